@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"github.com/gracchi-stdio/barf/internal/domain"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// runKey mints, lists and revokes the api keys of integrations
+func runKey(args []string) error {
+	if len(args) == 0 {
+		keyUsage()
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "create":
+		return runKeyCreate(args[1:])
+	case "list":
+		return runKeyList(args[1:])
+	case "revoke":
+		return runKeyRevoke(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "barfctl key: unknown subcommand %q\n", args[0])
+		keyUsage()
+		os.Exit(2)
+	}
+	return nil
+}
+
+func keyUsage() {
+	fmt.Fprintln(os.Stderr, "usage: barfctl key create -name NAME [-role ROLE] [-expires DURATION]")
+	fmt.Fprintln(os.Stderr, "       barfctl key list")
+	fmt.Fprintln(os.Stderr, "       barfctl key revoke PREFIX")
+}
+
+// runKeyCreate prints a new key to stdout. It is shown only this once.
+func runKeyCreate(args []string) error {
+	flags := flag.NewFlagSet("key create", flag.ExitOnError)
+	name := flags.String("name", "", "who or what the key is for")
+	roleName := flags.String("role", string(domain.RoleViewer), fmt.Sprintf("role of the key, one of %v", domain.Roles))
+	expires := flags.Duration("expires", 0, "lifetime of the key, e.g. 2160h; by default it never expires")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: barfctl key create -name NAME [-role ROLE] [-expires DURATION]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *name == "" || flags.NArg() != 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	role, err := domain.ParseRole(*roleName)
+	if err != nil {
+		return err
+	}
+
+	app, err := newApp()
+	if err != nil {
+		return err
+	}
+
+	key, secret, err := app.AuthService.CreateAPIKey(context.Background(), *name, role, *expires)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "created %s key %s for %q; store it now, it can't be shown again\n", key.Role, key.Prefix, key.Name)
+	fmt.Println(secret)
+	return nil
+}
+
+func runKeyList(args []string) error {
+	if len(args) != 0 {
+		keyUsage()
+		os.Exit(2)
+	}
+
+	app, err := newApp()
+	if err != nil {
+		return err
+	}
+
+	keys, err := app.AuthService.ListAPIKeys(context.Background())
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PREFIX\tNAME\tROLE\tSTATUS\tEXPIRES\tLAST USED\tCREATED")
+	for _, key := range keys {
+		status := "active"
+		switch {
+		case key.RevokedAt != nil:
+			status = "revoked"
+		case !key.Active(now):
+			status = "expired"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			key.Prefix,
+			key.Name,
+			key.Role,
+			status,
+			formatTime(key.ExpiresAt),
+			formatTime(key.LastUsedAt),
+			key.CreatedAt.Format(time.RFC3339))
+	}
+	return w.Flush()
+}
+
+func runKeyRevoke(args []string) error {
+	if len(args) != 1 {
+		keyUsage()
+		os.Exit(2)
+	}
+
+	app, err := newApp()
+	if err != nil {
+		return err
+	}
+
+	key, err := app.AuthService.RevokeAPIKey(context.Background(), args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "revoked key %s for %q\n", key.Prefix, key.Name)
+	return nil
+}
+
+// runUser adds staff users who log in to the api and changes their roles
+func runUser(args []string) error {
+	if len(args) == 0 {
+		userUsage()
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "add":
+		return runUserAdd(args[1:])
+	case "role":
+		return runUserRole(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "barfctl user: unknown subcommand %q\n", args[0])
+		userUsage()
+		os.Exit(2)
+	}
+	return nil
+}
+
+func userUsage() {
+	fmt.Fprintln(os.Stderr, "usage: barfctl user add [-name NAME] [-role ROLE] EMAIL")
+	fmt.Fprintln(os.Stderr, "       barfctl user role EMAIL ROLE")
+}
+
+// runUserAdd adds a user. The password is read from BARF_PASSWORD or else
+// the first line of stdin.
+func runUserAdd(args []string) error {
+	flags := flag.NewFlagSet("user add", flag.ExitOnError)
+	name := flags.String("name", "", "display name")
+	roleName := flags.String("role", string(domain.RoleViewer), fmt.Sprintf("role of the user, one of %v", domain.Roles))
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: barfctl user add [-name NAME] [-role ROLE] EMAIL")
+		fmt.Fprintln(os.Stderr, "the password is read from BARF_PASSWORD or stdin")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	email := flags.Arg(0)
+	role, err := domain.ParseRole(*roleName)
+	if err != nil {
+		return err
+	}
+
+	password := os.Getenv("BARF_PASSWORD")
+	if password == "" {
+		fmt.Fprint(os.Stderr, "password: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("failed to read password: %w", err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+
+	app, err := newApp()
+	if err != nil {
+		return err
+	}
+
+	user, err := app.AuthService.CreateUser(context.Background(), email, *name, password, role)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "created %s user %s\n", user.Role, user.Email)
+	fmt.Println(user.ID)
+	return nil
+}
+
+func runUserRole(args []string) error {
+	if len(args) != 2 {
+		userUsage()
+		os.Exit(2)
+	}
+	role, err := domain.ParseRole(args[1])
+	if err != nil {
+		return err
+	}
+
+	app, err := newApp()
+	if err != nil {
+		return err
+	}
+
+	user, err := app.AuthService.SetUserRole(context.Background(), args[0], role)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "user %s is now a %s\n", user.Email, user.Role)
+	return nil
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/gracchi-stdio/barf/internal/service"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// runImport loads books from FILE, or stdin when FILE is "-", and prints the
+// import report as json.
+func runImport(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	format := flags.String("format", "", "csv, jsonl, onix, marc or marcxml, defaults to the file extension")
+	mode := flags.String("mode", string(service.ImportSet), "set or add the stock quantity of existing books")
+	enrich := flags.Bool("enrich", false, "fill in missing details from the book fetchers")
+	provider := flags.String("provider", "", "book fetcher used for enrichment, defaults to the fetcher chain")
+	batch := flags.Int("batch", 500, "rows per transaction")
+	currency := flags.String("currency", "", "currency of prices read from onix feeds, defaults to the configured currency")
+	location := flags.String("location", "", "code of the location the stock is at, defaults to the default location")
+	failedOnly := flags.Bool("failed-only", false, "report only the rows that failed")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: barfctl import [flags] FILE")
+		fmt.Fprintln(os.Stderr, "runs as the api key in BARF_API_KEY, which needs the buyer role or above")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	path := flags.Arg(0)
+
+	if *format == "" {
+		*format = filepath.Ext(path)
+	}
+	importFormat, err := service.ParseImportFormat(*format)
+	if err != nil {
+		return err
+	}
+
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	app, err := newApp()
+	if err != nil {
+		return err
+	}
+
+	ctx, err := authContext(app)
+	if err != nil {
+		return err
+	}
+
+	report, err := app.ImportService.Import(ctx, in, service.ImportOptions{
+		Format:    importFormat,
+		Mode:      service.ImportMode(*mode),
+		Enrich:    *enrich,
+		Provider:  *provider,
+		BatchSize: *batch,
+		Currency:  *currency,
+		Location:  *location,
+	})
+	if report != nil {
+		if *failedOnly {
+			rows := report.Rows[:0]
+			for _, row := range report.Rows {
+				if row.Error != "" {
+					rows = append(rows, row)
+				}
+			}
+			report.Rows = rows
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	}
+
+	return err
+}
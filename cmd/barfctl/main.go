@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gracchi-stdio/barf/internal/config"
+	"github.com/gracchi-stdio/barf/internal/domain"
+	"github.com/gracchi-stdio/barf/internal/server"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"os"
+	"sort"
+)
+
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"import":    {"import books from csv, json lines, onix or marc", runImport},
+	"key":       {"create, list or revoke api keys", runKey},
+	"reconcile": {"check stock quantities against the stock ledger", runReconcile},
+	"user":      {"add staff users or change their role", runUser},
+}
+
+// barfctl runs catalog maintenance tasks against the database configured in
+// config.yaml. Logs go to stderr so command output can be piped.
+func main() {
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "barfctl: unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+
+	if err := cmd.run(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "barfctl %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(os.Stderr, "usage: barfctl COMMAND [flags]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].usage)
+	}
+}
+
+// newApp connects to the database and builds the services
+func newApp() (*server.App, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	return server.NewApp(cfg)
+}
+
+// authContext authenticates with the api key in BARF_API_KEY, so catalog
+// commands are held to the key's role just like api requests
+func authContext(app *server.App) (context.Context, error) {
+	key := os.Getenv("BARF_API_KEY")
+	if key == "" {
+		return nil, errors.New("set BARF_API_KEY to an api key whose role may run this command")
+	}
+
+	ctx := context.Background()
+	principal, err := app.AuthService.AuthenticateAPIKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return domain.WithPrincipal(ctx, principal), nil
+}
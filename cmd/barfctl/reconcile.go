@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// runReconcile prints the books whose quantity, in total or at a location,
+// differs from their stock ledger as json and fails if there are any
+func runReconcile(args []string) error {
+	if len(args) != 0 {
+		fmt.Fprintln(os.Stderr, "usage: barfctl reconcile")
+		fmt.Fprintln(os.Stderr, "runs as the api key in BARF_API_KEY")
+		os.Exit(2)
+	}
+
+	app, err := newApp()
+	if err != nil {
+		return err
+	}
+	ctx, err := authContext(app)
+	if err != nil {
+		return err
+	}
+
+	drift, err := app.StockService.Reconcile(ctx)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(drift); err != nil {
+		return err
+	}
+
+	if len(drift) > 0 {
+		return fmt.Errorf("%d stock quantities differ from the stock ledger", len(drift))
+	}
+	return nil
+}
@@ -1,5 +1,82 @@
-package worldcat
+package main
 
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/gracchi-stdio/barf/internal/config"
+	"github.com/gracchi-stdio/barf/pkg/bookfetcher"
+	"github.com/gracchi-stdio/barf/pkg/bookfetcher/providers/worldcat"
+	"os"
+	"time"
+)
+
+// worldcat looks up a book by ISBN or runs a keyword search against WorldCat.
+// Credentials default to the worldcat provider in config.yaml and
+// can be overridden by flags or the WORLDCAT_CLIENT_ID and
+// WORLDCAT_CLIENT_SECRET environment variables.
 func main() {
-	// TODO
+	isbn := flag.String("isbn", "", "isbn to look up")
+	query := flag.String("q", "", "keyword search query")
+	limit := flag.Int("limit", 10, "maximum search results")
+	clientID := flag.String("client-id", os.Getenv("WORLDCAT_CLIENT_ID"), "WSKey client id")
+	clientSecret := flag.String("client-secret", os.Getenv("WORLDCAT_CLIENT_SECRET"), "WSKey secret")
+	baseURL := flag.String("base-url", "", "search API base url")
+	tokenURL := flag.String("token-url", "", "oauth token endpoint")
+	timeout := flag.Duration("timeout", 30*time.Second, "overall timeout")
+	flag.Parse()
+
+	if (*isbn == "") == (*query == "") {
+		fmt.Fprintln(os.Stderr, "usage: worldcat -isbn ISBN | -q QUERY [flags]")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	options := map[string]string{}
+	if cfg, err := config.Load(); err == nil {
+		if provider, ok := cfg.Provider("worldcat"); ok {
+			options = provider.FactoryConfig()
+		}
+	}
+	for key, value := range map[string]string{
+		"clientId":     *clientID,
+		"clientSecret": *clientSecret,
+		"baseURL":      *baseURL,
+		"tokenURL":     *tokenURL,
+	} {
+		if value != "" {
+			options[key] = value
+		}
+	}
+
+	fetcher, err := worldcat.WorldCatFactory{}.CreateFetcher(options)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "worldcat:", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	var result any
+	if *isbn != "" {
+		result, err = fetcher.GetBookByISBN(ctx, *isbn)
+	} else {
+		result, err = fetcher.SearchBooks(ctx, bookfetcher.SearchOptions{
+			Query:      *query,
+			MaxResults: *limit,
+		})
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "worldcat:", err)
+		os.Exit(1)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(result); err != nil {
+		fmt.Fprintln(os.Stderr, "worldcat:", err)
+		os.Exit(1)
+	}
 }
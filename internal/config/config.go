@@ -2,7 +2,9 @@ package config
 
 import (
 	"github.com/spf13/viper"
+	"sort"
 	"strings"
+	"time"
 )
 
 type Server struct {
@@ -18,9 +20,162 @@ type Database struct {
 	Port     string
 }
 
+// Provider configures a book fetcher provider. Listed providers are enabled
+// unless Enabled is set to false.
+type Provider struct {
+	Name    string
+	Enabled *bool
+	// Default marks the provider tried first for ISBN lookups
+	Default bool
+	// Priority orders the remaining providers, lowest first
+	Priority int
+	APIKey   string
+	Timeout  time.Duration
+	// Options are passed to the provider factory as is
+	Options map[string]string
+}
+
+func (p Provider) IsEnabled() bool {
+	return p.Enabled == nil || *p.Enabled
+}
+
+// FactoryConfig returns the config map for the provider's FetcherFactory
+func (p Provider) FactoryConfig() map[string]string {
+	config := make(map[string]string, len(p.Options)+2)
+	for k, v := range p.Options {
+		config[k] = v
+	}
+	if p.APIKey != "" {
+		config["apiKey"] = p.APIKey
+	}
+	if p.Timeout > 0 {
+		config["timeout"] = p.Timeout.String()
+	}
+	return config
+}
+
+type BookFetcher struct {
+	// Precedence lists preferred providers per field for merged lookups
+	Precedence map[string][]string
+	Cache      LookupCache
+	Health     ProviderHealth
+}
+
+type ProviderHealth struct {
+	// ProbeInterval is how often providers are probed; zero disables probes
+	ProbeInterval      time.Duration
+	ProbeTimeout       time.Duration
+	FailureThreshold   int
+	ErrorRateThreshold float64
+	Window             int
+	OpenTimeout        time.Duration
+}
+
+type LookupCache struct {
+	Enabled bool
+	// TTL is how long a found book is cached, overridden per provider by ProviderTTL
+	TTL         time.Duration
+	ProviderTTL map[string]time.Duration
+	// NegativeTTL is how long a not found answer is cached
+	NegativeTTL time.Duration
+}
+
+// TTLFor returns the cache ttl for provider
+func (c LookupCache) TTLFor(provider string) time.Duration {
+	if ttl, ok := c.ProviderTTL[provider]; ok {
+		return ttl
+	}
+	return c.TTL
+}
+
+// Onix configures ONIX feeds
+type Onix struct {
+	// SenderName is sent in the header of exported feeds
+	SenderName string
+	// Currency selects the price taken from imported feeds and is the
+	// currency of exported prices
+	Currency string
+}
+
+// MARC configures MARC 21 record exchange
+type MARC struct {
+	// Mapping overrides the record location of book fields, e.g.
+	// publisher: ["264_1$b", "260$b"]. Unlisted fields keep the default.
+	Mapping map[string][]string
+}
+
+// OPDS configures the public OPDS catalog
+type OPDS struct {
+	// Title names the catalog in feeds and the OpenSearch description
+	Title string
+	// PageSize is the default number of entries per feed page
+	PageSize int
+}
+
+// Auth configures api authentication
+type Auth struct {
+	// Secret signs access tokens. When empty a random secret is used, so
+	// tokens don't outlive the process.
+	Secret string
+	// Issuer is the iss claim of access tokens
+	Issuer string
+	// AccessTTL is how long an access token is valid
+	AccessTTL time.Duration
+	// RefreshTTL is how long a refresh token is valid
+	RefreshTTL time.Duration
+}
+
+// Stock configures the stock ledger and locations
+type Stock struct {
+	// ReconcileInterval is how often the server checks quantities against
+	// the ledger; zero disables the check
+	ReconcileInterval time.Duration
+	// DefaultLocation and DefaultLocationName are the code and name of the
+	// location made on first start, where stock goes when no location is
+	// named
+	DefaultLocation     string
+	DefaultLocationName string
+}
+
 type Config struct {
-	Server Server
-	DB     Database
+	Server      Server
+	DB          Database
+	BookFetcher BookFetcher
+	Providers   []Provider
+	Onix        Onix
+	MARC        MARC
+	OPDS        OPDS
+	Auth        Auth
+	Stock       Stock
+}
+
+// EnabledProviders returns the enabled providers in lookup order: the
+// default provider first, then the others by priority.
+func (c *Config) EnabledProviders() []Provider {
+	providers := make([]Provider, 0, len(c.Providers))
+	for _, p := range c.Providers {
+		if p.IsEnabled() {
+			providers = append(providers, p)
+		}
+	}
+
+	sort.SliceStable(providers, func(i, j int) bool {
+		if providers[i].Default != providers[j].Default {
+			return providers[i].Default
+		}
+		return providers[i].Priority < providers[j].Priority
+	})
+	return providers
+}
+
+// Provider returns the configuration of the provider called name
+func (c *Config) Provider(name string) (Provider, bool) {
+	for _, p := range c.Providers {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Provider{}, false
 }
 
 func Load() (*Config, error) {
@@ -35,6 +190,41 @@ func Load() (*Config, error) {
 	viper.SetDefault("db.name", "")
 	viper.SetDefault("db.port", "5432")
 
+	// provider defaults
+	viper.SetDefault("providers", []map[string]interface{}{
+		{"name": "googlebooks", "default": true, "priority": 1, "timeout": "5s"},
+		{"name": "openlibrary", "priority": 2, "timeout": "10s"},
+	})
+
+	// book fetcher defaults
+	viper.SetDefault("bookfetcher.cache.enabled", true)
+	viper.SetDefault("bookfetcher.cache.ttl", "720h")
+	viper.SetDefault("bookfetcher.cache.negativettl", "24h")
+	viper.SetDefault("bookfetcher.health.probeinterval", "1m")
+	viper.SetDefault("bookfetcher.health.probetimeout", "5s")
+	viper.SetDefault("bookfetcher.health.failurethreshold", 5)
+	viper.SetDefault("bookfetcher.health.errorratethreshold", 0.5)
+	viper.SetDefault("bookfetcher.health.window", 20)
+	viper.SetDefault("bookfetcher.health.opentimeout", "30s")
+
+	// onix defaults
+	viper.SetDefault("onix.sendername", "barf")
+	viper.SetDefault("onix.currency", "USD")
+
+	// opds defaults
+	viper.SetDefault("opds.title", "barf catalog")
+	viper.SetDefault("opds.pagesize", 25)
+
+	// auth defaults
+	viper.SetDefault("auth.issuer", "barf")
+	viper.SetDefault("auth.accessttl", "15m")
+	viper.SetDefault("auth.refreshttl", "720h")
+
+	// stock defaults
+	viper.SetDefault("stock.reconcileinterval", "1h")
+	viper.SetDefault("stock.defaultlocation", "main")
+	viper.SetDefault("stock.defaultlocationname", "Main store")
+
 	// config file settings
 	viper.SetConfigName("config")
 	viper.SetConfigType("yaml")
@@ -77,6 +267,9 @@ func Load() (*Config, error) {
 	if dbPort := viper.GetString("DB_PORT"); dbPort != "" {
 		cfg.DB.Port = dbPort
 	}
+	if authSecret := viper.GetString("AUTH_SECRET"); authSecret != "" {
+		cfg.Auth.Secret = authSecret
+	}
 
 	return &cfg, nil
 }
@@ -0,0 +1,108 @@
+package domain
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"time"
+)
+
+// User is a staff member who logs in with email and password
+type User struct {
+	ID           uuid.UUID `json:"id" gorm:"primary_key;type:uuid;default:uuid_generate_v4()"`
+	Email        string    `json:"email" gorm:"not null;uniqueIndex"`
+	Name         string    `json:"name"`
+	PasswordHash string    `json:"-" gorm:"not null"`
+	Role         Role      `json:"role" gorm:"not null;default:viewer"`
+	Disabled     bool      `json:"disabled" gorm:"not null;default:false"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// APIKey authenticates an integration. Only a hash of the key is stored; the
+// key itself is shown once, when it is created. Prefix identifies the key in
+// listings and logs and is the first part of the key.
+type APIKey struct {
+	ID         uuid.UUID  `json:"id" gorm:"primary_key;type:uuid;default:uuid_generate_v4()"`
+	Name       string     `json:"name" gorm:"not null"`
+	Prefix     string     `json:"prefix" gorm:"not null;uniqueIndex"`
+	Hash       string     `json:"-" gorm:"not null"`
+	Role       Role       `json:"role" gorm:"not null;default:viewer"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// Active reports whether the key may be used at now
+func (k *APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// RefreshToken lets a logged in user get new access tokens. Tokens are used
+// once: each refresh revokes the token and issues a new one in the same
+// family, so a token that is presented twice reveals a leak and revokes the
+// whole family.
+type RefreshToken struct {
+	ID        uuid.UUID  `json:"id" gorm:"primary_key;type:uuid;default:uuid_generate_v4()"`
+	UserID    uuid.UUID  `json:"user_id" gorm:"type:uuid;not null;index"`
+	User      User       `json:"-" gorm:"foreignkey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	FamilyID  uuid.UUID  `json:"family_id" gorm:"type:uuid;not null;index"`
+	Hash      string     `json:"-" gorm:"not null;uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// TokenPair is what a login or refresh returns
+type TokenPair struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	RefreshToken string    `json:"refresh_token"`
+}
+
+type PrincipalKind string
+
+const (
+	PrincipalUser      PrincipalKind = "user"
+	PrincipalAPIKey    PrincipalKind = "api_key"
+	PrincipalAnonymous PrincipalKind = "anonymous"
+	PrincipalSystem    PrincipalKind = "system"
+)
+
+// Principal is who a request or job acts for: a logged in user, an API key,
+// an anonymous reader of the public catalog or a background job. Its role
+// decides what it may do.
+type Principal struct {
+	Kind PrincipalKind `json:"kind"`
+	ID   uuid.UUID     `json:"id"`
+	Name string        `json:"name"`
+	Role Role          `json:"role"`
+}
+
+// Can reports whether the principal's role has permission p
+func (p *Principal) Can(perm Permission) bool {
+	return p.Role.Can(perm)
+}
+
+// Anonymous is the principal of requests to the public routes
+var Anonymous = &Principal{Kind: PrincipalAnonymous, Name: "anonymous", Role: RoleViewer}
+
+// SystemPrincipal returns the principal of a background job called name
+func SystemPrincipal(name string, role Role) *Principal {
+	return &Principal{Kind: PrincipalSystem, Name: name, Role: role}
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a context carrying p
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the principal of ctx, if it has one
+func PrincipalFrom(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
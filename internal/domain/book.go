@@ -8,7 +8,7 @@ import (
 type Book struct {
 	ID              uuid.UUID  `json:"id" gorm:"primary_key;type:uuid;default:uuid_generate_v4()"`
 	Title           string     `json:"title" gorm:"not null"`
-	ISBN            string     `json:"isbn" gorm:"not null"`
+	ISBN            string     `json:"isbn" gorm:"not null"` // unique once migrated, see repository.MigrateBookISBNs
 	Author          string     `json:"author" gorm:"not null"`
 	Publisher       string     `json:"publisher"`
 	PublicationDate string     `json:"publication_date"`
@@ -17,13 +17,51 @@ type Book struct {
 	DeletedAt       *time.Time `json:"deleted_at"`
 }
 
+// Inventory is the stock of a book across all locations. Quantity is the
+// total; Locations breaks it down by location when loaded.
 type Inventory struct {
-	ID        uuid.UUID  `json:"id" gorm:"primary_key;type:uuid;default:uuid_generate_v4()"`
-	BookID    uuid.UUID  `json:"book_id" gorm:"type:uuid;not null"`
-	Book      Book       `gorm:"foreignkey:BookID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
-	Quantity  int        `json:"quantity" gorm:"not null"`
-	Price     float64    `json:"price"`
-	CreatedAt time.Time  `json:"created_at"`
-	UpdatedAt time.Time  `json:"updated_at"`
-	DeletedAt *time.Time `json:"deleted_at"`
+	ID        uuid.UUID       `json:"id" gorm:"primary_key;type:uuid;default:uuid_generate_v4()"`
+	BookID    uuid.UUID       `json:"book_id" gorm:"type:uuid;not null"`
+	Book      Book            `gorm:"foreignkey:BookID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Quantity  int             `json:"quantity" gorm:"not null"`
+	Price     float64         `json:"price"`
+	Locations []LocationStock `json:"locations,omitempty" gorm:"-"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	DeletedAt *time.Time      `json:"deleted_at"`
+}
+
+// CatalogEntry is a book together with its stock, as exported in catalog
+// dumps.
+type CatalogEntry struct {
+	ID              uuid.UUID `json:"id"`
+	ISBN            string    `json:"isbn"`
+	Title           string    `json:"title"`
+	Author          string    `json:"author"`
+	Publisher       string    `json:"publisher"`
+	PublicationDate string    `json:"publication_date"`
+	Quantity        int       `json:"quantity"`
+	Price           float64   `json:"price"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// Facet fields books can be grouped by
+const (
+	FacetAuthor    = "author"
+	FacetPublisher = "publisher"
+)
+
+// BookFacets narrow a listing to the books with exactly these field values.
+// Empty fields don't narrow it.
+type BookFacets struct {
+	Author    string
+	Publisher string
+}
+
+// FacetCount is a value of a facet field and the number of books that have
+// it
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
 }
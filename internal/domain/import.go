@@ -0,0 +1,34 @@
+package domain
+
+import (
+	"github.com/google/uuid"
+)
+
+type ImportRowStatus string
+
+const (
+	ImportCreated ImportRowStatus = "created"
+	ImportUpdated ImportRowStatus = "updated"
+	ImportFailed  ImportRowStatus = "failed"
+)
+
+// ImportRowResult reports what a bulk import did with one input row. Line is
+// the line number in the input file, or the position of the product in an
+// ONIX feed, whose record reference is given as Reference.
+type ImportRowResult struct {
+	Line      int             `json:"line"`
+	Reference string          `json:"reference,omitempty"`
+	ISBN      string          `json:"isbn,omitempty"`
+	Status    ImportRowStatus `json:"status"`
+	BookID    *uuid.UUID      `json:"book_id,omitempty"`
+	Enriched  bool            `json:"enriched,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+type ImportReport struct {
+	Total   int               `json:"total"`
+	Created int               `json:"created"`
+	Updated int               `json:"updated"`
+	Failed  int               `json:"failed"`
+	Rows    []ImportRowResult `json:"rows"`
+}
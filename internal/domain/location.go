@@ -0,0 +1,50 @@
+package domain
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// LocationKind says what sort of place a location is
+type LocationKind string
+
+const (
+	LocationStore     LocationKind = "store"
+	LocationWarehouse LocationKind = "warehouse"
+)
+
+// Location is a place stock is kept, such as a shop or a warehouse. Code
+// identifies it in requests and reports. Stock changes that name no
+// location happen at the default location.
+type Location struct {
+	ID        uuid.UUID    `json:"id" gorm:"primary_key;type:uuid;default:uuid_generate_v4()"`
+	Code      string       `json:"code" gorm:"not null;uniqueIndex"`
+	Name      string       `json:"name" gorm:"not null"`
+	Kind      LocationKind `json:"kind" gorm:"not null;default:store"`
+	IsDefault bool         `json:"default" gorm:"not null;default:false"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// LocationStock is the stock of a book at one location. Bin optionally
+// names the shelf or bin the copies are in. The quantities of a book's
+// locations add up to its inventory quantity.
+type LocationStock struct {
+	ID         uuid.UUID `json:"id" gorm:"primary_key;type:uuid;default:uuid_generate_v4()"`
+	BookID     uuid.UUID `json:"book_id" gorm:"type:uuid;not null;uniqueIndex:idx_location_stocks_book_location"`
+	Book       *Book     `json:"book,omitempty" gorm:"foreignkey:BookID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	LocationID uuid.UUID `json:"location_id" gorm:"type:uuid;not null;uniqueIndex:idx_location_stocks_book_location"`
+	Location   *Location `json:"location,omitempty" gorm:"foreignkey:LocationID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;"`
+	Bin        string    `json:"bin"`
+	Quantity   int       `json:"quantity" gorm:"not null;default:0"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Transfer is a move of copies between locations: the movement out of the
+// source and the movement into the destination, which share a reference.
+type Transfer struct {
+	Reference string        `json:"reference"`
+	Out       StockMovement `json:"out"`
+	In        StockMovement `json:"in"`
+}
@@ -0,0 +1,24 @@
+package domain
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// LookupCache stores a provider's answer for an ISBN. Info holds the
+// bookfetcher.BookInfo, including its raw provider data, as JSON.
+type LookupCache struct {
+	ID        uuid.UUID `json:"id" gorm:"primary_key;type:uuid;default:uuid_generate_v4()"`
+	Provider  string    `json:"provider" gorm:"not null;uniqueIndex:idx_lookup_cache_provider_isbn"`
+	ISBN      string    `json:"isbn" gorm:"not null;uniqueIndex:idx_lookup_cache_provider_isbn"`
+	Info      string    `json:"info" gorm:"type:jsonb"`
+	NotFound  bool      `json:"not_found" gorm:"not null;default:false"`
+	FetchedAt time.Time `json:"fetched_at" gorm:"not null"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"not null;index"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (LookupCache) TableName() string {
+	return "lookup_cache"
+}
@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type ReceivingStatus string
+
+const (
+	ReceivingOpen   ReceivingStatus = "open"
+	ReceivingClosed ReceivingStatus = "closed"
+)
+
+type ReceivingAction string
+
+const (
+	// ReceivingRestock adds stock to a book already in the catalog
+	ReceivingRestock ReceivingAction = "restock"
+	// ReceivingNew stages a new book from a provider lookup until it is confirmed
+	ReceivingNew ReceivingAction = "new"
+	// ReceivingFailed marks a scan whose isbn could not be looked up
+	ReceivingFailed ReceivingAction = "failed"
+)
+
+// ReceivingSession groups the scans of one batch of incoming stock. Nothing
+// reaches the catalog or inventory until the session is closed, when the
+// copies are stocked at the session's location; sessions opened before
+// locations existed stock the default location.
+type ReceivingSession struct {
+	ID         uuid.UUID       `json:"id" gorm:"primary_key;type:uuid;default:uuid_generate_v4()"`
+	Status     ReceivingStatus `json:"status" gorm:"not null;default:'open'"`
+	Note       string          `json:"note"`
+	LocationID *uuid.UUID      `json:"location_id" gorm:"type:uuid"`
+	Lines      []ReceivingLine `json:"lines" gorm:"foreignkey:SessionID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	ClosedAt   *time.Time      `json:"closed_at"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+}
+
+// ReceivingLine is one title scanned in a session; repeated scans of the same
+// isbn increase its quantity
+type ReceivingLine struct {
+	ID              uuid.UUID       `json:"id" gorm:"primary_key;type:uuid;default:uuid_generate_v4()"`
+	SessionID       uuid.UUID       `json:"session_id" gorm:"type:uuid;not null;index"`
+	ISBN            string          `json:"isbn" gorm:"not null"`
+	Quantity        int             `json:"quantity" gorm:"not null"`
+	Action          ReceivingAction `json:"action" gorm:"not null"`
+	BookID          *uuid.UUID      `json:"book_id" gorm:"type:uuid"`
+	Title           string          `json:"title"`
+	Author          string          `json:"author"`
+	Publisher       string          `json:"publisher"`
+	PublicationDate string          `json:"publication_date"`
+	Price           float64         `json:"price"`
+	Provider        string          `json:"provider"`
+	Confirmed       bool            `json:"confirmed"`
+	Error           string          `json:"error,omitempty"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+}
+
+// ReceivingSummary reports what closing a session did
+type ReceivingSummary struct {
+	SessionID   uuid.UUID       `json:"session_id"`
+	NewTitles   []ReceivingLine `json:"new_titles"`
+	Restocked   []ReceivingLine `json:"restocked"`
+	Failed      []ReceivingLine `json:"failed"`
+	Unconfirmed []ReceivingLine `json:"unconfirmed"`
+	TotalCopies int             `json:"total_copies"`
+}
@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Role is what a user or api key is trusted to do
+type Role string
+
+const (
+	// RoleViewer reads the catalog and stock
+	RoleViewer Role = "viewer"
+	// RoleClerk also sells and receives stock
+	RoleClerk Role = "clerk"
+	// RoleBuyer also adds and edits books and sets prices
+	RoleBuyer Role = "buyer"
+	// RoleManager also deletes books and sets up locations
+	RoleManager Role = "manager"
+	// RoleAdmin may do anything, including managing the book fetchers
+	RoleAdmin Role = "admin"
+)
+
+// Roles lists the roles from the least to the most trusted
+var Roles = []Role{RoleViewer, RoleClerk, RoleBuyer, RoleManager, RoleAdmin}
+
+// Permission is an operation guarded by role
+type Permission string
+
+const (
+	PermReadCatalog     Permission = "read_catalog"
+	PermEditCatalog     Permission = "edit_catalog"
+	PermAdjustInventory Permission = "adjust_inventory"
+	PermChangePrice     Permission = "change_price"
+	PermDeleteBook      Permission = "delete_book"
+	PermManageProviders Permission = "manage_providers"
+	PermManageLocations Permission = "manage_locations"
+)
+
+// rolePermissions is the permission matrix
+var rolePermissions = map[Role][]Permission{
+	RoleViewer: {PermReadCatalog},
+	RoleClerk:  {PermReadCatalog, PermAdjustInventory},
+	RoleBuyer:  {PermReadCatalog, PermAdjustInventory, PermEditCatalog, PermChangePrice},
+	RoleManager: {PermReadCatalog, PermAdjustInventory, PermEditCatalog, PermChangePrice,
+		PermDeleteBook, PermManageLocations},
+	RoleAdmin: {PermReadCatalog, PermAdjustInventory, PermEditCatalog, PermChangePrice,
+		PermDeleteBook, PermManageLocations, PermManageProviders},
+}
+
+// ParseRole parses a role name
+func ParseRole(name string) (Role, error) {
+	role := Role(strings.ToLower(strings.TrimSpace(name)))
+	if _, ok := rolePermissions[role]; !ok {
+		return "", fmt.Errorf("unknown role %q, want one of %v", name, Roles)
+	}
+	return role, nil
+}
+
+// Can reports whether the role has permission p. Unknown roles have none.
+func (r Role) Can(p Permission) bool {
+	return slices.Contains(rolePermissions[r], p)
+}
+
+// Permissions returns the permissions of the role
+func (r Role) Permissions() []Permission {
+	return slices.Clone(rolePermissions[r])
+}
@@ -0,0 +1,48 @@
+package domain
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// MovementReason says why stock changed
+type MovementReason string
+
+const (
+	MovementReceived   MovementReason = "received"
+	MovementSold       MovementReason = "sold"
+	MovementReturned   MovementReason = "returned"
+	MovementDamaged    MovementReason = "damaged"
+	MovementAdjustment MovementReason = "adjustment"
+	MovementTransfer   MovementReason = "transfer"
+)
+
+// StockMovement is an entry of the stock ledger. Every change to a book's
+// quantity writes one in the same transaction, and entries are never updated
+// or deleted, so the quantity at each location is the sum of the book's
+// deltas there. Reference names the document behind the change, such as a
+// receiving session or an order number; the actor is the principal that
+// made it.
+type StockMovement struct {
+	ID         uuid.UUID      `json:"id" gorm:"primary_key;type:uuid;default:uuid_generate_v4()"`
+	BookID     uuid.UUID      `json:"book_id" gorm:"type:uuid;not null;index:idx_stock_movements_book_created"`
+	LocationID uuid.UUID      `json:"location_id" gorm:"type:uuid;index"`
+	Delta      int            `json:"delta" gorm:"not null"`
+	Reason     MovementReason `json:"reason" gorm:"not null"`
+	Reference  string         `json:"reference"`
+	ActorKind  PrincipalKind  `json:"actor_kind" gorm:"not null"`
+	ActorID    *uuid.UUID     `json:"actor_id" gorm:"type:uuid"`
+	ActorName  string         `json:"actor_name"`
+	CreatedAt  time.Time      `json:"created_at" gorm:"not null;index:idx_stock_movements_book_created"`
+}
+
+// StockDrift is a book whose quantity differs from the sum of its ledger,
+// at a location or, when LocationID is nil, in total. Drift is how many
+// copies the quantity is over the ledger, or under it when negative.
+type StockDrift struct {
+	BookID         uuid.UUID  `json:"book_id"`
+	LocationID     *uuid.UUID `json:"location_id"`
+	Quantity       int        `json:"quantity"`
+	LedgerQuantity int        `json:"ledger_quantity"`
+	Drift          int        `json:"drift"`
+}
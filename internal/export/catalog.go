@@ -0,0 +1,280 @@
+// Package export encodes books and catalog dumps in the supported download
+// and citation formats.
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"github.com/gracchi-stdio/barf/internal/domain"
+	domainErr "github.com/gracchi-stdio/barf/pkg/errors"
+	"github.com/gracchi-stdio/barf/pkg/marc"
+	"github.com/gracchi-stdio/barf/pkg/onix"
+	"github.com/gracchi-stdio/barf/pkg/xlsx"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Format string
+
+const (
+	CSV     Format = "csv"
+	JSONL   Format = "jsonl"
+	XLSX    Format = "xlsx"
+	ONIX    Format = "onix"
+	MARC    Format = "marc"
+	MARCXML Format = "marcxml"
+)
+
+// Options configures the formats that need more than the entries
+type Options struct {
+	// OnixSender is the sender name in the header of ONIX feeds
+	OnixSender string
+	// Currency is the currency of prices in ONIX feeds
+	Currency string
+	// MARCMapping locates book fields in MARC records
+	MARCMapping marc.Mapping
+}
+
+func ParseFormat(name string) (Format, error) {
+	switch Format(strings.ToLower(name)) {
+	case CSV:
+		return CSV, nil
+	case JSONL, "ndjson":
+		return JSONL, nil
+	case XLSX:
+		return XLSX, nil
+	case ONIX:
+		return ONIX, nil
+	case MARC, "mrc":
+		return MARC, nil
+	case MARCXML:
+		return MARCXML, nil
+	case BibTeX, "bib":
+		return BibTeX, nil
+	case RIS:
+		return RIS, nil
+	case CSLJSON, "csljson", "csl":
+		return CSLJSON, nil
+	case JSONLD, "json-ld":
+		return JSONLD, nil
+	}
+	return "", domainErr.ErrUnsupportedFormat.Withf("%q", name)
+}
+
+func (f Format) ContentType() string {
+	switch f {
+	case CSV:
+		return "text/csv; charset=utf-8"
+	case JSONL:
+		return "application/x-ndjson"
+	case XLSX:
+		return xlsx.ContentType
+	case ONIX:
+		return "application/xml; charset=utf-8"
+	case MARC:
+		return "application/marc"
+	case MARCXML:
+		return "application/marcxml+xml; charset=utf-8"
+	case BibTeX:
+		return "application/x-bibtex; charset=utf-8"
+	case RIS:
+		return "application/x-research-info-systems; charset=utf-8"
+	case CSLJSON:
+		return "application/vnd.citationstyles.csl+json; charset=utf-8"
+	case JSONLD:
+		return "application/ld+json; charset=utf-8"
+	}
+	return "application/octet-stream"
+}
+
+// Extension returns the file name extension of the format
+func (f Format) Extension() string {
+	switch f {
+	case ONIX, MARCXML:
+		return "xml"
+	case MARC:
+		return "mrc"
+	case BibTeX:
+		return "bib"
+	case CSLJSON:
+		return "json"
+	}
+	return string(f)
+}
+
+// mediaTypes maps the media types clients ask for to formats
+var mediaTypes = map[string]Format{
+	"text/csv":                                CSV,
+	"application/x-ndjson":                    JSONL,
+	xlsx.ContentType:                          XLSX,
+	"application/marc":                        MARC,
+	"application/marcxml+xml":                 MARCXML,
+	"application/x-bibtex":                    BibTeX,
+	"text/x-bibtex":                           BibTeX,
+	"application/x-research-info-systems":     RIS,
+	"application/vnd.citationstyles.csl+json": CSLJSON,
+	"application/ld+json":                     JSONLD,
+}
+
+// FormatForMediaType returns the format of a media type without parameters
+func FormatForMediaType(mediaType string) (Format, bool) {
+	format, ok := mediaTypes[strings.ToLower(mediaType)]
+	return format, ok
+}
+
+// CatalogWriter writes catalog entries one at a time. Close flushes the
+// output and, for xlsx, finishes the workbook.
+type CatalogWriter interface {
+	Write(entry *domain.CatalogEntry) error
+	Close() error
+}
+
+// catalogColumns are the columns of tabular exports, in the same names as
+// the json fields and the import columns
+var catalogColumns = []string{
+	"id", "isbn", "title", "author", "publisher", "publication_date",
+	"quantity", "price", "created_at", "updated_at",
+}
+
+func NewCatalogWriter(w io.Writer, format Format, opts Options) (CatalogWriter, error) {
+	switch format {
+	case CSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(catalogColumns); err != nil {
+			return nil, err
+		}
+		return &csvCatalogWriter{w: cw}, nil
+	case JSONL:
+		return &jsonlCatalogWriter{enc: json.NewEncoder(w)}, nil
+	case XLSX:
+		xw, err := xlsx.NewWriter(w, "Catalog")
+		if err != nil {
+			return nil, err
+		}
+		header := make([]any, len(catalogColumns))
+		for i, column := range catalogColumns {
+			header[i] = column
+		}
+		if err := xw.WriteRow(header...); err != nil {
+			return nil, err
+		}
+		return &xlsxCatalogWriter{w: xw}, nil
+	case ONIX:
+		enc, err := onix.NewEncoder(w, onix.Header{Sender: onix.Sender{SenderName: opts.OnixSender}})
+		if err != nil {
+			return nil, err
+		}
+		return &onixCatalogWriter{enc: enc, opts: opts}, nil
+	case MARC:
+		return &marcCatalogWriter{w: marc.NewWriter(w), mapping: opts.MARCMapping}, nil
+	case MARCXML:
+		xw, err := marc.NewXMLWriter(w)
+		if err != nil {
+			return nil, err
+		}
+		return &marcXMLCatalogWriter{w: xw, mapping: opts.MARCMapping}, nil
+	case BibTeX:
+		return &bibtexCatalogWriter{w: bufio.NewWriter(w), keys: map[string]int{}}, nil
+	case RIS:
+		return &risCatalogWriter{w: bufio.NewWriter(w)}, nil
+	case CSLJSON:
+		return newJSONArrayWriter(w, "[", "]", func(entry *domain.CatalogEntry) any {
+			return newCSLItem(entry)
+		})
+	case JSONLD:
+		return newJSONArrayWriter(w, `{"@context": "`+schemaContext+`", "@graph": [`, "]}", func(entry *domain.CatalogEntry) any {
+			return newSchemaBook(entry)
+		})
+	}
+	return nil, domainErr.ErrUnsupportedFormat.Withf("%q", format)
+}
+
+type csvCatalogWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvCatalogWriter) Write(entry *domain.CatalogEntry) error {
+	return c.w.Write([]string{
+		entry.ID.String(),
+		entry.ISBN,
+		entry.Title,
+		entry.Author,
+		entry.Publisher,
+		entry.PublicationDate,
+		strconv.Itoa(entry.Quantity),
+		strconv.FormatFloat(entry.Price, 'f', 2, 64),
+		entry.CreatedAt.Format(time.RFC3339),
+		entry.UpdatedAt.Format(time.RFC3339),
+	})
+}
+
+func (c *csvCatalogWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type jsonlCatalogWriter struct {
+	enc *json.Encoder
+}
+
+func (j *jsonlCatalogWriter) Write(entry *domain.CatalogEntry) error {
+	return j.enc.Encode(entry)
+}
+
+func (j *jsonlCatalogWriter) Close() error {
+	return nil
+}
+
+type xlsxCatalogWriter struct {
+	w *xlsx.Writer
+}
+
+func (x *xlsxCatalogWriter) Write(entry *domain.CatalogEntry) error {
+	return x.w.WriteRow(
+		entry.ID.String(),
+		entry.ISBN,
+		entry.Title,
+		entry.Author,
+		entry.Publisher,
+		entry.PublicationDate,
+		entry.Quantity,
+		entry.Price,
+		entry.CreatedAt,
+		entry.UpdatedAt,
+	)
+}
+
+func (x *xlsxCatalogWriter) Close() error {
+	return x.w.Close()
+}
+
+// EncodeBook encodes a single book in one of the record formats: MARC,
+// MARCXML or a citation format.
+func EncodeBook(book *domain.Book, format Format, opts Options) ([]byte, error) {
+	switch format {
+	case MARC:
+		return MARCRecord(book, opts.MARCMapping).MarshalISO2709()
+	case MARCXML:
+		return marc.MarshalXML(MARCRecord(book, opts.MARCMapping))
+	case BibTeX, RIS, CSLJSON, JSONLD:
+		return encodeCitation(BookEntry(book), format)
+	}
+	return nil, domainErr.ErrUnsupportedFormat.Withf("%q", format)
+}
+
+// BookEntry returns the catalog entry of a book without its inventory
+func BookEntry(book *domain.Book) *domain.CatalogEntry {
+	return &domain.CatalogEntry{
+		ID:              book.ID,
+		ISBN:            book.ISBN,
+		Title:           book.Title,
+		Author:          book.Author,
+		Publisher:       book.Publisher,
+		PublicationDate: book.PublicationDate,
+		CreatedAt:       book.CreatedAt,
+		UpdatedAt:       book.UpdatedAt,
+	}
+}
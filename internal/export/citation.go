@@ -0,0 +1,347 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/gracchi-stdio/barf/internal/domain"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Citation formats
+const (
+	BibTeX  Format = "bibtex"
+	RIS     Format = "ris"
+	CSLJSON Format = "csl-json"
+	JSONLD  Format = "jsonld"
+)
+
+// citation is the part of a book that citation formats carry
+type citation struct {
+	id        string
+	title     string
+	authors   []string
+	publisher string
+	date      string
+	isbn      string
+}
+
+func newCitation(entry *domain.CatalogEntry) citation {
+	c := citation{
+		id:        entry.ID.String(),
+		title:     entry.Title,
+		publisher: entry.Publisher,
+		date:      entry.PublicationDate,
+		isbn:      entry.ISBN,
+	}
+	for _, author := range strings.Split(entry.Author, ";") {
+		if author = strings.TrimSpace(author); author != "" {
+			c.authors = append(c.authors, author)
+		}
+	}
+	return c
+}
+
+// dateParts splits a YYYY-MM-DD, YYYY-MM or YYYY date; unparseable parts
+// are dropped
+func (c citation) dateParts() []int {
+	var parts []int
+	for _, part := range strings.SplitN(c.date, "-", 3) {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			break
+		}
+		parts = append(parts, n)
+	}
+	return parts
+}
+
+// splitName returns the family and given names of "Family, Given" or
+// "Given Family". A single word is returned as the family name.
+func splitName(name string) (string, string) {
+	if family, given, ok := strings.Cut(name, ","); ok {
+		return strings.TrimSpace(family), strings.TrimSpace(given)
+	}
+	words := strings.Fields(name)
+	if len(words) < 2 {
+		return name, ""
+	}
+	return words[len(words)-1], strings.Join(words[:len(words)-1], " ")
+}
+
+// BibTeX
+
+var bibtexMonths = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+
+var bibtexEscaper = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`&`, `\&`,
+	`%`, `\%`,
+	`$`, `\$`,
+	`#`, `\#`,
+	`_`, `\_`,
+	`~`, `\textasciitilde{}`,
+	`^`, `\textasciicircum{}`,
+)
+
+type bibtexCatalogWriter struct {
+	w    *bufio.Writer
+	keys map[string]int
+}
+
+func (b *bibtexCatalogWriter) Write(entry *domain.CatalogEntry) error {
+	c := newCitation(entry)
+
+	// keys are the first author's family name and the year, with a letter
+	// appended when the same key comes up again
+	key := b.key(c)
+	if n := b.keys[key]; n > 0 {
+		b.keys[key]++
+		key += string(rune('a' + (n-1)%26))
+	} else {
+		b.keys[key] = 1
+	}
+
+	fmt.Fprintf(b.w, "@book{%s,\n", key)
+	field := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(b.w, "  %s = {%s},\n", name, bibtexEscaper.Replace(value))
+		}
+	}
+	field("title", c.title)
+	field("author", strings.Join(c.authors, " and "))
+	field("publisher", c.publisher)
+	if parts := c.dateParts(); len(parts) > 0 {
+		fmt.Fprintf(b.w, "  year = {%04d},\n", parts[0])
+		if len(parts) > 1 && parts[1] >= 1 && parts[1] <= 12 {
+			fmt.Fprintf(b.w, "  month = %s,\n", bibtexMonths[parts[1]-1])
+		}
+	}
+	field("isbn", c.isbn)
+	_, err := b.w.WriteString("}\n\n")
+	return err
+}
+
+func (b *bibtexCatalogWriter) key(c citation) string {
+	key := ""
+	if len(c.authors) > 0 {
+		family, _ := splitName(c.authors[0])
+		key = strings.Map(func(r rune) rune {
+			if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+				return unicode.ToLower(r)
+			}
+			return -1
+		}, family)
+	}
+	if parts := c.dateParts(); len(parts) > 0 {
+		key += strconv.Itoa(parts[0])
+	}
+	if key == "" {
+		key = "isbn" + c.isbn
+	}
+	return key
+}
+
+func (b *bibtexCatalogWriter) Close() error {
+	return b.w.Flush()
+}
+
+// RIS
+
+type risCatalogWriter struct {
+	w *bufio.Writer
+}
+
+func (r *risCatalogWriter) Write(entry *domain.CatalogEntry) error {
+	c := newCitation(entry)
+
+	tag := func(name, value string) {
+		if value = strings.TrimSpace(value); value != "" {
+			fmt.Fprintf(r.w, "%s  - %s\r\n", name, value)
+		}
+	}
+	tag("TY", "BOOK")
+	tag("ID", c.id)
+	tag("TI", c.title)
+	for _, author := range c.authors {
+		tag("AU", author)
+	}
+	tag("PB", c.publisher)
+	if parts := c.dateParts(); len(parts) > 0 {
+		tag("PY", fmt.Sprintf("%04d", parts[0]))
+		// DA is YYYY/MM/DD/ with unknown parts left empty
+		date := make([]string, 3)
+		for i, part := range parts {
+			date[i] = fmt.Sprintf("%02d", part)
+		}
+		date[0] = fmt.Sprintf("%04d", parts[0])
+		tag("DA", strings.Join(date, "/")+"/")
+	}
+	tag("SN", c.isbn)
+	_, err := r.w.WriteString("ER  - \r\n\r\n")
+	return err
+}
+
+func (r *risCatalogWriter) Close() error {
+	return r.w.Flush()
+}
+
+// CSL-JSON
+
+type cslName struct {
+	Family  string `json:"family,omitempty"`
+	Given   string `json:"given,omitempty"`
+	Literal string `json:"literal,omitempty"`
+}
+
+type cslDate struct {
+	DateParts [][]int `json:"date-parts"`
+}
+
+type cslItem struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Title     string    `json:"title"`
+	Author    []cslName `json:"author,omitempty"`
+	Publisher string    `json:"publisher,omitempty"`
+	Issued    *cslDate  `json:"issued,omitempty"`
+	ISBN      string    `json:"ISBN,omitempty"`
+}
+
+func newCSLItem(entry *domain.CatalogEntry) cslItem {
+	c := newCitation(entry)
+	item := cslItem{
+		ID:        c.id,
+		Type:      "book",
+		Title:     c.title,
+		Publisher: c.publisher,
+		ISBN:      c.isbn,
+	}
+	for _, author := range c.authors {
+		family, given := splitName(author)
+		if given == "" {
+			item.Author = append(item.Author, cslName{Literal: author})
+		} else {
+			item.Author = append(item.Author, cslName{Family: family, Given: given})
+		}
+	}
+	if parts := c.dateParts(); len(parts) > 0 {
+		item.Issued = &cslDate{DateParts: [][]int{parts}}
+	}
+	return item
+}
+
+// schema.org JSON-LD
+
+const schemaContext = "https://schema.org"
+
+type schemaThing struct {
+	Type string `json:"@type"`
+	Name string `json:"name"`
+}
+
+type schemaBook struct {
+	Context       string        `json:"@context,omitempty"`
+	Type          string        `json:"@type"`
+	ID            string        `json:"@id,omitempty"`
+	Name          string        `json:"name"`
+	ISBN          string        `json:"isbn,omitempty"`
+	Author        []schemaThing `json:"author,omitempty"`
+	Publisher     *schemaThing  `json:"publisher,omitempty"`
+	DatePublished string        `json:"datePublished,omitempty"`
+}
+
+func newSchemaBook(entry *domain.CatalogEntry) schemaBook {
+	c := newCitation(entry)
+	book := schemaBook{
+		Type:          "Book",
+		ID:            "urn:isbn:" + c.isbn,
+		Name:          c.title,
+		ISBN:          c.isbn,
+		DatePublished: c.date,
+	}
+	if c.isbn == "" {
+		book.ID = ""
+	}
+	for _, author := range c.authors {
+		family, given := splitName(author)
+		name := author
+		if given != "" {
+			name = given + " " + family
+		}
+		book.Author = append(book.Author, schemaThing{Type: "Person", Name: name})
+	}
+	if c.publisher != "" {
+		book.Publisher = &schemaThing{Type: "Organization", Name: c.publisher}
+	}
+	return book
+}
+
+// jsonArrayWriter streams json values as an array between prefix and
+// suffix, which either open and close the array or an object around it
+type jsonArrayWriter struct {
+	w      *bufio.Writer
+	suffix string
+	item   func(entry *domain.CatalogEntry) any
+	count  int
+}
+
+func newJSONArrayWriter(w io.Writer, prefix, suffix string, item func(entry *domain.CatalogEntry) any) (*jsonArrayWriter, error) {
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString(prefix); err != nil {
+		return nil, err
+	}
+	return &jsonArrayWriter{w: bw, suffix: suffix, item: item}, nil
+}
+
+func (j *jsonArrayWriter) Write(entry *domain.CatalogEntry) error {
+	data, err := json.Marshal(j.item(entry))
+	if err != nil {
+		return err
+	}
+	if j.count > 0 {
+		j.w.WriteString(",")
+	}
+	j.count++
+	j.w.WriteString("\n  ")
+	_, err = j.w.Write(data)
+	return err
+}
+
+func (j *jsonArrayWriter) Close() error {
+	if _, err := j.w.WriteString("\n" + j.suffix + "\n"); err != nil {
+		return err
+	}
+	return j.w.Flush()
+}
+
+// encodeCitation encodes a single book. The json formats give one object
+// rather than a list.
+func encodeCitation(entry *domain.CatalogEntry, format Format) ([]byte, error) {
+	switch format {
+	case CSLJSON:
+		return json.MarshalIndent(newCSLItem(entry), "", "  ")
+	case JSONLD:
+		book := newSchemaBook(entry)
+		book.Context = schemaContext
+		return json.MarshalIndent(book, "", "  ")
+	}
+
+	var buf strings.Builder
+	writer, err := NewCatalogWriter(&buf, format, Options{})
+	if err != nil {
+		return nil, err
+	}
+	if err := writer.Write(entry); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return []byte(strings.TrimRight(buf.String(), "\r\n") + "\n"), nil
+}
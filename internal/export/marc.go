@@ -0,0 +1,72 @@
+package export
+
+import (
+	"github.com/gracchi-stdio/barf/internal/domain"
+	"github.com/gracchi-stdio/barf/pkg/bookfetcher"
+	"github.com/gracchi-stdio/barf/pkg/marc"
+	"strings"
+)
+
+// BookInfo converts a stored book to the richer provider form. Authors
+// stored as a "; " separated list are split again.
+func BookInfo(book *domain.Book) *bookfetcher.BookInfo {
+	info := &bookfetcher.BookInfo{
+		Title:           book.Title,
+		ISBN:            book.ISBN,
+		ISBN13:          book.ISBN,
+		Publisher:       book.Publisher,
+		PublicationDate: book.PublicationDate,
+		ProviderID:      book.ID.String(),
+	}
+	for _, author := range strings.Split(book.Author, ";") {
+		if author = strings.TrimSpace(author); author != "" {
+			info.Authors = append(info.Authors, author)
+		}
+	}
+	return info
+}
+
+// MARCRecord maps a stored book to a MARC 21 record; its id becomes the
+// control number
+func MARCRecord(book *domain.Book, mapping marc.Mapping) *marc.Record {
+	return mapping.Record(BookInfo(book))
+}
+
+func entryBook(entry *domain.CatalogEntry) *domain.Book {
+	return &domain.Book{
+		ID:              entry.ID,
+		Title:           entry.Title,
+		ISBN:            entry.ISBN,
+		Author:          entry.Author,
+		Publisher:       entry.Publisher,
+		PublicationDate: entry.PublicationDate,
+		CreatedAt:       entry.CreatedAt,
+		UpdatedAt:       entry.UpdatedAt,
+	}
+}
+
+type marcCatalogWriter struct {
+	w       *marc.Writer
+	mapping marc.Mapping
+}
+
+func (m *marcCatalogWriter) Write(entry *domain.CatalogEntry) error {
+	return m.w.Write(MARCRecord(entryBook(entry), m.mapping))
+}
+
+func (m *marcCatalogWriter) Close() error {
+	return nil
+}
+
+type marcXMLCatalogWriter struct {
+	w       *marc.XMLWriter
+	mapping marc.Mapping
+}
+
+func (m *marcXMLCatalogWriter) Write(entry *domain.CatalogEntry) error {
+	return m.w.Write(MARCRecord(entryBook(entry), m.mapping))
+}
+
+func (m *marcXMLCatalogWriter) Close() error {
+	return m.w.Close()
+}
@@ -0,0 +1,95 @@
+package export
+
+import (
+	"github.com/gracchi-stdio/barf/internal/domain"
+	"github.com/gracchi-stdio/barf/pkg/onix"
+	"strconv"
+	"strings"
+)
+
+type onixCatalogWriter struct {
+	enc  *onix.Encoder
+	opts Options
+}
+
+func (o *onixCatalogWriter) Write(entry *domain.CatalogEntry) error {
+	return o.enc.Encode(Product(entry, o.opts))
+}
+
+func (o *onixCatalogWriter) Close() error {
+	return o.enc.Close()
+}
+
+// Product maps a catalog entry to an ONIX product. Authors stored as a "; "
+// separated list become one contributor each; the price is written as a
+// retail price including tax.
+func Product(entry *domain.CatalogEntry, opts Options) *onix.Product {
+	product := &onix.Product{
+		RecordReference:  entry.ID.String(),
+		NotificationType: onix.NotificationConfirmed,
+		ProductIdentifiers: []onix.ProductIdentifier{
+			{ProductIDType: onix.IDTypeProprietary, IDValue: entry.ID.String()},
+			{ProductIDType: onix.IDTypeISBN13, IDValue: entry.ISBN},
+			{ProductIDType: onix.IDTypeGTIN13, IDValue: entry.ISBN},
+		},
+		DescriptiveDetail: onix.DescriptiveDetail{
+			ProductComposition: "00", // single item
+			ProductForm:        "BA", // book
+			TitleDetails: []onix.TitleDetail{{
+				TitleType: onix.TitleTypeDistinctive,
+				TitleElements: []onix.TitleElement{{
+					TitleElementLevel: onix.TitleElementLevelProduct,
+					TitleText:         entry.Title,
+				}},
+			}},
+		},
+	}
+
+	sequence := 0
+	for _, name := range strings.Split(entry.Author, ";") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		sequence++
+		product.DescriptiveDetail.Contributors = append(product.DescriptiveDetail.Contributors, onix.Contributor{
+			SequenceNumber:   sequence,
+			ContributorRoles: []string{onix.RoleAuthor},
+			PersonName:       name,
+		})
+	}
+
+	if entry.Publisher != "" {
+		product.PublishingDetail.Publishers = []onix.Publisher{{
+			PublishingRole: onix.PublishingRolePublisher,
+			PublisherName:  entry.Publisher,
+		}}
+	}
+	if date, ok := onix.DateFromISO(entry.PublicationDate); ok {
+		product.PublishingDetail.PublishingDates = []onix.PublishingDate{{
+			PublishingDateRole: onix.PublishingDatePublication,
+			Date:               date,
+		}}
+	}
+
+	availability := onix.AvailabilityInStock
+	if entry.Quantity <= 0 {
+		availability = onix.AvailabilityOutOfStock
+	}
+	product.ProductSupply = []onix.ProductSupply{{
+		SupplyDetails: []onix.SupplyDetail{{
+			Supplier: onix.Supplier{
+				SupplierRole: "00", // unspecified
+				SupplierName: opts.OnixSender,
+			},
+			ProductAvailability: availability,
+			Stock:               []onix.Stock{{OnHand: strconv.Itoa(max(entry.Quantity, 0))}},
+			Prices: []onix.Price{{
+				PriceType:    onix.PriceTypeRRPIncludingTax,
+				PriceAmount:  strconv.FormatFloat(entry.Price, 'f', 2, 64),
+				CurrencyCode: opts.Currency,
+			}},
+		}},
+	}}
+
+	return product
+}
@@ -0,0 +1,150 @@
+package http
+
+import (
+	"github.com/gracchi-stdio/barf/internal/domain"
+	"github.com/gracchi-stdio/barf/internal/service"
+	domainErr "github.com/gracchi-stdio/barf/pkg/errors"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strings"
+)
+
+// HeaderAPIKey carries an api key; a key may also be sent as a bearer token
+const HeaderAPIKey = "X-API-Key"
+
+// protectedPrefix is the path prefix of the routes that need a principal
+const protectedPrefix = "/api/v1/"
+
+// publicPaths are the routes under protectedPrefix open to anyone
+var publicPaths = map[string]bool{
+	"/api/v1/auth/login":   true,
+	"/api/v1/auth/refresh": true,
+	"/api/v1/auth/logout":  true,
+}
+
+type AuthHandler struct {
+	AuthService *service.AuthService
+}
+
+func NewAuthHandler(authService *service.AuthService) *AuthHandler {
+	return &AuthHandler{
+		AuthService: authService,
+	}
+}
+
+func (h *AuthHandler) RegisterRoutes(e *echo.Echo) {
+	e.POST("/api/v1/auth/login", h.Login)
+	e.POST("/api/v1/auth/refresh", h.Refresh)
+	e.POST("/api/v1/auth/logout", h.Logout)
+	e.GET("/api/v1/auth/me", h.Me)
+}
+
+// Middleware authenticates requests to the api by api key or access token
+// and puts the principal on the request context. Requests outside the api,
+// such as the OPDS catalog and the health check, act as the anonymous
+// viewer.
+func (h *AuthHandler) Middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		path := c.Request().URL.Path
+		principal := domain.Anonymous
+		if strings.HasPrefix(path, protectedPrefix) && !publicPaths[path] {
+			var err error
+			if principal, err = h.authenticate(c); err != nil {
+				return err
+			}
+		}
+
+		req := c.Request()
+		c.SetRequest(req.WithContext(domain.WithPrincipal(req.Context(), principal)))
+		return next(c)
+	}
+}
+
+func (h *AuthHandler) authenticate(c echo.Context) (*domain.Principal, error) {
+	ctx := c.Request().Context()
+
+	if key := c.Request().Header.Get(HeaderAPIKey); key != "" {
+		return h.AuthService.AuthenticateAPIKey(ctx, key)
+	}
+
+	scheme, token, found := strings.Cut(c.Request().Header.Get(echo.HeaderAuthorization), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return nil, domainErr.ErrUnauthenticated
+	}
+	token = strings.TrimSpace(token)
+
+	// api keys have a recognizable form, anything else must be a jwt
+	if strings.HasPrefix(token, "barf_") {
+		return h.AuthService.AuthenticateAPIKey(ctx, token)
+	}
+	return h.AuthService.AuthenticateAccessToken(token)
+}
+
+type LoginRequest struct {
+	Email    string `json:"email" validate:"required,max=255"`
+	Password string `json:"password" validate:"required,max=1000"`
+}
+
+func (h *AuthHandler) Login(c echo.Context) error {
+	var req LoginRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	tokens, err := h.AuthService.Login(c.Request().Context(), req.Email, req.Password)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, tokens)
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+func (h *AuthHandler) Refresh(c echo.Context) error {
+	var req RefreshRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	tokens, err := h.AuthService.Refresh(c.Request().Context(), req.RefreshToken)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, tokens)
+}
+
+func (h *AuthHandler) Logout(c echo.Context) error {
+	var req RefreshRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.AuthService.Logout(c.Request().Context(), req.RefreshToken); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// MeResponse is the principal a request acts for and what its role allows
+type MeResponse struct {
+	*domain.Principal
+	Permissions []domain.Permission `json:"permissions"`
+}
+
+// Me returns the principal the request is authenticated as
+func (h *AuthHandler) Me(c echo.Context) error {
+	principal, ok := domain.PrincipalFrom(c.Request().Context())
+	if !ok {
+		return domainErr.ErrUnauthenticated
+	}
+
+	return c.JSON(http.StatusOK, MeResponse{
+		Principal:   principal,
+		Permissions: principal.Role.Permissions(),
+	})
+}
@@ -2,34 +2,44 @@ package http
 
 import (
 	"github.com/gracchi-stdio/barf/internal/domain"
+	"github.com/gracchi-stdio/barf/internal/export"
 	"github.com/gracchi-stdio/barf/internal/service"
+	domainErr "github.com/gracchi-stdio/barf/pkg/errors"
 	"github.com/labstack/echo/v4"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
 type BookHandler struct {
 	BookService *service.BookService
+	Options     export.Options
 }
 
-func NewBookHandler(bookService *service.BookService) *BookHandler {
+func NewBookHandler(bookService *service.BookService, opts export.Options) *BookHandler {
 	return &BookHandler{
 		BookService: bookService,
+		Options:     opts,
 	}
 }
 
+// citationFormats are the formats books and search results can be
+// negotiated in besides json
+var citationFormats = []export.Format{export.BibTeX, export.RIS, export.CSLJSON, export.JSONLD}
+
 type CreateBookRequest struct {
-	Title           string  `json:"title"`
-	ISBN            string  `json:"isbn"`
-	Author          string  `json:"author"`
-	Publisher       string  `json:"publisher"`
-	PublicationDate string  `json:"publication_date"`
-	InitialQuantity int     `json:"initial_quantity"`
-	Price           float64 `json:"price"`
+	Title           string  `json:"title" validate:"required,max=500"`
+	ISBN            string  `json:"isbn" validate:"required,isbn"`
+	Author          string  `json:"author" validate:"required,max=500"`
+	Publisher       string  `json:"publisher" validate:"max=255"`
+	PublicationDate string  `json:"publication_date" validate:"date"`
+	InitialQuantity int     `json:"initial_quantity" validate:"min=0"`
+	Price           float64 `json:"price" validate:"money"`
 }
 
 func (h *BookHandler) RegisterRoutes(e *echo.Echo) {
 	e.POST("/api/v1/books", h.CreateBook)
+	e.POST("/api/v1/books/from-isbn", h.CreateBookFromISBN)
 	e.GET("/api/v1/books/:id", h.GetBook)
 	e.GET("/api/v1/books", h.SearchBook)
 	e.GET("/api/v1/books/low-stock", h.GetLowStockBooks)
@@ -43,7 +53,7 @@ func (h *BookHandler) RegisterRoutes(e *echo.Echo) {
 
 func (h *BookHandler) CreateBook(c echo.Context) error {
 	var req CreateBookRequest
-	if err := c.Bind(&req); err != nil {
+	if err := bind(c, &req); err != nil {
 		return err
 	}
 
@@ -56,20 +66,64 @@ func (h *BookHandler) CreateBook(c echo.Context) error {
 	}
 
 	if err := h.BookService.CreateBook(c.Request().Context(), book, req.InitialQuantity, req.Price); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, book)
+}
+
+type CreateBookFromISBNRequest struct {
+	ISBN     string  `json:"isbn" validate:"required,isbn"`
+	Quantity int     `json:"quantity" validate:"min=0"`
+	Price    float64 `json:"price" validate:"money"`
+}
+
+func (h *BookHandler) CreateBookFromISBN(c echo.Context) error {
+	var req CreateBookFromISBNRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	book, created, err := h.BookService.CreateBookWithISBN(c.Request().Context(), req.ISBN, req.Quantity, req.Price)
+	if err != nil {
+		return err
 	}
 
+	if !created {
+		return c.JSON(http.StatusOK, book)
+	}
 	return c.JSON(http.StatusCreated, book)
 }
 
+type UpdateBookRequest struct {
+	Title           string `json:"title" validate:"required,max=500"`
+	ISBN            string `json:"isbn" validate:"required,isbn"`
+	Author          string `json:"author" validate:"required,max=500"`
+	Publisher       string `json:"publisher" validate:"max=255"`
+	PublicationDate string `json:"publication_date" validate:"date"`
+}
+
+// UpdateBook replaces the descriptive fields of the book with the id in the
+// path
 func (h *BookHandler) UpdateBook(c echo.Context) error {
-	var book domain.Book
-	if err := c.Bind(&book); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	var req UpdateBookRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	book, err := h.BookService.GetBookByID(ctx, c.Param("id"))
+	if err != nil {
+		return err
 	}
 
-	if err := h.BookService.UpdateBook(c.Request().Context(), &book); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	book.Title = req.Title
+	book.ISBN = req.ISBN
+	book.Author = req.Author
+	book.Publisher = req.Publisher
+	book.PublicationDate = req.PublicationDate
+	if err := h.BookService.UpdateBook(ctx, book); err != nil {
+		return err
 	}
 
 	return c.JSON(http.StatusOK, book)
@@ -79,39 +133,73 @@ func (h *BookHandler) DeleteBook(c echo.Context) error {
 	id := c.Param("id")
 
 	if err := h.BookService.DeleteBook(c.Request().Context(), id); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return err
 	}
 
 	return c.NoContent(http.StatusNoContent)
 }
 
+// GetBook returns a book as json, or in a citation format or as a MARC 21
+// record when one is asked for with format or the Accept header. The .marc
+// suffix on the id gives an ISO 2709 record, or MARCXML with format=xml or
+// the Accept header.
 func (h *BookHandler) GetBook(c echo.Context) error {
-	id := c.Param("id")
+	id, isMARC := strings.CutSuffix(c.Param("id"), ".marc")
+
+	var format export.Format
+	if isMARC {
+		format = export.MARC
+		if c.QueryParam("format") == "xml" || strings.Contains(c.Request().Header.Get(echo.HeaderAccept), "marcxml") {
+			format = export.MARCXML
+		}
+	} else {
+		negotiated, ok, err := negotiateFormat(c, append(citationFormats, export.MARC, export.MARCXML)...)
+		if err != nil {
+			return err
+		}
+		if ok {
+			format = negotiated
+		}
+	}
 
 	book, err := h.BookService.GetBookByID(c.Request().Context(), id)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		return err
 	}
 
-	return c.JSON(http.StatusOK, book)
+	if format == "" {
+		return c.JSON(http.StatusOK, book)
+	}
+
+	data, err := export.EncodeBook(book, format, h.Options)
+	if err != nil {
+		return err
+	}
+	return c.Blob(http.StatusOK, format.ContentType(), data)
 }
 
 func (h *BookHandler) SearchBook(c echo.Context) error {
 	query := c.QueryParam("q")
-	page, _ := strconv.Atoi(c.QueryParam("page"))
-	pageSize, _ := strconv.Atoi(c.QueryParam("page_size"))
-
-	if page < 1 {
-		page = 1
+	var paging PageQuery
+	if err := bindQuery(c, &paging); err != nil {
+		return err
 	}
+	page, pageSize := paging.pages(10)
 
-	if pageSize < 1 {
-		pageSize = 10
+	format, negotiated, err := negotiateFormat(c, citationFormats...)
+	if err != nil {
+		return err
 	}
 
 	books, total, err := h.BookService.SearchBook(c.Request().Context(), query, page, pageSize)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return err
+	}
+
+	// citation formats carry the page's books only; the total goes in a
+	// header instead
+	if negotiated {
+		return h.writeCitations(c, format, books, total)
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
@@ -121,20 +209,60 @@ func (h *BookHandler) SearchBook(c echo.Context) error {
 	})
 }
 
+func (h *BookHandler) writeCitations(c echo.Context, format export.Format, books []domain.Book, total int64) error {
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, format.ContentType())
+	res.Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
+	res.WriteHeader(http.StatusOK)
+
+	writer, err := export.NewCatalogWriter(res, format, h.Options)
+	if err != nil {
+		return err
+	}
+	for i := range books {
+		if err := writer.Write(export.BookEntry(&books[i])); err != nil {
+			return err
+		}
+	}
+	return writer.Close()
+}
+
+// UpdateInventoryRequest sells or restocks copies, sets the price, or both.
+// The copies are counted at location, a location code, or at the default
+// location. Reason and reference are recorded in the stock ledger with the
+// change.
 type UpdateInventoryRequest struct {
-	QuantityChange int `json:"quantity_change"`
+	QuantityChange int      `json:"quantity_change"`
+	Location       string   `json:"location" validate:"max=50"`
+	Reason         string   `json:"reason" validate:"oneof=received sold returned damaged adjustment"`
+	Reference      string   `json:"reference" validate:"max=255"`
+	Price          *float64 `json:"price" validate:"money"`
 }
 
 func (h *BookHandler) UpdateInventory(c echo.Context) error {
 	id := c.Param("id")
 
 	var req UpdateInventoryRequest
-	if err := c.Bind(&req); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+	if req.QuantityChange == 0 && req.Price == nil {
+		return domainErr.Validation("request is invalid", domainErr.FieldError{
+			Field:   "quantity_change",
+			Code:    "required",
+			Message: "is required unless price is given",
+		})
 	}
 
-	if err := h.BookService.UpdateInventory(c.Request().Context(), id, req.QuantityChange); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	update := service.InventoryUpdate{
+		QuantityChange: req.QuantityChange,
+		Location:       req.Location,
+		Reason:         domain.MovementReason(req.Reason),
+		Reference:      req.Reference,
+		Price:          req.Price,
+	}
+	if err := h.BookService.UpdateInventory(c.Request().Context(), id, update); err != nil {
+		return err
 	}
 
 	return c.NoContent(http.StatusNoContent)
@@ -144,21 +272,29 @@ func (h *BookHandler) GetInventory(c echo.Context) error {
 	id := c.Param("id")
 	inventory, err := h.BookService.GetInventory(c.Request().Context(), id)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		return err
 	}
 
 	return c.JSON(http.StatusOK, inventory)
 }
 
+// LowStockQuery selects books with at most threshold copies in total or,
+// given a location code, at that location
+type LowStockQuery struct {
+	Threshold int    `query:"threshold" validate:"min=1"`
+	Location  string `query:"location" validate:"max=50"`
+}
+
 func (h *BookHandler) GetLowStockBooks(c echo.Context) error {
-	threshold, _ := strconv.Atoi(c.QueryParam("threshold"))
-	if threshold < 1 {
-		threshold = 1
+	var q LowStockQuery
+	if err := bindQuery(c, &q); err != nil {
+		return err
 	}
-	books, err := h.BookService.GetLowStockBooks(c.Request().Context(), threshold)
+	threshold := max(q.Threshold, 1)
+	books, err := h.BookService.GetLowStockBooks(c.Request().Context(), threshold, q.Location)
 
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return err
 	}
 
 	return c.JSON(http.StatusOK, books)
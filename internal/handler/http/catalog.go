@@ -0,0 +1,75 @@
+package http
+
+import (
+	"github.com/gracchi-stdio/barf/internal/service"
+	"github.com/gracchi-stdio/barf/pkg/bookfetcher"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+// CatalogHandler exposes provider lookups and searches without saving results
+type CatalogHandler struct {
+	BookService *service.BookService
+}
+
+func NewCatalogHandler(bookService *service.BookService) *CatalogHandler {
+	return &CatalogHandler{
+		BookService: bookService,
+	}
+}
+
+func (h *CatalogHandler) RegisterRoutes(e *echo.Echo) {
+	e.GET("/api/v1/catalog/lookup/:isbn", h.LookupBook)
+	e.POST("/api/v1/catalog/lookup/:isbn/refresh", h.RefreshBook)
+	e.GET("/api/v1/catalog/search", h.SearchCatalog)
+}
+
+func (h *CatalogHandler) LookupBook(c echo.Context) error {
+	info, err := h.BookService.FetchBookDetails(c.Request().Context(), c.Param("isbn"), c.QueryParam("provider"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, info)
+}
+
+func (h *CatalogHandler) RefreshBook(c echo.Context) error {
+	info, err := h.BookService.RefreshBookDetails(c.Request().Context(), c.Param("isbn"), c.QueryParam("provider"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, info)
+}
+
+// CatalogSearchQuery are the parameters of a provider search. Providers
+// return at most 40 results at a time.
+type CatalogSearchQuery struct {
+	Query    string `query:"q" validate:"required,max=500"`
+	Page     int    `query:"page" validate:"min=1"`
+	PageSize int    `query:"page_size" validate:"min=1,max=40"`
+	Language string `query:"lang" validate:"max=8"`
+	OrderBy  string `query:"order_by" validate:"oneof=relevance newest"`
+	Provider string `query:"provider"`
+}
+
+func (h *CatalogHandler) SearchCatalog(c echo.Context) error {
+	var q CatalogSearchQuery
+	if err := bindQuery(c, &q); err != nil {
+		return err
+	}
+	page, pageSize := PageQuery{Page: q.Page, PageSize: q.PageSize}.pages(10)
+
+	result, err := h.BookService.SearchCatalog(c.Request().Context(), bookfetcher.SearchOptions{
+		Query:      q.Query,
+		MaxResults: pageSize,
+		StartIndex: (page - 1) * pageSize,
+		Language:   q.Language,
+		OrderBy:    q.OrderBy,
+	}, q.Provider)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
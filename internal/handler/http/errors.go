@@ -0,0 +1,153 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gracchi-stdio/barf/pkg/bookfetcher"
+	domainErr "github.com/gracchi-stdio/barf/pkg/errors"
+	"github.com/gracchi-stdio/barf/pkg/isbn"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"net/http"
+	"strings"
+)
+
+// MIMEApplicationProblemJSON is the media type of RFC 7807 problem details
+const MIMEApplicationProblemJSON = "application/problem+json"
+
+// problemTypePrefix prefixes the code of a problem to form its type URI
+const problemTypePrefix = "urn:barf:problem:"
+
+// Problem is an RFC 7807 problem details document. Code is the stable error
+// code, also found at the end of Type; Errors lists the fields at fault for
+// validation problems.
+type Problem struct {
+	Type     string                 `json:"type"`
+	Title    string                 `json:"title"`
+	Status   int                    `json:"status"`
+	Detail   string                 `json:"detail,omitempty"`
+	Instance string                 `json:"instance,omitempty"`
+	Code     string                 `json:"code"`
+	Errors   []domainErr.FieldError `json:"errors,omitempty"`
+}
+
+// kindStatus is the http status of each kind of domain error
+var kindStatus = map[domainErr.Kind]int{
+	domainErr.KindInternal:            http.StatusInternalServerError,
+	domainErr.KindNotFound:            http.StatusNotFound,
+	domainErr.KindConflict:            http.StatusConflict,
+	domainErr.KindValidation:          http.StatusBadRequest,
+	domainErr.KindInsufficientStock:   http.StatusConflict,
+	domainErr.KindUpstreamUnavailable: http.StatusServiceUnavailable,
+	domainErr.KindRateLimited:         http.StatusTooManyRequests,
+	domainErr.KindUnauthenticated:     http.StatusUnauthorized,
+	domainErr.KindForbidden:           http.StatusForbidden,
+}
+
+// ErrorHandler is the echo HTTPErrorHandler. It answers every error with
+// problem+json: domain errors by their kind, code and message, echo errors
+// by their status, and anything else as an internal error. Causes wrapped
+// in an error are logged but not sent, since they may quote upstream urls
+// or database errors.
+func ErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		log.Error().Err(err).Str("uri", c.Request().RequestURI).Msg("error after response was sent")
+		return
+	}
+
+	problem := NewProblem(err)
+	problem.Instance = c.Request().URL.Path
+	switch {
+	case problem.Status >= http.StatusInternalServerError:
+		log.Error().Err(err).Str("code", problem.Code).Str("uri", c.Request().RequestURI).Msg("request failed")
+	case err.Error() != problem.Detail:
+		log.Info().Err(err).Str("code", problem.Code).Str("uri", c.Request().RequestURI).Msg("request rejected")
+	}
+
+	if problem.Status == http.StatusUnauthorized {
+		c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer realm="barf"`)
+	}
+	c.Response().Header().Set(echo.HeaderContentType, MIMEApplicationProblemJSON)
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(problem.Status)
+	} else {
+		err = c.JSON(problem.Status, problem)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("failed to write error response")
+	}
+}
+
+// NewProblem describes err as a problem
+func NewProblem(err error) *Problem {
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		if e, ok := domainErr.As(he.Internal); ok {
+			return domainProblem(e, he.Internal)
+		}
+		code := strings.ReplaceAll(strings.ToLower(http.StatusText(he.Code)), " ", "_")
+		return &Problem{
+			Type:   problemTypePrefix + code,
+			Title:  http.StatusText(he.Code),
+			Status: he.Code,
+			Detail: fmt.Sprint(he.Message),
+			Code:   code,
+		}
+	}
+
+	if e, ok := domainErr.As(classify(err)); ok {
+		return domainProblem(e, err)
+	}
+
+	return &Problem{
+		Type:   problemTypePrefix + "internal_error",
+		Title:  http.StatusText(http.StatusInternalServerError),
+		Status: http.StatusInternalServerError,
+		Detail: "the server could not complete the request",
+		Code:   "internal_error",
+	}
+}
+
+func domainProblem(e *domainErr.Error, err error) *Problem {
+	status := kindStatus[e.Kind]
+	return &Problem{
+		Type:   problemTypePrefix + e.Code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: e.Message,
+		Code:   e.Code,
+		Errors: e.Fields,
+	}
+}
+
+// classify wraps the errors of packages outside the domain in the domain
+// error they amount to. Domain errors and unknown errors are returned as is.
+func classify(err error) error {
+	if _, ok := domainErr.As(err); ok {
+		return err
+	}
+
+	switch {
+	case errors.Is(err, isbn.ErrInvalid), errors.Is(err, bookfetcher.ErrInvalidISBN):
+		return domainErr.Wrap(domainErr.KindValidation, "invalid_isbn", err)
+	// provider errors may quote request urls, so they get messages of their own
+	case errors.Is(err, bookfetcher.ErrBookNotFound):
+		return wrapCause(domainErr.KindNotFound, "book_not_found", "book not found", err)
+	case errors.Is(err, bookfetcher.ErrProviderNotFound):
+		return domainErr.Wrap(domainErr.KindNotFound, "provider_not_found", err)
+	case errors.Is(err, bookfetcher.ErrRateLimitExceeded):
+		return wrapCause(domainErr.KindRateLimited, "rate_limited", "book providers are rate limited, try again later", err)
+	case errors.Is(err, bookfetcher.ErrProviderError), errors.Is(err, bookfetcher.ErrNoProviders):
+		return wrapCause(domainErr.KindUpstreamUnavailable, "upstream_unavailable", "book providers are unavailable", err)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return domainErr.NotFound("record", err)
+	}
+	return err
+}
+
+// wrapCause returns a domain error with message that wraps err, keeping
+// err's text out of responses
+func wrapCause(kind domainErr.Kind, code, message string, err error) *domainErr.Error {
+	return &domainErr.Error{Kind: kind, Code: code, Message: message, Err: err}
+}
@@ -0,0 +1,68 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"github.com/gracchi-stdio/barf/pkg/bookfetcher"
+	domainErr "github.com/gracchi-stdio/barf/pkg/errors"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestNewProblemDetail(t *testing.T) {
+	urlErr := &url.Error{Op: "Get", URL: "https://books.example/volumes?key=secret", Err: context.DeadlineExceeded}
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+		detail string
+	}{
+		{
+			name:   "upstream error",
+			err:    fmt.Errorf("%w: failed to get book: %w", bookfetcher.ErrProviderError, urlErr),
+			status: http.StatusServiceUnavailable,
+			code:   "upstream_unavailable",
+			detail: "book providers are unavailable",
+		},
+		{
+			name:   "domain error wrapping a cause",
+			err:    &domainErr.Error{Kind: domainErr.KindConflict, Code: "book_exists", Message: "a book with this isbn already exists", Err: fmt.Errorf("duplicate key value violates unique constraint")},
+			status: http.StatusConflict,
+			code:   "book_exists",
+			detail: "a book with this isbn already exists",
+		},
+		{
+			name:   "domain error wrapped by the caller",
+			err:    fmt.Errorf("close session: %w", domainErr.ErrSessionClosed),
+			status: http.StatusConflict,
+			code:   "session_closed",
+			detail: "receiving session is closed",
+		},
+		{
+			name:   "domain error with detail",
+			err:    domainErr.ErrMalformedInput.Withf("record %d: unexpected EOF", 3),
+			status: http.StatusBadRequest,
+			code:   "malformed_input",
+			detail: "malformed input: record 3: unexpected EOF",
+		},
+		{
+			name:   "unknown error",
+			err:    fmt.Errorf("pq: connection refused"),
+			status: http.StatusInternalServerError,
+			code:   "internal_error",
+			detail: "the server could not complete the request",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problem := NewProblem(tt.err)
+			if problem.Status != tt.status || problem.Code != tt.code || problem.Detail != tt.detail {
+				t.Errorf("problem = %d %s %q, want %d %s %q",
+					problem.Status, problem.Code, problem.Detail, tt.status, tt.code, tt.detail)
+			}
+		})
+	}
+}
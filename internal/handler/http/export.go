@@ -0,0 +1,103 @@
+package http
+
+import (
+	"fmt"
+	"github.com/gracchi-stdio/barf/internal/domain"
+	"github.com/gracchi-stdio/barf/internal/export"
+	"github.com/gracchi-stdio/barf/internal/service"
+	domainErr "github.com/gracchi-stdio/barf/pkg/errors"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"time"
+)
+
+// exportFlushRows is how many rows are written between flushes of the
+// response
+const exportFlushRows = 500
+
+type ExportHandler struct {
+	BookService *service.BookService
+	Options     export.Options
+}
+
+func NewExportHandler(bookService *service.BookService, opts export.Options) *ExportHandler {
+	return &ExportHandler{
+		BookService: bookService,
+		Options:     opts,
+	}
+}
+
+func (h *ExportHandler) RegisterRoutes(e *echo.Echo) {
+	e.GET("/api/v1/exports/books", h.ExportBooks)
+}
+
+// ExportBooks streams the catalog, filtered by q like the book search, as
+// csv, jsonl, xlsx, an ONIX 3.0 feed, MARC 21 records (marc, marcxml) or
+// citations (bibtex, ris, csl-json, jsonld). The format comes from the format
+// parameter or the Accept header and is csv by default. A failure before the
+// first row gets an error response; one after it aborts the connection.
+func (h *ExportHandler) ExportBooks(c echo.Context) error {
+	format, ok, err := negotiateFormat(c, export.CSV, export.JSONL, export.XLSX, export.ONIX,
+		export.MARC, export.MARCXML, export.BibTeX, export.RIS, export.CSLJSON, export.JSONLD)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if c.QueryParam("format") != "" {
+			return domainErr.ErrUnsupportedFormat.Withf("json, use jsonl")
+		}
+		format = export.CSV
+	}
+
+	res := c.Response()
+
+	// the status is sent with the first row, so failing to authorize or to
+	// open the cursor still gets an error response
+	var writer export.CatalogWriter
+	open := func() error {
+		res.Header().Set(echo.HeaderContentType, format.ContentType())
+		res.Header().Set(echo.HeaderContentDisposition,
+			fmt.Sprintf(`attachment; filename="books-%s.%s"`, time.Now().Format("20060102"), format.Extension()))
+		res.WriteHeader(http.StatusOK)
+
+		var err error
+		writer, err = export.NewCatalogWriter(res, format, h.Options)
+		return err
+	}
+
+	rows := 0
+	err = h.BookService.ExportCatalog(c.Request().Context(), c.QueryParam("q"), func(entry *domain.CatalogEntry) error {
+		if writer == nil {
+			if err := open(); err != nil {
+				return err
+			}
+		}
+		if err := writer.Write(entry); err != nil {
+			return err
+		}
+		if rows++; rows%exportFlushRows == 0 {
+			res.Flush()
+		}
+		return nil
+	})
+	if err != nil && !res.Committed {
+		return err
+	}
+	if err == nil && writer == nil {
+		err = open()
+	}
+	if err == nil {
+		err = writer.Close()
+	}
+
+	// the status is already sent, so abort the connection rather than end
+	// the body cleanly and have the client take a truncated export as whole
+	if err != nil {
+		log.Error().Err(err).Int("rows", rows).Msg("catalog export failed")
+		panic(http.ErrAbortHandler)
+	}
+
+	log.Info().Str("format", string(format)).Int("rows", rows).Msg("catalog exported")
+	return nil
+}
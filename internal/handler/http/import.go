@@ -0,0 +1,114 @@
+package http
+
+import (
+	"errors"
+	"github.com/gracchi-stdio/barf/internal/service"
+	"github.com/gracchi-stdio/barf/pkg/bookfetcher"
+	domainErr "github.com/gracchi-stdio/barf/pkg/errors"
+	"github.com/labstack/echo/v4"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+type ImportHandler struct {
+	ImportService *service.ImportService
+}
+
+func NewImportHandler(importService *service.ImportService) *ImportHandler {
+	return &ImportHandler{
+		ImportService: importService,
+	}
+}
+
+func (h *ImportHandler) RegisterRoutes(e *echo.Echo) {
+	e.POST("/api/v1/imports", h.Import)
+}
+
+// ImportQuery are the options of an import besides its format
+type ImportQuery struct {
+	Mode     string `query:"mode" validate:"oneof=set add"`
+	Enrich   bool   `query:"enrich"`
+	Provider string `query:"provider"`
+	Currency string `query:"currency" validate:"min=3,max=3"`
+	Location string `query:"location" validate:"max=50"`
+}
+
+// Import loads a csv, json lines, ONIX 3.0 or MARC 21 file, sent either as the "file" field of a
+// multipart form or as the raw request body. The format is taken from the
+// format query parameter, the file name or the content type.
+func (h *ImportHandler) Import(c echo.Context) error {
+	var (
+		body     io.Reader = c.Request().Body
+		filename string
+	)
+
+	if file, err := c.FormFile("file"); err == nil {
+		src, err := file.Open()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		defer src.Close()
+
+		body = src
+		filename = file.Filename
+	}
+
+	format, err := importFormat(c, filename)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	var q ImportQuery
+	if err := bindQuery(c, &q); err != nil {
+		return err
+	}
+
+	opts := service.ImportOptions{
+		Format:   format,
+		Mode:     service.ImportMode(q.Mode),
+		Enrich:   q.Enrich,
+		Provider: q.Provider,
+		Currency: q.Currency,
+		Location: q.Location,
+	}
+
+	report, err := h.ImportService.Import(c.Request().Context(), body, opts)
+	if err != nil {
+		// the provider comes from the request, so an unknown one is bad input
+		if errors.Is(err, bookfetcher.ErrProviderNotFound) {
+			return domainErr.Wrap(domainErr.KindValidation, "unknown_provider", err)
+		}
+		return err
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
+
+func importFormat(c echo.Context, filename string) (service.ImportFormat, error) {
+	if format := c.QueryParam("format"); format != "" {
+		return service.ParseImportFormat(format)
+	}
+
+	if ext := filepath.Ext(filename); ext != "" {
+		return service.ParseImportFormat(ext)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	switch {
+	case mediaType == "text/csv":
+		return service.ImportCSV, nil
+	case mediaType == "application/x-ndjson", strings.Contains(mediaType, "jsonl"), strings.Contains(mediaType, "jsonlines"):
+		return service.ImportJSONL, nil
+	case mediaType == "application/xml", mediaType == "text/xml":
+		return service.ImportONIX, nil
+	case mediaType == "application/marc":
+		return service.ImportMARC, nil
+	case mediaType == "application/marcxml+xml":
+		return service.ImportMARCXML, nil
+	}
+
+	return "", errors.New("format is required: set the format query parameter to csv, jsonl, onix, marc or marcxml")
+}
@@ -0,0 +1,78 @@
+package http
+
+import (
+	"github.com/gracchi-stdio/barf/internal/export"
+	domainErr "github.com/gracchi-stdio/barf/pkg/errors"
+	"github.com/labstack/echo/v4"
+	"mime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// negotiateFormat returns the format a request asks for among allowed, from
+// the format query parameter or else the Accept header. ok is false when the
+// request has no preference the handler's default can't satisfy: no format,
+// format=json, or an Accept header that takes json or anything.
+func negotiateFormat(c echo.Context, allowed ...export.Format) (format export.Format, ok bool, err error) {
+	if name := c.QueryParam("format"); name != "" {
+		if strings.EqualFold(name, "json") {
+			return "", false, nil
+		}
+		format, err := export.ParseFormat(name)
+		if err != nil {
+			return "", false, err
+		}
+		if !slices.Contains(allowed, format) {
+			return "", false, domainErr.ErrUnsupportedFormat.Withf("%q is not available here", name)
+		}
+		return format, true, nil
+	}
+
+	for _, mediaType := range acceptedMediaTypes(c.Request().Header.Get(echo.HeaderAccept)) {
+		switch mediaType {
+		case "*/*", "application/*", echo.MIMEApplicationJSON:
+			return "", false, nil
+		}
+		if format, found := export.FormatForMediaType(mediaType); found && slices.Contains(allowed, format) {
+			return format, true, nil
+		}
+	}
+
+	// nothing acceptable was asked for; json is served rather than a 406
+	return "", false, nil
+}
+
+// acceptedMediaTypes returns the media types of an Accept header from the
+// most to the least preferred, leaving out those with q=0
+func acceptedMediaTypes(accept string) []string {
+	type accepted struct {
+		mediaType string
+		q         float64
+	}
+
+	var types []accepted
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, found := params["q"]; found {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			types = append(types, accepted{mediaType: mediaType, q: q})
+		}
+	}
+	sort.SliceStable(types, func(i, j int) bool { return types[i].q > types[j].q })
+
+	mediaTypes := make([]string, len(types))
+	for i, t := range types {
+		mediaTypes[i] = t.mediaType
+	}
+	return mediaTypes
+}
@@ -0,0 +1,392 @@
+package http
+
+import (
+	"github.com/gracchi-stdio/barf/internal/domain"
+	"github.com/gracchi-stdio/barf/internal/export"
+	"github.com/gracchi-stdio/barf/internal/service"
+	"github.com/gracchi-stdio/barf/pkg/opds"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// OPDS versions served under /opds/:version
+const (
+	opdsAtom = "v1"
+	opdsJSON = "v2"
+)
+
+// opdsFacetValues is how many values of each facet group a books feed
+// offers
+const opdsFacetValues = 10
+
+// OPDSHandler serves the inventory as a read only OPDS catalog, as OPDS 1.2
+// Atom feeds under /opds/v1 and OPDS 2.0 json under /opds/v2
+type OPDSHandler struct {
+	BookService *service.BookService
+	Title       string
+	PageSize    int
+}
+
+func NewOPDSHandler(bookService *service.BookService, title string, pageSize int) *OPDSHandler {
+	return &OPDSHandler{
+		BookService: bookService,
+		Title:       title,
+		PageSize:    pageSize,
+	}
+}
+
+func (h *OPDSHandler) RegisterRoutes(e *echo.Echo) {
+	e.GET("/opds/:version", h.Root)
+	e.GET("/opds/:version/books", h.Books)
+	e.GET("/opds/:version/authors", h.Authors)
+	e.GET("/opds/:version/publishers", h.Publishers)
+	e.GET("/opds/:version/opensearch.xml", h.OpenSearch)
+}
+
+// opdsRequest holds what the feeds of one request share
+type opdsRequest struct {
+	c       echo.Context
+	version string
+	// origin is the scheme and host the catalog was reached at, so links
+	// stay absolute behind any host name
+	origin string
+}
+
+func (r *opdsRequest) url(path string, query url.Values) string {
+	u := r.origin + "/opds/" + r.version + path
+	if encoded := query.Encode(); encoded != "" {
+		u += "?" + encoded
+	}
+	return u
+}
+
+func (r *opdsRequest) feedType(kind opds.Kind) string {
+	switch {
+	case r.version == opdsJSON:
+		return opds.JSONType
+	case kind == opds.Acquisition:
+		return opds.AcquisitionType
+	default:
+		return opds.NavigationType
+	}
+}
+
+// commonLinks are the self, start and search links of every feed
+func (r *opdsRequest) commonLinks(kind opds.Kind) []opds.Link {
+	links := []opds.Link{
+		{Rel: opds.RelSelf, Href: r.origin + r.c.Request().URL.RequestURI(), Type: r.feedType(kind)},
+		{Rel: opds.RelStart, Href: r.url("", nil), Type: r.feedType(opds.Navigation)},
+	}
+	if r.version == opdsJSON {
+		return append(links, opds.Link{Rel: opds.RelSearch, Href: r.url("/books", nil) + "{?q}", Type: opds.JSONType, Templated: true})
+	}
+	return append(links, opds.Link{Rel: opds.RelSearch, Href: r.url("/opensearch.xml", nil), Type: opds.OpenSearchType})
+}
+
+func (h *OPDSHandler) request(c echo.Context) (*opdsRequest, error) {
+	version := c.Param("version")
+	if version != opdsAtom && version != opdsJSON {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "unknown opds version "+version)
+	}
+	return &opdsRequest{c: c, version: version, origin: c.Scheme() + "://" + c.Request().Host}, nil
+}
+
+// paging reads page and page_size, defaulting to the configured page size
+func (h *OPDSHandler) paging(c echo.Context) (int, int, error) {
+	var q PageQuery
+	if err := bindQuery(c, &q); err != nil {
+		return 0, 0, err
+	}
+	page, pageSize := q.pages(h.PageSize)
+	return page, pageSize, nil
+}
+
+func (h *OPDSHandler) render(r *opdsRequest, feed *opds.Feed) error {
+	res := r.c.Response()
+	res.Header().Set(echo.HeaderContentType, r.feedType(feed.Kind))
+	res.WriteHeader(http.StatusOK)
+
+	if r.version == opdsJSON {
+		return feed.WriteJSON(res)
+	}
+	return feed.WriteAtom(res)
+}
+
+// Root is the start feed, linking to all books and to the author and
+// publisher listings
+func (h *OPDSHandler) Root(c echo.Context) error {
+	r, err := h.request(c)
+	if err != nil {
+		return err
+	}
+
+	acquisition := r.feedType(opds.Acquisition)
+	navigation := r.feedType(opds.Navigation)
+	return h.render(r, &opds.Feed{
+		ID:    r.url("", nil),
+		Title: h.Title,
+		Kind:  opds.Navigation,
+		Links: r.commonLinks(opds.Navigation),
+		Navigation: []opds.NavigationEntry{
+			{ID: r.url("/books", nil), Title: "All books", Summary: "Every book in the catalog, newest first", Href: r.url("/books", nil), Type: acquisition},
+			{ID: r.url("/authors", nil), Title: "Authors", Summary: "Books by author", Href: r.url("/authors", nil), Type: navigation},
+			{ID: r.url("/publishers", nil), Title: "Publishers", Summary: "Books by publisher", Href: r.url("/publishers", nil), Type: navigation},
+		},
+	})
+}
+
+// Books is the acquisition feed of the books matching q, narrowed by the
+// author and publisher facets
+func (h *OPDSHandler) Books(c echo.Context) error {
+	r, err := h.request(c)
+	if err != nil {
+		return err
+	}
+
+	query := c.QueryParam("q")
+	facets := domain.BookFacets{
+		Author:    c.QueryParam("author"),
+		Publisher: c.QueryParam("publisher"),
+	}
+	page, pageSize, err := h.paging(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	books, total, err := h.BookService.BrowseBooks(ctx, query, facets, page, pageSize)
+	if err != nil {
+		return err
+	}
+
+	title := h.Title
+	switch {
+	case facets.Author != "":
+		title = "Books by " + facets.Author
+	case facets.Publisher != "":
+		title = "Books published by " + facets.Publisher
+	}
+	if query != "" {
+		title += ": " + query
+	}
+
+	// every link keeps the current query and facets
+	params := url.Values{}
+	for name, value := range map[string]string{"q": query, "author": facets.Author, "publisher": facets.Publisher} {
+		if value != "" {
+			params.Set(name, value)
+		}
+	}
+	if c.QueryParam("page_size") != "" {
+		params.Set("page_size", strconv.Itoa(pageSize))
+	}
+
+	feed := &opds.Feed{
+		ID:    r.url("/books", params),
+		Title: title,
+		Kind:  opds.Acquisition,
+		Links: append(r.commonLinks(opds.Acquisition),
+			opds.Link{Rel: opds.RelUp, Href: r.url("", nil), Type: r.feedType(opds.Navigation)}),
+		Paging: &opds.Paging{
+			Page:         page,
+			ItemsPerPage: pageSize,
+			Total:        total,
+			Href: func(page int) string {
+				pageParams := cloneValues(params)
+				pageParams.Set("page", strconv.Itoa(page))
+				return r.url("/books", pageParams)
+			},
+		},
+	}
+
+	for _, field := range []string{domain.FacetAuthor, domain.FacetPublisher} {
+		group, err := h.facetGroup(r, field, query, facets, params)
+		if err != nil {
+			return err
+		}
+		feed.Facets = append(feed.Facets, group)
+	}
+
+	for i := range books {
+		feed.Publications = append(feed.Publications, h.publication(r, &books[i]))
+		if books[i].UpdatedAt.After(feed.Updated) {
+			feed.Updated = books[i].UpdatedAt
+		}
+	}
+
+	return h.render(r, feed)
+}
+
+// facetGroup offers the most common values of field among the books the feed
+// would list without its own facet, plus "All" to drop the facet
+func (h *OPDSHandler) facetGroup(r *opdsRequest, field, query string, facets domain.BookFacets, params url.Values) (opds.FacetGroup, error) {
+	others := facets
+	title, active := "Author", facets.Author
+	others.Author = ""
+	if field == domain.FacetPublisher {
+		title, active = "Publisher", facets.Publisher
+		others = facets
+		others.Publisher = ""
+	}
+
+	counts, _, err := h.BookService.FacetCounts(r.c.Request().Context(), field, query, others, 1, opdsFacetValues)
+	if err != nil {
+		return opds.FacetGroup{}, err
+	}
+
+	// the active value is offered even when it isn't among the most common
+	found := active == ""
+	for _, count := range counts {
+		found = found || count.Value == active
+	}
+	if !found {
+		counts = append(counts, domain.FacetCount{Value: active})
+	}
+
+	href := func(value string) string {
+		facetParams := cloneValues(params)
+		facetParams.Del("page")
+		if value == "" {
+			facetParams.Del(field)
+		} else {
+			facetParams.Set(field, value)
+		}
+		return r.url("/books", facetParams)
+	}
+
+	group := opds.FacetGroup{
+		Title:  title,
+		Facets: []opds.Facet{{Title: "All", Href: href(""), Active: active == ""}},
+	}
+	for _, count := range counts {
+		group.Facets = append(group.Facets, opds.Facet{
+			Title:  count.Value,
+			Href:   href(count.Value),
+			Count:  count.Count,
+			Active: count.Value == active,
+		})
+	}
+	return group, nil
+}
+
+// publication describes a book with links to its json, JSON-LD and MARCXML
+// representations
+func (h *OPDSHandler) publication(r *opdsRequest, book *domain.Book) opds.Publication {
+	bookURL := r.origin + "/api/v1/books/" + book.ID.String()
+	pub := opds.Publication{
+		ID:        "urn:uuid:" + book.ID.String(),
+		Title:     book.Title,
+		Publisher: book.Publisher,
+		Issued:    book.PublicationDate,
+		Updated:   book.UpdatedAt,
+		Links: []opds.Link{
+			{Rel: opds.RelAlternate, Href: bookURL, Type: echo.MIMEApplicationJSON, Title: "Book"},
+			{Rel: opds.RelAlternate, Href: bookURL + "?format=jsonld", Type: export.JSONLD.ContentType(), Title: "schema.org"},
+			{Rel: opds.RelAlternate, Href: bookURL + ".marc?format=xml", Type: export.MARCXML.ContentType(), Title: "MARCXML"},
+		},
+	}
+	if book.ISBN != "" {
+		pub.Identifier = "urn:isbn:" + book.ISBN
+	}
+	for _, author := range strings.Split(book.Author, ";") {
+		if author = strings.TrimSpace(author); author != "" {
+			pub.Authors = append(pub.Authors, author)
+		}
+	}
+	return pub
+}
+
+// Authors is the navigation feed of authors, most books first
+func (h *OPDSHandler) Authors(c echo.Context) error {
+	return h.facetFeed(c, domain.FacetAuthor, "Authors", "/authors")
+}
+
+// Publishers is the navigation feed of publishers, most books first
+func (h *OPDSHandler) Publishers(c echo.Context) error {
+	return h.facetFeed(c, domain.FacetPublisher, "Publishers", "/publishers")
+}
+
+func (h *OPDSHandler) facetFeed(c echo.Context, field, title, path string) error {
+	r, err := h.request(c)
+	if err != nil {
+		return err
+	}
+
+	page, pageSize, err := h.paging(c)
+	if err != nil {
+		return err
+	}
+	counts, total, err := h.BookService.FacetCounts(c.Request().Context(), field, "", domain.BookFacets{}, page, pageSize)
+	if err != nil {
+		return err
+	}
+
+	params := url.Values{}
+	if c.QueryParam("page_size") != "" {
+		params.Set("page_size", strconv.Itoa(pageSize))
+	}
+
+	feed := &opds.Feed{
+		ID:    r.url(path, nil),
+		Title: title,
+		Kind:  opds.Navigation,
+		Links: append(r.commonLinks(opds.Navigation),
+			opds.Link{Rel: opds.RelUp, Href: r.url("", nil), Type: r.feedType(opds.Navigation)}),
+		Paging: &opds.Paging{
+			Page:         page,
+			ItemsPerPage: pageSize,
+			Total:        total,
+			Href: func(page int) string {
+				pageParams := cloneValues(params)
+				pageParams.Set("page", strconv.Itoa(page))
+				return r.url(path, pageParams)
+			},
+		},
+	}
+
+	for _, count := range counts {
+		href := r.url("/books", url.Values{field: {count.Value}})
+		feed.Navigation = append(feed.Navigation, opds.NavigationEntry{
+			ID:    href,
+			Title: count.Value,
+			Href:  href,
+			Type:  r.feedType(opds.Acquisition),
+			Count: count.Count,
+		})
+	}
+
+	return h.render(r, feed)
+}
+
+// OpenSearch is the OpenSearch description of the books feed, whose q
+// parameter takes the search terms
+func (h *OPDSHandler) OpenSearch(c echo.Context) error {
+	r, err := h.request(c)
+	if err != nil {
+		return err
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, opds.OpenSearchType)
+	res.Header().Set(echo.HeaderCacheControl, "public, max-age=86400")
+	res.WriteHeader(http.StatusOK)
+
+	description := &opds.OpenSearchDescription{
+		ShortName:   h.Title,
+		Description: "Search " + h.Title + " by title, author or ISBN",
+		Template:    r.url("/books", nil) + "?q={searchTerms}",
+		Type:        r.feedType(opds.Acquisition),
+	}
+	return description.Write(res)
+}
+
+func cloneValues(values url.Values) url.Values {
+	clone := make(url.Values, len(values))
+	for name, v := range values {
+		clone[name] = append([]string(nil), v...)
+	}
+	return clone
+}
@@ -0,0 +1,25 @@
+package http
+
+import (
+	"github.com/gracchi-stdio/barf/pkg/bookfetcher"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+type ProviderHandler struct {
+	Monitor *bookfetcher.HealthMonitor
+}
+
+func NewProviderHandler(monitor *bookfetcher.HealthMonitor) *ProviderHandler {
+	return &ProviderHandler{
+		Monitor: monitor,
+	}
+}
+
+func (h *ProviderHandler) RegisterRoutes(e *echo.Echo) {
+	e.GET("/api/v1/providers", h.ListProviders)
+}
+
+func (h *ProviderHandler) ListProviders(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.Monitor.Status())
+}
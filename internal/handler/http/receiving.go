@@ -0,0 +1,121 @@
+package http
+
+import (
+	"github.com/gracchi-stdio/barf/internal/service"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+type ReceivingHandler struct {
+	ReceivingService *service.ReceivingService
+}
+
+func NewReceivingHandler(receivingService *service.ReceivingService) *ReceivingHandler {
+	return &ReceivingHandler{
+		ReceivingService: receivingService,
+	}
+}
+
+func (h *ReceivingHandler) RegisterRoutes(e *echo.Echo) {
+	e.POST("/api/v1/receiving", h.OpenSession)
+	e.GET("/api/v1/receiving/:id", h.GetSession)
+	e.POST("/api/v1/receiving/:id/scans", h.Scan)
+	e.PUT("/api/v1/receiving/:id/lines/:line_id", h.UpdateLine)
+	e.POST("/api/v1/receiving/:id/close", h.CloseSession)
+}
+
+// OpenSessionRequest opens a session receiving stock at location, a location
+// code, or at the default location
+type OpenSessionRequest struct {
+	Note     string `json:"note" validate:"max=1000"`
+	Location string `json:"location" validate:"max=50"`
+}
+
+func (h *ReceivingHandler) OpenSession(c echo.Context) error {
+	var req OpenSessionRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	session, err := h.ReceivingService.OpenSession(c.Request().Context(), req.Note, req.Location)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, session)
+}
+
+func (h *ReceivingHandler) GetSession(c echo.Context) error {
+	session, err := h.ReceivingService.GetSession(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, session)
+}
+
+// ScanRequest records one copy unless a quantity is given
+type ScanRequest struct {
+	Code     string `json:"code" validate:"required,isbn"`
+	Quantity *int   `json:"quantity" validate:"min=1"`
+}
+
+func (h *ReceivingHandler) Scan(c echo.Context) error {
+	var req ScanRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	quantity := 1
+	if req.Quantity != nil {
+		quantity = *req.Quantity
+	}
+
+	line, err := h.ReceivingService.Scan(c.Request().Context(), c.Param("id"), req.Code, quantity)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, line)
+}
+
+type UpdateLineRequest struct {
+	Quantity        *int     `json:"quantity" validate:"min=0"`
+	Title           *string  `json:"title" validate:"max=500"`
+	Author          *string  `json:"author" validate:"max=500"`
+	Publisher       *string  `json:"publisher" validate:"max=255"`
+	PublicationDate *string  `json:"publication_date" validate:"date"`
+	Price           *float64 `json:"price" validate:"money"`
+	Confirmed       *bool    `json:"confirmed"`
+}
+
+func (h *ReceivingHandler) UpdateLine(c echo.Context) error {
+	var req UpdateLineRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	line, err := h.ReceivingService.UpdateLine(c.Request().Context(), c.Param("id"), c.Param("line_id"), service.ReceivingLineUpdate{
+		Quantity:        req.Quantity,
+		Title:           req.Title,
+		Author:          req.Author,
+		Publisher:       req.Publisher,
+		PublicationDate: req.PublicationDate,
+		Price:           req.Price,
+		Confirmed:       req.Confirmed,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, line)
+}
+
+func (h *ReceivingHandler) CloseSession(c echo.Context) error {
+	summary, err := h.ReceivingService.CloseSession(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, summary)
+}
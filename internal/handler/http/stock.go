@@ -0,0 +1,141 @@
+package http
+
+import (
+	"github.com/gracchi-stdio/barf/internal/domain"
+	"github.com/gracchi-stdio/barf/internal/service"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+type StockHandler struct {
+	StockService *service.StockService
+}
+
+func NewStockHandler(stockService *service.StockService) *StockHandler {
+	return &StockHandler{
+		StockService: stockService,
+	}
+}
+
+func (h *StockHandler) RegisterRoutes(e *echo.Echo) {
+	e.GET("/api/v1/books/:id/movements", h.ListMovements)
+	e.POST("/api/v1/books/:id/transfers", h.Transfer)
+	e.PUT("/api/v1/books/:id/bin", h.SetBin)
+	e.GET("/api/v1/stock/drift", h.Drift)
+	e.GET("/api/v1/locations", h.ListLocations)
+	e.POST("/api/v1/locations", h.CreateLocation)
+}
+
+func (h *StockHandler) ListLocations(c echo.Context) error {
+	locations, err := h.StockService.ListLocations(c.Request().Context())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, locations)
+}
+
+type CreateLocationRequest struct {
+	Code string `json:"code" validate:"required,max=50"`
+	Name string `json:"name" validate:"required,max=255"`
+	Kind string `json:"kind" validate:"oneof=store warehouse"`
+}
+
+func (h *StockHandler) CreateLocation(c echo.Context) error {
+	var req CreateLocationRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	location := &domain.Location{
+		Code: req.Code,
+		Name: req.Name,
+		Kind: domain.LocationKind(req.Kind),
+	}
+	if err := h.StockService.CreateLocation(c.Request().Context(), location); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, location)
+}
+
+// TransferRequest moves copies between locations given by code; an empty
+// code is the default location
+type TransferRequest struct {
+	From      string `json:"from" validate:"max=50"`
+	To        string `json:"to" validate:"max=50"`
+	Quantity  int    `json:"quantity" validate:"required,min=1"`
+	Reference string `json:"reference" validate:"max=255"`
+}
+
+// Transfer moves copies of a book from one location to another
+func (h *StockHandler) Transfer(c echo.Context) error {
+	var req TransferRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	transfer, err := h.StockService.Transfer(c.Request().Context(), c.Param("id"), service.StockTransfer{
+		From:      req.From,
+		To:        req.To,
+		Quantity:  req.Quantity,
+		Reference: req.Reference,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, transfer)
+}
+
+// SetBinRequest files a book's copies at a location under a bin or shelf
+// code; an empty bin clears it
+type SetBinRequest struct {
+	Location string `json:"location" validate:"max=50"`
+	Bin      string `json:"bin" validate:"max=50"`
+}
+
+func (h *StockHandler) SetBin(c echo.Context) error {
+	var req SetBinRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	stock, err := h.StockService.SetBin(c.Request().Context(), c.Param("id"), req.Location, req.Bin)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, stock)
+}
+
+// ListMovements returns a page of a book's stock ledger, newest first
+func (h *StockHandler) ListMovements(c echo.Context) error {
+	var paging PageQuery
+	if err := bindQuery(c, &paging); err != nil {
+		return err
+	}
+	page, pageSize := paging.pages(50)
+
+	movements, total, err := h.StockService.ListMovements(c.Request().Context(), c.Param("id"), page, pageSize)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"movements": movements,
+		"total":     total,
+		"page":      page,
+	})
+}
+
+// Drift reconciles the stock now and lists the books whose quantity, in
+// total or at a location, differs from their ledger
+func (h *StockHandler) Drift(c echo.Context) error {
+	drift, err := h.StockService.Reconcile(c.Request().Context())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, drift)
+}
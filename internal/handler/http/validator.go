@@ -0,0 +1,54 @@
+package http
+
+import (
+	"github.com/gracchi-stdio/barf/pkg/validate"
+	"github.com/labstack/echo/v4"
+)
+
+// maxPageSize caps page_size on every listing
+const maxPageSize = 100
+
+// RequestValidator is the echo Validator. It checks the validate tags of
+// request structs and fails with a validation error listing the fields at
+// fault.
+type RequestValidator struct{}
+
+func (RequestValidator) Validate(i interface{}) error {
+	return validate.Struct(i)
+}
+
+// PageQuery is the paging of a listing. Zero values take the listing's
+// default; page_size is capped at maxPageSize.
+type PageQuery struct {
+	Page     int `query:"page" validate:"min=1"`
+	PageSize int `query:"page_size" validate:"min=1,max=100"`
+}
+
+// pages returns the page, or 1, and the page size, or defaultSize
+func (q PageQuery) pages(defaultSize int) (int, int) {
+	page, pageSize := q.Page, q.PageSize
+	if page == 0 {
+		page = 1
+	}
+	if pageSize == 0 {
+		pageSize = defaultSize
+	}
+	return page, min(pageSize, maxPageSize)
+}
+
+// bind binds the request to req as c.Bind does and validates it
+func bind(c echo.Context, req any) error {
+	if err := c.Bind(req); err != nil {
+		return err
+	}
+	return c.Validate(req)
+}
+
+// bindQuery binds only the query parameters to q, whatever the method, and
+// validates them
+func bindQuery(c echo.Context, q any) error {
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, q); err != nil {
+		return err
+	}
+	return c.Validate(q)
+}
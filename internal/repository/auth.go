@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/gracchi-stdio/barf/internal/domain"
+	domainErr "github.com/gracchi-stdio/barf/pkg/errors"
+	"gorm.io/gorm"
+	"time"
+)
+
+type userRepository struct {
+	db *gorm.DB
+}
+
+func NewUserRepository(db *gorm.DB) *userRepository {
+	return &userRepository{
+		db: db,
+	}
+}
+
+func (r *userRepository) Create(ctx context.Context, user *domain.User) error {
+	result := r.db.WithContext(ctx).Create(user)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func (r *userRepository) Update(ctx context.Context, user *domain.User) error {
+	result := r.db.WithContext(ctx).Save(user)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func (r *userRepository) GetByID(ctx context.Context, id string) (*domain.User, error) {
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, domainErr.InvalidID(id)
+	}
+
+	var user domain.User
+	result := r.db.WithContext(ctx).First(&user, userID)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, domainErr.NotFound("user", gorm.ErrRecordNotFound)
+		}
+		return nil, result.Error
+	}
+	return &user, nil
+}
+
+func (r *userRepository) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
+	var user domain.User
+	result := r.db.WithContext(ctx).Where("LOWER(email) = LOWER(?)", email).First(&user)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, domainErr.NotFound("user", gorm.ErrRecordNotFound)
+		}
+		return nil, result.Error
+	}
+	return &user, nil
+}
+
+type apiKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepository(db *gorm.DB) *apiKeyRepository {
+	return &apiKeyRepository{
+		db: db,
+	}
+}
+
+func (r *apiKeyRepository) Create(ctx context.Context, key *domain.APIKey) error {
+	result := r.db.WithContext(ctx).Create(key)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func (r *apiKeyRepository) GetByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error) {
+	var key domain.APIKey
+	result := r.db.WithContext(ctx).Where("prefix = ?", prefix).First(&key)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, domainErr.NotFound("api key", gorm.ErrRecordNotFound)
+		}
+		return nil, result.Error
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepository) List(ctx context.Context) ([]domain.APIKey, error) {
+	var keys []domain.APIKey
+	result := r.db.WithContext(ctx).Order("created_at ASC").Find(&keys)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return keys, nil
+}
+
+// Revoke marks a key revoked at at. Revoking a revoked key keeps its first
+// revocation time.
+func (r *apiKeyRepository) Revoke(ctx context.Context, id uuid.UUID, at time.Time) error {
+	result := r.db.WithContext(ctx).
+		Model(&domain.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", at)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+// Touch records the last use of a key without changing its updated_at
+func (r *apiKeyRepository) Touch(ctx context.Context, id uuid.UUID, at time.Time) error {
+	result := r.db.WithContext(ctx).
+		Model(&domain.APIKey{}).
+		Where("id = ?", id).
+		UpdateColumn("last_used_at", at)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+type refreshTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewRefreshTokenRepository(db *gorm.DB) *refreshTokenRepository {
+	return &refreshTokenRepository{
+		db: db,
+	}
+}
+
+func (r *refreshTokenRepository) Create(ctx context.Context, token *domain.RefreshToken) error {
+	result := r.db.WithContext(ctx).Omit("User").Create(token)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func (r *refreshTokenRepository) GetByHash(ctx context.Context, hash string) (*domain.RefreshToken, error) {
+	var token domain.RefreshToken
+	result := r.db.WithContext(ctx).Preload("User").Where("hash = ?", hash).First(&token)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, domainErr.NotFound("refresh token", gorm.ErrRecordNotFound)
+		}
+		return nil, result.Error
+	}
+	return &token, nil
+}
+
+// Revoke marks a token revoked at at. It reports false when the token was
+// already revoked, so of two requests racing to use a token only one wins.
+func (r *refreshTokenRepository) Revoke(ctx context.Context, id uuid.UUID, at time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&domain.RefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", at)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *refreshTokenRepository) RevokeFamily(ctx context.Context, familyID uuid.UUID, at time.Time) error {
+	result := r.db.WithContext(ctx).
+		Model(&domain.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", at)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
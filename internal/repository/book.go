@@ -2,12 +2,21 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"fmt"
 	"github.com/google/uuid"
 	"github.com/gracchi-stdio/barf/internal/domain"
+	domainErr "github.com/gracchi-stdio/barf/pkg/errors"
+	"github.com/gracchi-stdio/barf/pkg/isbn"
+	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
+	"strings"
 )
 
+// pgUniqueViolation is the postgres error code for a duplicate key
+const pgUniqueViolation = "23505"
+
 type bookRepository struct {
 	db *gorm.DB
 }
@@ -26,36 +35,51 @@ func (r *bookRepository) Create(ctx context.Context, tx *gorm.DB, book *domain.B
 
 	result := db.WithContext(ctx).Create(book)
 	if result.Error != nil {
+		// another transaction may have stored the isbn since it was looked up
+		var pgErr *pgconn.PgError
+		if errors.As(result.Error, &pgErr) && pgErr.Code == pgUniqueViolation {
+			return domainErr.ErrBookExists
+		}
 		return result.Error
 	}
 	return nil
 }
 
-func (r *bookRepository) Update(ctx context.Context, book *domain.Book) error {
-	result := r.db.WithContext(ctx).Save(book)
+func (r *bookRepository) Update(ctx context.Context, tx *gorm.DB, book *domain.Book) error {
+	db := r.db
+	if tx != nil {
+		db = tx
+	}
+
+	result := db.WithContext(ctx).Save(book)
 	if result.Error != nil {
 		return result.Error
 	}
 
 	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound
+		return domainErr.NotFound("book", gorm.ErrRecordNotFound)
 	}
 
 	return nil
 }
 
-func (r *bookRepository) Delete(ctx context.Context, id string) error {
+func (r *bookRepository) Delete(ctx context.Context, tx *gorm.DB, id string) error {
+	db := r.db
+	if tx != nil {
+		db = tx
+	}
+
 	bookID, err := uuid.Parse(id)
 	if err != nil {
-		return err
+		return domainErr.InvalidID(id)
 	}
-	result := r.db.WithContext(ctx).Delete(&domain.Book{}, bookID)
+	result := db.WithContext(ctx).Delete(&domain.Book{}, bookID)
 	if result.Error != nil {
 		return result.Error
 	}
 
 	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound
+		return domainErr.NotFound("book", gorm.ErrRecordNotFound)
 	}
 
 	return nil
@@ -64,28 +88,57 @@ func (r *bookRepository) Delete(ctx context.Context, id string) error {
 func (r *bookRepository) GetByID(ctx context.Context, id string) (*domain.Book, error) {
 	bookID, err := uuid.Parse(id)
 	if err != nil {
-		return nil, err
+		return nil, domainErr.InvalidID(id)
 	}
 	var book domain.Book
 	result := r.db.WithContext(ctx).First(&book, bookID)
 	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, domainErr.NotFound("book", gorm.ErrRecordNotFound)
+		}
 		return nil, result.Error
 	}
 	return &book, nil
 }
 
-func (r *bookRepository) GetByISBN(ctx context.Context, isbn string) (*domain.Book, error) {
+func (r *bookRepository) GetByISBN(ctx context.Context, code string) (*domain.Book, error) {
+	// books are stored by their normalized isbn-13
+	code, err := isbn.Normalize(code)
+	if err != nil {
+		return nil, err
+	}
+
 	var book domain.Book
-	result := r.db.WithContext(ctx).Where("isbn = ?", isbn).First(&book)
+	result := r.db.WithContext(ctx).Where("isbn = ?", code).First(&book)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			return nil, gorm.ErrRecordNotFound
+			return nil, domainErr.NotFound("book", gorm.ErrRecordNotFound)
 		}
 		return nil, result.Error
 	}
 	return &book, nil
 }
 
+// GetByISBNs returns the books stored under any of the normalized isbns.
+// Unknown isbns are skipped.
+func (r *bookRepository) GetByISBNs(ctx context.Context, tx *gorm.DB, isbns []string) ([]domain.Book, error) {
+	db := r.db
+	if tx != nil {
+		db = tx
+	}
+
+	var books []domain.Book
+	if len(isbns) == 0 {
+		return books, nil
+	}
+
+	result := db.WithContext(ctx).Where("isbn IN ?", isbns).Find(&books)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return books, nil
+}
+
 func (r *bookRepository) List(ctx context.Context, limit, offset int) ([]domain.Book, int64, error) {
 	var books []domain.Book
 	var count int64
@@ -106,9 +159,25 @@ func (r *bookRepository) Search(ctx context.Context, query string, offset, limit
 	var books []domain.Book
 	var count int64
 
-	searchQuery := "%" + query + "%"
-	baseQuery := r.db.WithContext(ctx).Where("title ILIKE ? OR author ILIKE ? OR isbn LIKE ?",
-		searchQuery, searchQuery, searchQuery)
+	baseQuery := r.db.WithContext(ctx).Scopes(searchScope(query, ""))
+
+	if err := baseQuery.Model(&domain.Book{}).Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+
+	result := baseQuery.Limit(limit).Offset(offset).Order("created_at DESC").Find(&books)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+	return books, count, nil
+}
+
+// SearchFaceted is Search narrowed to the books matching facets
+func (r *bookRepository) SearchFaceted(ctx context.Context, query string, facets domain.BookFacets, offset, limit int) ([]domain.Book, int64, error) {
+	var books []domain.Book
+	var count int64
+
+	baseQuery := r.db.WithContext(ctx).Scopes(searchScope(query, ""), facetScope(facets))
 
 	if err := baseQuery.Model(&domain.Book{}).Count(&count).Error; err != nil {
 		return nil, 0, err
@@ -121,6 +190,116 @@ func (r *bookRepository) Search(ctx context.Context, query string, offset, limit
 	return books, count, nil
 }
 
+// FacetCounts returns the values of field among the books matching query and
+// facets, most common first, and the number of distinct values. Books
+// without a value are left out.
+func (r *bookRepository) FacetCounts(ctx context.Context, field, query string, facets domain.BookFacets, offset, limit int) ([]domain.FacetCount, int64, error) {
+	switch field {
+	case domain.FacetAuthor, domain.FacetPublisher:
+	default:
+		return nil, 0, fmt.Errorf("unknown facet field %q", field)
+	}
+
+	var counts []domain.FacetCount
+	var total int64
+
+	baseQuery := r.db.WithContext(ctx).Model(&domain.Book{}).
+		Scopes(searchScope(query, ""), facetScope(facets)).
+		Where(field + " <> ''")
+
+	if err := baseQuery.Distinct(field).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	result := r.db.WithContext(ctx).Model(&domain.Book{}).
+		Scopes(searchScope(query, ""), facetScope(facets)).
+		Where(field + " <> ''").
+		Select(field + " AS value, COUNT(*) AS count").
+		Group(field).
+		Order("count DESC, value").
+		Limit(limit).Offset(offset).
+		Scan(&counts)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+	return counts, total, nil
+}
+
+// catalogFetchSize is the number of rows fetched from the export cursor at a
+// time
+const catalogFetchSize = 1000
+
+// StreamCatalog calls fn for every book matching query, joined with its
+// stock, in search order. Rows are read through a server side cursor in a
+// read only repeatable read transaction, so the whole table is never held in
+// memory and the dump is one consistent snapshot. An error from fn stops
+// the stream and is returned.
+func (r *bookRepository) StreamCatalog(ctx context.Context, query string, fn func(entry *domain.CatalogEntry) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		stmt := tx.Session(&gorm.Session{DryRun: true}).
+			Table("books AS b").
+			Select("b.id, b.isbn, b.title, b.author, b.publisher, b.publication_date, " +
+				"COALESCE(i.quantity, 0) AS quantity, COALESCE(i.price, 0) AS price, b.created_at, b.updated_at").
+			Joins("LEFT JOIN inventories AS i ON i.book_id = b.id").
+			Scopes(searchScope(query, "b.")).
+			Order("b.created_at DESC, b.id").
+			Find(&[]domain.CatalogEntry{}).Statement
+
+		if err := tx.Exec("DECLARE catalog_export NO SCROLL CURSOR FOR "+stmt.SQL.String(), stmt.Vars...).Error; err != nil {
+			return err
+		}
+
+		for {
+			var entries []domain.CatalogEntry
+			if err := tx.Raw(fmt.Sprintf("FETCH %d FROM catalog_export", catalogFetchSize)).Scan(&entries).Error; err != nil {
+				return err
+			}
+
+			for i := range entries {
+				if err := fn(&entries[i]); err != nil {
+					return err
+				}
+			}
+
+			if len(entries) < catalogFetchSize {
+				return tx.Exec("CLOSE catalog_export").Error
+			}
+		}
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+}
+
+// searchScope filters books by title, author or isbn the way Search does.
+// prefix qualifies the columns when books is joined.
+func searchScope(query, prefix string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if query == "" {
+			return db
+		}
+
+		// a query that is an isbn in any form matches the stored isbn-13
+		if code, err := isbn.Normalize(query); err == nil {
+			query = code
+		}
+
+		searchQuery := "%" + query + "%"
+		return db.Where(prefix+"title ILIKE ? OR "+prefix+"author ILIKE ? OR "+prefix+"isbn LIKE ?",
+			searchQuery, searchQuery, searchQuery)
+	}
+}
+
+// facetScope keeps the books whose fields equal the set facets
+func facetScope(facets domain.BookFacets) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if facets.Author != "" {
+			db = db.Where("author = ?", facets.Author)
+		}
+		if facets.Publisher != "" {
+			db = db.Where("publisher = ?", facets.Publisher)
+		}
+		return db
+	}
+}
+
 func (r *bookRepository) BeginTx(ctx context.Context) (*gorm.DB, error) {
 	tx := r.db.WithContext(ctx).Begin()
 	if tx.Error != nil {
@@ -128,3 +307,52 @@ func (r *bookRepository) BeginTx(ctx context.Context) (*gorm.DB, error) {
 	}
 	return tx, nil
 }
+
+// ErrDuplicateISBNs is returned by MigrateBookISBNs when books share an
+// isbn, which must be merged by hand before isbns can be made unique
+var ErrDuplicateISBNs = errors.New("isbns are stored on more than one book")
+
+// MigrateBookISBNs rewrites the isbns of books stored before isbns were
+// normalized, such as hyphenated ones, to their isbn-13, then makes isbns
+// unique. Invalid isbns are left as they are and returned so the books can
+// be fixed by hand. When books share an isbn, normalized or not, nothing
+// is changed and ErrDuplicateISBNs lists them. It is safe to run on every
+// start.
+func MigrateBookISBNs(db *gorm.DB) ([]string, error) {
+	var invalid []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var books []domain.Book
+		if err := tx.Where("isbn !~ '^[0-9]{13}$'").Find(&books).Error; err != nil {
+			return err
+		}
+
+		for _, book := range books {
+			code, err := isbn.Normalize(book.ISBN)
+			if err != nil {
+				invalid = append(invalid, book.ISBN)
+				continue
+			}
+			if err := tx.Model(&domain.Book{}).Where("id = ?", book.ID).Update("isbn", code).Error; err != nil {
+				return err
+			}
+		}
+
+		var duplicates []string
+		if err := tx.Model(&domain.Book{}).
+			Group("isbn").
+			Having("COUNT(*) > 1").
+			Order("isbn").
+			Pluck("isbn", &duplicates).Error; err != nil {
+			return err
+		}
+		if len(duplicates) > 0 {
+			return fmt.Errorf("%w: %s", ErrDuplicateISBNs, strings.Join(duplicates, ", "))
+		}
+
+		return tx.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_books_isbn ON books (isbn)").Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return invalid, nil
+}
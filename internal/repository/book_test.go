@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/gracchi-stdio/barf/internal/domain"
+	domainErr "github.com/gracchi-stdio/barf/pkg/errors"
+	"gorm.io/gorm"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// createBooks stores a book under each isbn and returns them in order
+func createBooks(t *testing.T, db *gorm.DB, isbns ...string) []*domain.Book {
+	t.Helper()
+
+	books := make([]*domain.Book, 0, len(isbns))
+	for _, code := range isbns {
+		book := &domain.Book{Title: "Test book", ISBN: code, Author: "Test author"}
+		if err := db.Create(book).Error; err != nil {
+			t.Fatalf("failed to create book: %v", err)
+		}
+		books = append(books, book)
+	}
+	return books
+}
+
+func TestMigrateBookISBNs(t *testing.T) {
+	testSchema(t, testDB(t), func(db *gorm.DB) {
+		if err := db.AutoMigrate(domain.Book{}); err != nil {
+			t.Fatal(err)
+		}
+		books := createBooks(t, db, "978-0-306-40615-7", "9780140328721", "not an isbn")
+
+		invalid, err := MigrateBookISBNs(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(invalid, []string{"not an isbn"}) {
+			t.Errorf("invalid = %v, want the unparsable isbn", invalid)
+		}
+
+		var stored domain.Book
+		if err := db.First(&stored, books[0].ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if stored.ISBN != "9780306406157" {
+			t.Errorf("isbn = %q, want it normalized to 9780306406157", stored.ISBN)
+		}
+
+		// isbns are unique from now on
+		err = NewBookRepository(db).Create(context.Background(), nil,
+			&domain.Book{Title: "Copy", ISBN: "9780140328721", Author: "Test author"})
+		if !errors.Is(err, domainErr.ErrBookExists) {
+			t.Errorf("creating a duplicate: err = %v, want ErrBookExists", err)
+		}
+
+		if _, err := MigrateBookISBNs(db); err != nil {
+			t.Errorf("second run: %v", err)
+		}
+	})
+}
+
+func TestMigrateBookISBNsDuplicates(t *testing.T) {
+	testSchema(t, testDB(t), func(db *gorm.DB) {
+		if err := db.AutoMigrate(domain.Book{}); err != nil {
+			t.Fatal(err)
+		}
+		// a duplicate as stored, and one that only shows once normalized
+		books := createBooks(t, db, "9780140328721", "9780140328721", "9780306406157", "978-0-306-40615-7")
+
+		_, err := MigrateBookISBNs(db)
+		if !errors.Is(err, ErrDuplicateISBNs) {
+			t.Fatalf("err = %v, want ErrDuplicateISBNs", err)
+		}
+		if !strings.Contains(err.Error(), "9780140328721, 9780306406157") {
+			t.Errorf("err = %v, want it to list both duplicated isbns", err)
+		}
+
+		var stored domain.Book
+		if err := db.First(&stored, books[3].ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if stored.ISBN != "978-0-306-40615-7" {
+			t.Errorf("isbn = %q, want the failed migration rolled back", stored.ISBN)
+		}
+
+		// once the books are merged the migration goes through
+		if err := db.Where("id IN ?", []uuid.UUID{books[1].ID, books[3].ID}).Delete(&domain.Book{}).Error; err != nil {
+			t.Fatal(err)
+		}
+		if _, err := MigrateBookISBNs(db); err != nil {
+			t.Fatalf("after merging: %v", err)
+		}
+		err = NewBookRepository(db).Create(context.Background(), nil,
+			&domain.Book{Title: "Copy", ISBN: "9780306406157", Author: "Test author"})
+		if !errors.Is(err, domainErr.ErrBookExists) {
+			t.Errorf("creating a duplicate: err = %v, want ErrBookExists", err)
+		}
+	})
+}
@@ -2,9 +2,12 @@ package repository
 
 import (
 	"context"
+	"github.com/google/uuid"
 	"github.com/gracchi-stdio/barf/internal/domain"
+	"github.com/gracchi-stdio/barf/pkg/bookfetcher"
 
 	"gorm.io/gorm"
+	"time"
 )
 
 type Transaction interface {
@@ -15,18 +18,77 @@ type Transaction interface {
 type BookRepository interface {
 	BeginTx(ctx context.Context) (*gorm.DB, error)
 	Create(ctx context.Context, tx *gorm.DB, book *domain.Book) error
-	Update(ctx context.Context, book *domain.Book) error
-	Delete(ctx context.Context, id string) error
+	Update(ctx context.Context, tx *gorm.DB, book *domain.Book) error
+	Delete(ctx context.Context, tx *gorm.DB, id string) error
 	GetByID(ctx context.Context, id string) (*domain.Book, error)
 	GetByISBN(ctx context.Context, isbn string) (*domain.Book, error)
+	GetByISBNs(ctx context.Context, tx *gorm.DB, isbns []string) ([]domain.Book, error)
 	List(ctx context.Context, limit, offset int) ([]domain.Book, int64, error)
 	Search(ctx context.Context, query string, offset, limit int) ([]domain.Book, int64, error)
+	SearchFaceted(ctx context.Context, query string, facets domain.BookFacets, offset, limit int) ([]domain.Book, int64, error)
+	FacetCounts(ctx context.Context, field, query string, facets domain.BookFacets, offset, limit int) ([]domain.FacetCount, int64, error)
+	StreamCatalog(ctx context.Context, query string, fn func(entry *domain.CatalogEntry) error) error
 }
 
+// InventoryRepository stores stock, in total and at each location.
+// Quantities change only through Move, which keeps the stock ledger;
+// inventories are created empty and stocked with a movement.
 type InventoryRepository interface {
 	Create(ctx context.Context, tx *gorm.DB, inventory *domain.Inventory) error
-	Update(ctx context.Context, inventory *domain.Inventory) error
+	Update(ctx context.Context, tx *gorm.DB, inventory *domain.Inventory) error
 	GetByBookID(ctx context.Context, bookID string) (*domain.Inventory, error)
-	UpdateQuantity(ctx context.Context, bookID string, quantity int) error
-	ListLowStock(ctx context.Context, threshold int) ([]domain.Inventory, error)
+	GetByBookIDsForUpdate(ctx context.Context, tx *gorm.DB, bookIDs []uuid.UUID) ([]domain.Inventory, error)
+	GetLocationStocks(ctx context.Context, tx *gorm.DB, locationID *uuid.UUID, bookIDs []uuid.UUID) ([]domain.LocationStock, error)
+	Move(ctx context.Context, tx *gorm.DB, movement *domain.StockMovement) error
+	SetBin(ctx context.Context, bookID, locationID uuid.UUID, bin string) (*domain.LocationStock, error)
+	UpdatePrice(ctx context.Context, tx *gorm.DB, bookID string, price float64) error
+	ListLowStock(ctx context.Context, threshold int, locationID *uuid.UUID) ([]domain.Inventory, error)
+}
+
+type LocationRepository interface {
+	Create(ctx context.Context, location *domain.Location) error
+	List(ctx context.Context) ([]domain.Location, error)
+	GetByCode(ctx context.Context, code string) (*domain.Location, error)
+	GetDefault(ctx context.Context) (*domain.Location, error)
+}
+
+type StockMovementRepository interface {
+	ListByBook(ctx context.Context, bookID string, offset, limit int) ([]domain.StockMovement, int64, error)
+	FindDrift(ctx context.Context) ([]domain.StockDrift, error)
+}
+
+type LookupCacheRepository interface {
+	Get(ctx context.Context, provider, isbn string) (*bookfetcher.CacheEntry, error)
+	Put(ctx context.Context, entry *bookfetcher.CacheEntry) error
+}
+
+type ReceivingRepository interface {
+	Create(ctx context.Context, session *domain.ReceivingSession) error
+	GetByID(ctx context.Context, id string) (*domain.ReceivingSession, error)
+	GetByIDForUpdate(ctx context.Context, tx *gorm.DB, id string) (*domain.ReceivingSession, error)
+	Update(ctx context.Context, tx *gorm.DB, session *domain.ReceivingSession) error
+	SaveLine(ctx context.Context, tx *gorm.DB, line *domain.ReceivingLine) error
+	AddLineQuantity(ctx context.Context, id uuid.UUID, quantity int) (*domain.ReceivingLine, error)
+}
+
+type UserRepository interface {
+	Create(ctx context.Context, user *domain.User) error
+	Update(ctx context.Context, user *domain.User) error
+	GetByID(ctx context.Context, id string) (*domain.User, error)
+	GetByEmail(ctx context.Context, email string) (*domain.User, error)
+}
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *domain.APIKey) error
+	GetByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error)
+	List(ctx context.Context) ([]domain.APIKey, error)
+	Revoke(ctx context.Context, id uuid.UUID, at time.Time) error
+	Touch(ctx context.Context, id uuid.UUID, at time.Time) error
+}
+
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *domain.RefreshToken) error
+	GetByHash(ctx context.Context, hash string) (*domain.RefreshToken, error)
+	Revoke(ctx context.Context, id uuid.UUID, at time.Time) (bool, error)
+	RevokeFamily(ctx context.Context, familyID uuid.UUID, at time.Time) error
 }
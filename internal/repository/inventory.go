@@ -5,7 +5,9 @@ import (
 	"errors"
 	"github.com/google/uuid"
 	"github.com/gracchi-stdio/barf/internal/domain"
+	domainErr "github.com/gracchi-stdio/barf/pkg/errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type inventoryRepository struct {
@@ -31,23 +33,30 @@ func (i inventoryRepository) Create(ctx context.Context, tx *gorm.DB, inventory
 	return nil
 }
 
-func (i inventoryRepository) Update(ctx context.Context, inventory *domain.Inventory) error {
-	result := i.db.WithContext(ctx).Save(inventory)
+// Update saves an inventory except for its quantity, which only Move changes
+func (i inventoryRepository) Update(ctx context.Context, tx *gorm.DB, inventory *domain.Inventory) error {
+	db := i.db
+	if tx != nil {
+		db = tx
+	}
+
+	result := db.WithContext(ctx).Omit("Quantity").Save(inventory)
 	if result.Error != nil {
 		return result.Error
 	}
 
 	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound
+		return domainErr.NotFound("inventory", gorm.ErrRecordNotFound)
 	}
 
 	return nil
 }
 
+// GetByBookID returns a book's inventory with its stock at each location
 func (i inventoryRepository) GetByBookID(ctx context.Context, bookID string) (*domain.Inventory, error) {
 	id, err := uuid.Parse(bookID)
 	if err != nil {
-		return nil, err
+		return nil, domainErr.InvalidID(bookID)
 	}
 
 	var inventory domain.Inventory
@@ -55,37 +64,240 @@ func (i inventoryRepository) GetByBookID(ctx context.Context, bookID string) (*d
 	result := i.db.WithContext(ctx).Preload("Book").Where("book_id = ?", id).First(&inventory)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			return nil, gorm.ErrRecordNotFound
+			return nil, domainErr.NotFound("inventory", gorm.ErrRecordNotFound)
 		}
 		return nil, result.Error
 	}
-	return &inventory, nil
+
+	inventories := []domain.Inventory{inventory}
+	if err := i.loadLocations(ctx, inventories, nil); err != nil {
+		return nil, err
+	}
+	return &inventories[0], nil
+}
+
+// GetByBookIDsForUpdate returns the inventory rows of the given books,
+// without the book relation, and locks them until tx ends so their
+// quantities can't change meanwhile. Rows are locked in book order, so
+// transactions locking overlapping books don't deadlock.
+func (i inventoryRepository) GetByBookIDsForUpdate(ctx context.Context, tx *gorm.DB, bookIDs []uuid.UUID) ([]domain.Inventory, error) {
+	var inventories []domain.Inventory
+	if len(bookIDs) == 0 {
+		return inventories, nil
+	}
+
+	result := tx.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("book_id IN ?", bookIDs).
+		Order("book_id").
+		Find(&inventories)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return inventories, nil
+}
+
+// GetLocationStocks returns the stock of the given books at locationID, or
+// at every location when it is nil. Books never stocked at a location have
+// no row for it.
+func (i inventoryRepository) GetLocationStocks(ctx context.Context, tx *gorm.DB, locationID *uuid.UUID, bookIDs []uuid.UUID) ([]domain.LocationStock, error) {
+	db := i.db
+	if tx != nil {
+		db = tx
+	}
+
+	var stocks []domain.LocationStock
+	if len(bookIDs) == 0 {
+		return stocks, nil
+	}
+
+	query := db.WithContext(ctx).Where("book_id IN ?", bookIDs)
+	if locationID != nil {
+		query = query.Where("location_id = ?", *locationID)
+	}
+	result := query.Find(&stocks)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return stocks, nil
+}
+
+// Move changes a book's quantity at the movement's location, and its total,
+// by the movement's delta and records the movement in the ledger, all in tx
+// or, when tx is nil, in a transaction of their own. A decrement is
+// conditional on the stock covering it, checked by the update itself: the
+// row lock makes a concurrent decrement wait and re-check against the new
+// quantity, so stock never goes below zero and the loser gets
+// ErrInsufficientStock. The inventory row is locked first, so moves of a
+// book queue there and never deadlock on its locations.
+func (i inventoryRepository) Move(ctx context.Context, tx *gorm.DB, movement *domain.StockMovement) error {
+	move := func(db *gorm.DB) error {
+		query := db.Model(&domain.Inventory{}).Where("book_id = ?", movement.BookID)
+		if movement.Delta < 0 {
+			query = query.Where("quantity + ? >= 0", movement.Delta)
+		}
+
+		result := query.Update("quantity", gorm.Expr("quantity + ?", movement.Delta))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return i.moveFailure(db, movement.BookID)
+		}
+
+		if err := i.moveAtLocation(db, movement); err != nil {
+			return err
+		}
+
+		return db.Create(movement).Error
+	}
+
+	if tx != nil {
+		return move(tx.WithContext(ctx))
+	}
+	return i.db.WithContext(ctx).Transaction(move)
 }
 
-func (i inventoryRepository) UpdateQuantity(ctx context.Context, bookID string, quantity int) error {
+// moveFailure tells why a move updated no row: the book has no inventory,
+// or not enough stock
+func (i inventoryRepository) moveFailure(db *gorm.DB, bookID uuid.UUID) error {
+	var count int64
+	if err := db.Model(&domain.Inventory{}).Where("book_id = ?", bookID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return domainErr.NotFound("inventory", gorm.ErrRecordNotFound)
+	}
+	return domainErr.ErrInsufficientStock
+}
+
+// moveAtLocation changes the stock at the movement's location. A decrement
+// needs the copies to be there; an increment stocks the location if the
+// book has not been there before.
+func (i inventoryRepository) moveAtLocation(db *gorm.DB, movement *domain.StockMovement) error {
+	if movement.Delta < 0 {
+		result := db.Model(&domain.LocationStock{}).
+			Where("book_id = ? AND location_id = ?", movement.BookID, movement.LocationID).
+			Where("quantity + ? >= 0", movement.Delta).
+			Update("quantity", gorm.Expr("quantity + ?", movement.Delta))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return domainErr.ErrInsufficientStock
+		}
+		return nil
+	}
+
+	stock := &domain.LocationStock{
+		BookID:     movement.BookID,
+		LocationID: movement.LocationID,
+		Quantity:   movement.Delta,
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "book_id"}, {Name: "location_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"quantity":   gorm.Expr("location_stocks.quantity + excluded.quantity"),
+			"updated_at": gorm.Expr("excluded.updated_at"),
+		}),
+	}).Create(stock).Error
+}
+
+// SetBin files a book's copies at a location under bin, which may be empty
+// to clear it. The book need not be stocked there yet.
+func (i inventoryRepository) SetBin(ctx context.Context, bookID, locationID uuid.UUID, bin string) (*domain.LocationStock, error) {
+	stock := &domain.LocationStock{
+		BookID:     bookID,
+		LocationID: locationID,
+		Bin:        bin,
+	}
+	result := i.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "book_id"}, {Name: "location_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"bin", "updated_at"}),
+	}, clause.Returning{}).Create(stock)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return stock, nil
+}
+
+func (i inventoryRepository) UpdatePrice(ctx context.Context, tx *gorm.DB, bookID string, price float64) error {
 	id, err := uuid.Parse(bookID)
 	if err != nil {
-		return err
+		return domainErr.InvalidID(bookID)
 	}
 
-	result := i.db.WithContext(ctx).Model(&domain.Inventory{}).Where("book_id = ?", id).Update("quantity", gorm.Expr("quantity + ?", quantity))
+	db := i.db
+	if tx != nil {
+		db = tx
+	}
+
+	result := db.WithContext(ctx).Model(&domain.Inventory{}).Where("book_id = ?", id).Update("price", price)
 
 	if result.Error != nil {
 		return result.Error
 	}
 
 	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound
+		return domainErr.NotFound("inventory", gorm.ErrRecordNotFound)
 	}
 
 	return nil
 }
 
-func (i inventoryRepository) ListLowStock(ctx context.Context, threshold int) ([]domain.Inventory, error) {
+// ListLowStock returns the inventories with at most threshold copies in
+// total or, when locationID is set, at that location, where a book that
+// was never stocked counts as none. Each inventory lists its stock at the
+// location, or at every location.
+func (i inventoryRepository) ListLowStock(ctx context.Context, threshold int, locationID *uuid.UUID) ([]domain.Inventory, error) {
 	var inventories []domain.Inventory
-	result := i.db.WithContext(ctx).Joins("Book").Where("quantity <= ?", threshold).Find(&inventories)
+	query := i.db.WithContext(ctx).Joins("Book")
+	if locationID != nil {
+		query = query.Where("COALESCE((SELECT s.quantity FROM location_stocks AS s "+
+			"WHERE s.book_id = inventories.book_id AND s.location_id = ?), 0) <= ?", *locationID, threshold)
+	} else {
+		query = query.Where("inventories.quantity <= ?", threshold)
+	}
+
+	result := query.Find(&inventories)
 	if result.Error != nil {
 		return nil, result.Error
 	}
+
+	if err := i.loadLocations(ctx, inventories, locationID); err != nil {
+		return nil, err
+	}
 	return inventories, nil
 }
+
+// loadLocations fills in the stock of the inventories at locationID, or at
+// every location when it is nil
+func (i inventoryRepository) loadLocations(ctx context.Context, inventories []domain.Inventory, locationID *uuid.UUID) error {
+	if len(inventories) == 0 {
+		return nil
+	}
+
+	bookIDs := make([]uuid.UUID, len(inventories))
+	for n, inventory := range inventories {
+		bookIDs[n] = inventory.BookID
+	}
+
+	query := i.db.WithContext(ctx).Joins("Location").Where("location_stocks.book_id IN ?", bookIDs)
+	if locationID != nil {
+		query = query.Where("location_stocks.location_id = ?", *locationID)
+	}
+
+	var stocks []domain.LocationStock
+	if err := query.Order(`"Location".is_default DESC, "Location".code`).Find(&stocks).Error; err != nil {
+		return err
+	}
+
+	byBook := make(map[uuid.UUID][]domain.LocationStock, len(inventories))
+	for _, stock := range stocks {
+		byBook[stock.BookID] = append(byBook[stock.BookID], stock)
+	}
+	for n := range inventories {
+		inventories[n].Locations = byBook[inventories[n].BookID]
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/gracchi-stdio/barf/internal/domain"
+	domainErr "github.com/gracchi-stdio/barf/pkg/errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// TestMoveNeverOversells races many sales of one book against each other.
+// Only as many may succeed as there are copies; the rest must fail with
+// ErrInsufficientStock and leave the stock, at the location and in total,
+// at zero or above and equal to the ledger.
+func TestMoveNeverOversells(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	repo := NewInventoryRepository(db)
+
+	location, err := NewLocationRepository(db).GetDefault(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	book := testBook(t, db)
+
+	const stock = 25
+	if err := repo.Move(ctx, nil, &domain.StockMovement{
+		BookID: book.ID, LocationID: location.ID, Delta: stock,
+		Reason: domain.MovementReceived, ActorKind: domain.PrincipalSystem,
+	}); err != nil {
+		t.Fatalf("failed to stock book: %v", err)
+	}
+
+	const sales = 100
+	var sold, refused atomic.Int64
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < sales; i++ {
+		// sales of one and of two copies
+		delta := -1 - i%2
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			err := repo.Move(ctx, nil, &domain.StockMovement{
+				BookID: book.ID, LocationID: location.ID, Delta: delta,
+				Reason: domain.MovementSold, ActorKind: domain.PrincipalSystem,
+			})
+			switch {
+			case err == nil:
+				sold.Add(int64(-delta))
+			case errors.Is(err, domainErr.ErrInsufficientStock):
+				refused.Add(1)
+			default:
+				t.Errorf("Move: %v", err)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if refused.Load() == 0 {
+		t.Errorf("no sale was refused, %d copies were sold of %d", sold.Load(), stock)
+	}
+	if sold.Load() > stock {
+		t.Fatalf("sold %d copies of %d", sold.Load(), stock)
+	}
+
+	inventory, err := repo.GetByBookID(ctx, book.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := stock - int(sold.Load()); inventory.Quantity != want {
+		t.Errorf("quantity = %d, want %d", inventory.Quantity, want)
+	}
+	if inventory.Quantity < 0 {
+		t.Errorf("quantity went below zero: %d", inventory.Quantity)
+	}
+	if len(inventory.Locations) != 1 || inventory.Locations[0].Quantity != inventory.Quantity {
+		t.Errorf("location stock = %+v, want one location with %d", inventory.Locations, inventory.Quantity)
+	}
+
+	var ledger int
+	if err := db.Model(&domain.StockMovement{}).Where("book_id = ?", book.ID).
+		Select("COALESCE(SUM(delta), 0)").Scan(&ledger).Error; err != nil {
+		t.Fatal(err)
+	}
+	if ledger != inventory.Quantity {
+		t.Errorf("ledger sums to %d, quantity is %d", ledger, inventory.Quantity)
+	}
+}
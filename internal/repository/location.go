@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/gracchi-stdio/barf/internal/domain"
+	domainErr "github.com/gracchi-stdio/barf/pkg/errors"
+	"gorm.io/gorm"
+)
+
+type locationRepository struct {
+	db *gorm.DB
+}
+
+func NewLocationRepository(db *gorm.DB) *locationRepository {
+	return &locationRepository{
+		db: db,
+	}
+}
+
+func (r *locationRepository) Create(ctx context.Context, location *domain.Location) error {
+	result := r.db.WithContext(ctx).Create(location)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+// List returns every location, the default first
+func (r *locationRepository) List(ctx context.Context) ([]domain.Location, error) {
+	var locations []domain.Location
+	result := r.db.WithContext(ctx).Order("is_default DESC, code").Find(&locations)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return locations, nil
+}
+
+// GetByCode returns the location with code, ignoring case
+func (r *locationRepository) GetByCode(ctx context.Context, code string) (*domain.Location, error) {
+	var location domain.Location
+	result := r.db.WithContext(ctx).Where("LOWER(code) = LOWER(?)", code).First(&location)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, domainErr.NotFound("location", gorm.ErrRecordNotFound)
+		}
+		return nil, result.Error
+	}
+	return &location, nil
+}
+
+// GetDefault returns the location stock goes to when none is named
+func (r *locationRepository) GetDefault(ctx context.Context) (*domain.Location, error) {
+	var location domain.Location
+	result := r.db.WithContext(ctx).Where("is_default = ?", true).First(&location)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, domainErr.NotFound("location", gorm.ErrRecordNotFound)
+		}
+		return nil, result.Error
+	}
+	return &location, nil
+}
+
+// MigrateLocations returns the default location, creating it with code and
+// name if there is none yet. Stock from before locations existed is kept
+// there.
+func MigrateLocations(db *gorm.DB, code, name string) (*domain.Location, error) {
+	var location domain.Location
+	result := db.Where("is_default = ?", true).First(&location)
+	if result.Error == nil {
+		return &location, nil
+	}
+	if !errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, result.Error
+	}
+
+	location = domain.Location{
+		Code:      code,
+		Name:      name,
+		Kind:      domain.LocationStore,
+		IsDefault: true,
+	}
+	if err := db.Create(&location).Error; err != nil {
+		return nil, err
+	}
+	return &location, nil
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gracchi-stdio/barf/internal/domain"
+	"github.com/gracchi-stdio/barf/pkg/bookfetcher"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type lookupCacheRepository struct {
+	db *gorm.DB
+}
+
+func NewLookupCacheRepository(db *gorm.DB) *lookupCacheRepository {
+	return &lookupCacheRepository{
+		db: db,
+	}
+}
+
+func (r *lookupCacheRepository) Get(ctx context.Context, provider, isbn string) (*bookfetcher.CacheEntry, error) {
+	var row domain.LookupCache
+	result := r.db.WithContext(ctx).Where("provider = ? AND isbn = ?", provider, isbn).First(&row)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, bookfetcher.ErrCacheMiss
+		}
+		return nil, result.Error
+	}
+
+	entry := &bookfetcher.CacheEntry{
+		Provider:  row.Provider,
+		ISBN:      row.ISBN,
+		NotFound:  row.NotFound,
+		FetchedAt: row.FetchedAt,
+		ExpiresAt: row.ExpiresAt,
+	}
+	if !row.NotFound {
+		var info bookfetcher.BookInfo
+		if err := json.Unmarshal([]byte(row.Info), &info); err != nil {
+			return nil, err
+		}
+		entry.Info = &info
+	}
+
+	return entry, nil
+}
+
+func (r *lookupCacheRepository) Put(ctx context.Context, entry *bookfetcher.CacheEntry) error {
+	info := "null"
+	if entry.Info != nil {
+		data, err := json.Marshal(entry.Info)
+		if err != nil {
+			return err
+		}
+		info = string(data)
+	}
+
+	row := domain.LookupCache{
+		Provider:  entry.Provider,
+		ISBN:      entry.ISBN,
+		Info:      info,
+		NotFound:  entry.NotFound,
+		FetchedAt: entry.FetchedAt,
+		ExpiresAt: entry.ExpiresAt,
+	}
+
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "provider"}, {Name: "isbn"}},
+		DoUpdates: clause.AssignmentColumns([]string{"info", "not_found", "fetched_at", "expires_at", "updated_at"}),
+	}).Create(&row)
+
+	return result.Error
+}
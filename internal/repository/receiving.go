@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/gracchi-stdio/barf/internal/domain"
+	domainErr "github.com/gracchi-stdio/barf/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type receivingRepository struct {
+	db *gorm.DB
+}
+
+func NewReceivingRepository(db *gorm.DB) *receivingRepository {
+	return &receivingRepository{
+		db: db,
+	}
+}
+
+func (r *receivingRepository) Create(ctx context.Context, session *domain.ReceivingSession) error {
+	result := r.db.WithContext(ctx).Create(session)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func (r *receivingRepository) GetByID(ctx context.Context, id string) (*domain.ReceivingSession, error) {
+	sessionID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, domainErr.InvalidID(id)
+	}
+
+	var session domain.ReceivingSession
+	result := r.db.WithContext(ctx).
+		Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("created_at ASC") }).
+		First(&session, sessionID)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, domainErr.NotFound("receiving session", gorm.ErrRecordNotFound)
+		}
+		return nil, result.Error
+	}
+	return &session, nil
+}
+
+// GetByIDForUpdate loads a session with its lines and locks the session row
+// until tx ends
+func (r *receivingRepository) GetByIDForUpdate(ctx context.Context, tx *gorm.DB, id string) (*domain.ReceivingSession, error) {
+	sessionID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, domainErr.InvalidID(id)
+	}
+
+	var session domain.ReceivingSession
+	result := tx.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&session, sessionID)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, domainErr.NotFound("receiving session", gorm.ErrRecordNotFound)
+		}
+		return nil, result.Error
+	}
+
+	if err := tx.WithContext(ctx).
+		Where("session_id = ?", session.ID).
+		Order("created_at ASC").
+		Find(&session.Lines).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *receivingRepository) Update(ctx context.Context, tx *gorm.DB, session *domain.ReceivingSession) error {
+	db := r.db
+	if tx != nil {
+		db = tx
+	}
+
+	result := db.WithContext(ctx).Omit("Lines").Save(session)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func (r *receivingRepository) SaveLine(ctx context.Context, tx *gorm.DB, line *domain.ReceivingLine) error {
+	db := r.db
+	if tx != nil {
+		db = tx
+	}
+
+	result := db.WithContext(ctx).Save(line)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+// AddLineQuantity adds quantity to a line's quantity in the database, so
+// concurrent scans of the same title each count, and returns the line as
+// stored afterwards
+func (r *receivingRepository) AddLineQuantity(ctx context.Context, id uuid.UUID, quantity int) (*domain.ReceivingLine, error) {
+	line := domain.ReceivingLine{ID: id}
+	result := r.db.WithContext(ctx).
+		Model(&line).
+		Clauses(clause.Returning{}).
+		Update("quantity", gorm.Expr("quantity + ?", quantity))
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, domainErr.NotFound("receiving line", gorm.ErrRecordNotFound)
+	}
+	return &line, nil
+}
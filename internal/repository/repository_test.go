@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/gracchi-stdio/barf/internal/domain"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"os"
+	"strings"
+	"testing"
+)
+
+// testDB connects to the postgres database in BARF_TEST_DSN and migrates
+// it, or skips the test when the variable is not set. The database should
+// be a scratch one; tests add rows and leave them behind.
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := os.Getenv("BARF_TEST_DSN")
+	if dsn == "" {
+		t.Skip("BARF_TEST_DSN is not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("failed to connect to database: %v", err)
+	}
+	// stay below the server's connection limit when tests race goroutines
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(20)
+
+	if err := db.Exec(`CREATE EXTENSION IF NOT EXISTS "uuid-ossp"`).Error; err != nil {
+		t.Fatalf("failed to create uuid extension: %v", err)
+	}
+	if err := db.AutoMigrate(
+		domain.Book{},
+		domain.Inventory{},
+		domain.StockMovement{},
+		domain.Location{},
+		domain.LocationStock{},
+	); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	location, err := MigrateLocations(db, "main", "Main store")
+	if err != nil {
+		t.Fatalf("failed to migrate locations: %v", err)
+	}
+	if err := MigrateStockLedger(db, location.ID); err != nil {
+		t.Fatalf("failed to migrate stock ledger: %v", err)
+	}
+	if _, err := MigrateBookISBNs(db); err != nil {
+		t.Fatalf("failed to migrate book isbns: %v", err)
+	}
+	return db
+}
+
+// testSchema calls fn with a connection whose new tables go to a fresh
+// schema, dropped afterwards, for tests that need tables in a state the
+// shared ones can't be put in
+func testSchema(t *testing.T, db *gorm.DB, fn func(db *gorm.DB)) {
+	t.Helper()
+
+	schema := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	err := db.Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+			return err
+		}
+		defer conn.Exec("DROP SCHEMA " + schema + " CASCADE")
+
+		// public stays on the path for the uuid functions
+		if err := conn.Exec("SET search_path TO " + schema + ", public").Error; err != nil {
+			return err
+		}
+		defer conn.Exec("RESET search_path")
+
+		fn(conn)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to create test schema: %v", err)
+	}
+}
+
+// testBook creates a book with an empty inventory
+func testBook(t *testing.T, db *gorm.DB) *domain.Book {
+	t.Helper()
+
+	book := &domain.Book{
+		Title:  "Test book",
+		ISBN:   fmt.Sprintf("test-%s", uuid.NewString()),
+		Author: "Test author",
+	}
+	if err := db.Create(book).Error; err != nil {
+		t.Fatalf("failed to create book: %v", err)
+	}
+	if err := db.Create(&domain.Inventory{BookID: book.ID}).Error; err != nil {
+		t.Fatalf("failed to create inventory: %v", err)
+	}
+	return book
+}
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/gracchi-stdio/barf/internal/domain"
+	domainErr "github.com/gracchi-stdio/barf/pkg/errors"
+	"gorm.io/gorm"
+)
+
+type stockMovementRepository struct {
+	db *gorm.DB
+}
+
+func NewStockMovementRepository(db *gorm.DB) *stockMovementRepository {
+	return &stockMovementRepository{
+		db: db,
+	}
+}
+
+// ListByBook returns a page of a book's movements, newest first
+func (r *stockMovementRepository) ListByBook(ctx context.Context, bookID string, offset, limit int) ([]domain.StockMovement, int64, error) {
+	id, err := uuid.Parse(bookID)
+	if err != nil {
+		return nil, 0, domainErr.InvalidID(bookID)
+	}
+
+	var movements []domain.StockMovement
+	var count int64
+
+	if err := r.db.WithContext(ctx).Model(&domain.StockMovement{}).Where("book_id = ?", id).Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+
+	result := r.db.WithContext(ctx).
+		Where("book_id = ?", id).
+		Order("created_at DESC, id").
+		Limit(limit).
+		Offset(offset).
+		Find(&movements)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+	return movements, count, nil
+}
+
+// FindDrift returns the inventories whose total quantity is not the sum of
+// their ledger, then the stock at a location that is not the sum of the
+// ledger there
+func (r *stockMovementRepository) FindDrift(ctx context.Context) ([]domain.StockDrift, error) {
+	var drift []domain.StockDrift
+	result := r.db.WithContext(ctx).
+		Table("inventories AS i").
+		Select("i.book_id, i.quantity, COALESCE(SUM(m.delta), 0) AS ledger_quantity, " +
+			"i.quantity - COALESCE(SUM(m.delta), 0) AS drift").
+		Joins("LEFT JOIN stock_movements AS m ON m.book_id = i.book_id").
+		Group("i.book_id, i.quantity").
+		Having("i.quantity <> COALESCE(SUM(m.delta), 0)").
+		Order("i.book_id").
+		Scan(&drift)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	// a full join, so ledger entries at a location with no stock row count.
+	// The stock rows of a deleted book go with it but its movements stay, so
+	// only movements of books that still exist are summed.
+	var locationDrift []domain.StockDrift
+	result = r.db.WithContext(ctx).Raw(`SELECT COALESCE(s.book_id, m.book_id) AS book_id,
+		COALESCE(s.location_id, m.location_id) AS location_id,
+		COALESCE(s.quantity, 0) AS quantity,
+		COALESCE(m.ledger_quantity, 0) AS ledger_quantity,
+		COALESCE(s.quantity, 0) - COALESCE(m.ledger_quantity, 0) AS drift
+		FROM location_stocks AS s
+		FULL JOIN (
+			SELECT book_id, location_id, SUM(delta) AS ledger_quantity
+			FROM stock_movements
+			WHERE book_id IN (SELECT id FROM books)
+			GROUP BY book_id, location_id
+		) AS m ON m.book_id = s.book_id AND m.location_id = s.location_id
+		WHERE COALESCE(s.quantity, 0) <> COALESCE(m.ledger_quantity, 0)
+		ORDER BY 1, 2`).Scan(&locationDrift)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return append(drift, locationDrift...), nil
+}
+
+// MigrateStockLedger makes the stock ledger append-only and opens it with
+// the quantities stocked before it existed. Stock and movements from before
+// locations existed are put at defaultLocation. It is safe to run on every
+// start.
+func MigrateStockLedger(db *gorm.DB, defaultLocation uuid.UUID) error {
+	statements := []struct {
+		sql  string
+		args []interface{}
+	}{
+		{sql: `CREATE OR REPLACE FUNCTION stock_movements_immutable() RETURNS trigger AS $$
+		BEGIN
+			RAISE EXCEPTION 'stock movements cannot be changed or deleted';
+		END;
+		$$ LANGUAGE plpgsql`},
+		{sql: `DROP TRIGGER IF EXISTS stock_movements_immutable ON stock_movements`},
+		// movements from before locations, while the trigger is off
+		{sql: `UPDATE stock_movements SET location_id = ? WHERE location_id IS NULL`,
+			args: []interface{}{defaultLocation}},
+		{sql: `CREATE TRIGGER stock_movements_immutable
+		BEFORE UPDATE OR DELETE ON stock_movements
+		FOR EACH ROW EXECUTE FUNCTION stock_movements_immutable()`},
+		// inventories without movements predate the ledger
+		{sql: `INSERT INTO stock_movements (book_id, location_id, delta, reason, reference, actor_kind, actor_name, created_at)
+		SELECT i.book_id, ?, i.quantity, 'adjustment', 'opening balance', 'system', 'stock ledger', NOW()
+		FROM inventories AS i
+		WHERE i.quantity <> 0
+		AND NOT EXISTS (SELECT 1 FROM stock_movements AS m WHERE m.book_id = i.book_id)`,
+			args: []interface{}{defaultLocation}},
+		// inventories without location stock predate locations
+		{sql: `INSERT INTO location_stocks (book_id, location_id, quantity, created_at, updated_at)
+		SELECT i.book_id, ?, i.quantity, NOW(), NOW()
+		FROM inventories AS i
+		WHERE i.quantity <> 0
+		AND NOT EXISTS (SELECT 1 FROM location_stocks AS s WHERE s.book_id = i.book_id)`,
+			args: []interface{}{defaultLocation}},
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range statements {
+			if err := tx.Exec(statement.sql, statement.args...).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
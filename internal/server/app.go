@@ -0,0 +1,255 @@
+package server
+
+import (
+	"crypto/rand"
+	"fmt"
+	"github.com/gracchi-stdio/barf/internal/config"
+	"github.com/gracchi-stdio/barf/internal/domain"
+	"github.com/gracchi-stdio/barf/internal/repository"
+	"github.com/gracchi-stdio/barf/internal/service"
+	"github.com/gracchi-stdio/barf/pkg/bookfetcher"
+	"github.com/gracchi-stdio/barf/pkg/bookfetcher/providers/googlebooks"
+	"github.com/gracchi-stdio/barf/pkg/bookfetcher/providers/openlibrary"
+	"github.com/gracchi-stdio/barf/pkg/bookfetcher/providers/sru"
+	"github.com/gracchi-stdio/barf/pkg/bookfetcher/providers/worldcat"
+	"github.com/gracchi-stdio/barf/pkg/marc"
+	"github.com/rs/zerolog/log"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// App wires the database, book fetchers and services. It is shared by the
+// http server and the command line tools.
+type App struct {
+	cfg *config.Config
+
+	DB          *gorm.DB
+	Monitor     *bookfetcher.HealthMonitor
+	MARCMapping marc.Mapping
+
+	BookService      *service.BookService
+	ReceivingService *service.ReceivingService
+	ImportService    *service.ImportService
+	AuthService      *service.AuthService
+	StockService     *service.StockService
+}
+
+func NewApp(cfg *config.Config) (*App, error) {
+	healthCfg := cfg.BookFetcher.Health
+	app := &App{
+		cfg: cfg,
+		Monitor: bookfetcher.NewHealthMonitor(
+			bookfetcher.BreakerConfig{
+				FailureThreshold:   healthCfg.FailureThreshold,
+				ErrorRateThreshold: healthCfg.ErrorRateThreshold,
+				Window:             healthCfg.Window,
+				OpenTimeout:        healthCfg.OpenTimeout,
+			},
+			healthCfg.ProbeInterval,
+			healthCfg.ProbeTimeout,
+		),
+	}
+
+	mapping, err := marc.ParseMapping(cfg.MARC.Mapping)
+	if err != nil {
+		return nil, err
+	}
+	app.MARCMapping = mapping
+
+	if err := app.setupDB(); err != nil {
+		return nil, err
+	}
+
+	if err := app.setupServices(); err != nil {
+		return nil, err
+	}
+
+	return app, nil
+}
+
+func (a *App) setupDB() error {
+	dsn := fmt.Sprintf("host=%v user=%v password=%v dbname=%v port=%v sslmode=disable",
+		a.cfg.DB.Host,
+		a.cfg.DB.User,
+		a.cfg.DB.Password,
+		a.cfg.DB.Name,
+		a.cfg.DB.Port)
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	// enable uuid
+	if err := db.Exec("CREATE EXTENSION IF NOT EXISTS \"uuid-ossp\";").Error; err != nil {
+		return fmt.Errorf("failed to create uuid extension: %w", err)
+	}
+
+	// migrate database
+	if err := db.AutoMigrate(
+		domain.Book{},
+		domain.Inventory{},
+		domain.LookupCache{},
+		domain.ReceivingSession{},
+		domain.ReceivingLine{},
+		domain.User{},
+		domain.APIKey{},
+		domain.RefreshToken{},
+		domain.StockMovement{},
+		domain.Location{},
+		domain.LocationStock{},
+	); err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	// before anything relies on isbns being unique
+	invalid, err := repository.MigrateBookISBNs(db)
+	if err != nil {
+		return fmt.Errorf("failed to migrate book isbns: %w", err)
+	}
+	for _, code := range invalid {
+		log.Warn().Str("isbn", code).Msg("book isbn is invalid and was not normalized")
+	}
+
+	location, err := repository.MigrateLocations(db, a.cfg.Stock.DefaultLocation, a.cfg.Stock.DefaultLocationName)
+	if err != nil {
+		return fmt.Errorf("failed to migrate locations: %w", err)
+	}
+
+	if err := repository.MigrateStockLedger(db, location.ID); err != nil {
+		return fmt.Errorf("failed to migrate stock ledger: %w", err)
+	}
+
+	log.Info().Msg("database migrated")
+
+	a.DB = db
+
+	return nil
+}
+
+func (a *App) setupServices() error {
+	// initialize repositories
+	bookRepo := repository.NewBookRepository(a.DB)
+	inventoryRepo := repository.NewInventoryRepository(a.DB)
+
+	lookupCacheRepo := repository.NewLookupCacheRepository(a.DB)
+	receivingRepo := repository.NewReceivingRepository(a.DB)
+	userRepo := repository.NewUserRepository(a.DB)
+	apiKeyRepo := repository.NewAPIKeyRepository(a.DB)
+	refreshTokenRepo := repository.NewRefreshTokenRepository(a.DB)
+	stockMovementRepo := repository.NewStockMovementRepository(a.DB)
+	locationRepo := repository.NewLocationRepository(a.DB)
+
+	// initialize fetchers
+	registry := bookfetcher.NewRegistry()
+	for name, factory := range map[string]bookfetcher.FetcherFactory{
+		"googlebooks": googlebooks.GoogleBooksFactory{},
+		"openlibrary": openlibrary.OpenLibraryFactory{},
+		"sru":         sru.SRUFactory{},
+		"worldcat":    worldcat.WorldCatFactory{},
+	} {
+		if err := registry.Register(name, factory); err != nil {
+			return err
+		}
+	}
+
+	enabled := a.cfg.EnabledProviders()
+	fetchers := make(map[string]bookfetcher.BookFetcher, len(enabled)+1)
+	providers := make([]bookfetcher.BookFetcher, 0, len(enabled))
+	chain := make([]string, 0, len(enabled))
+	for _, provider := range enabled {
+		fetcher, err := a.buildFetcher(registry, provider, lookupCacheRepo)
+		if err != nil {
+			return err
+		}
+		fetchers[provider.Name] = fetcher
+		providers = append(providers, fetcher)
+		chain = append(chain, provider.Name)
+	}
+
+	// merging fetcher asks every provider and combines the results
+	precedence := make(bookfetcher.Precedence, len(a.cfg.BookFetcher.Precedence))
+	for field, names := range a.cfg.BookFetcher.Precedence {
+		precedence[bookfetcher.MergeField(field)] = names
+	}
+	fetchers["merge"] = bookfetcher.NewMergingFetcher(providers, precedence)
+
+	// initialize services
+	a.BookService = service.NewBookService(
+		bookRepo,
+		inventoryRepo,
+		locationRepo,
+		fetchers,
+		chain)
+	a.ReceivingService = service.NewReceivingService(
+		receivingRepo,
+		bookRepo,
+		inventoryRepo,
+		locationRepo,
+		a.BookService)
+	a.ImportService = service.NewImportService(
+		bookRepo,
+		inventoryRepo,
+		locationRepo,
+		a.BookService,
+		a.cfg.Onix.Currency,
+		a.MARCMapping)
+
+	a.StockService = service.NewStockService(
+		bookRepo,
+		inventoryRepo,
+		locationRepo,
+		stockMovementRepo)
+
+	secret, err := a.authSecret()
+	if err != nil {
+		return err
+	}
+	a.AuthService = service.NewAuthService(
+		userRepo,
+		apiKeyRepo,
+		refreshTokenRepo,
+		secret,
+		a.cfg.Auth.Issuer,
+		a.cfg.Auth.AccessTTL,
+		a.cfg.Auth.RefreshTTL)
+
+	return nil
+}
+
+// authSecret returns the configured token signing secret, or a random one
+// when none is configured
+func (a *App) authSecret() ([]byte, error) {
+	if a.cfg.Auth.Secret != "" {
+		return []byte(a.cfg.Auth.Secret), nil
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate auth secret: %w", err)
+	}
+	log.Warn().Msg("auth secret is not configured, access tokens will not survive a restart")
+	return secret, nil
+}
+
+// buildFetcher creates a provider from the registry and wraps it in a circuit
+// breaker tracked by the health monitor and, if enabled, the lookup cache
+func (a *App) buildFetcher(registry *bookfetcher.Registry, provider config.Provider, cache bookfetcher.CacheStore) (bookfetcher.BookFetcher, error) {
+	fetcher, err := registry.Create(provider.Name, provider.FactoryConfig())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s fetcher: %w", provider.Name, err)
+	}
+
+	fetcher = a.Monitor.Register(fetcher)
+
+	if cacheCfg := a.cfg.BookFetcher.Cache; cacheCfg.Enabled {
+		fetcher = bookfetcher.NewCachingFetcher(
+			fetcher,
+			cache,
+			cacheCfg.TTLFor(provider.Name),
+			cacheCfg.NegativeTTL,
+		)
+	}
+
+	return fetcher, nil
+}
@@ -1,31 +1,28 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"github.com/gracchi-stdio/barf/internal/config"
-	"github.com/gracchi-stdio/barf/internal/domain"
+	"github.com/gracchi-stdio/barf/internal/export"
 	httphandler "github.com/gracchi-stdio/barf/internal/handler/http"
-	"github.com/gracchi-stdio/barf/internal/repository"
-	"github.com/gracchi-stdio/barf/internal/service"
 	"github.com/gracchi-stdio/barf/pkg/bookfetcher"
-	"github.com/gracchi-stdio/barf/pkg/bookfetcher/providers/googlebooks"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/rs/zerolog/log"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
 	"net/http"
-	"time"
 )
 
 type Server struct {
 	e   *echo.Echo
 	cfg *config.Config
-	db  *gorm.DB
+	app *App
 }
 
 func New(cfg *config.Config) *Server {
 	e := echo.New()
+	e.HTTPErrorHandler = httphandler.ErrorHandler
+	e.Validator = httphandler.RequestValidator{}
 
 	// Request logging middleware
 	e.Use(middleware.RequestLoggerWithConfig(middleware.RequestLoggerConfig{
@@ -52,75 +49,71 @@ func New(cfg *config.Config) *Server {
 	}
 }
 
-func (s *Server) setupDB() error {
-	dsn := fmt.Sprintf("host=%v user=%v password=%v dbname=%v port=%v sslmode=disable",
-		s.cfg.DB.Host,
-		s.cfg.DB.User,
-		s.cfg.DB.Password,
-		s.cfg.DB.Name,
-		s.cfg.DB.Port)
-
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
-	if err != nil {
-		return fmt.Errorf("failed to connect to database: %w", err)
-	}
-
-	// enable uuid
-	if err := db.Exec("CREATE EXTENSION IF NOT EXISTS \"uuid-ossp\";").Error; err != nil {
-		return fmt.Errorf("failed to create uuid extension: %w", err)
-	}
-
-	// migrate database
-	if err := db.AutoMigrate(
-		domain.Book{},
-		domain.Inventory{},
-	); err != nil {
-		return fmt.Errorf("failed to migrate database: %w", err)
-	}
-
-	log.Info().Msg("database migrated")
-
-	s.db = db
-
-	return nil
-}
 func (s *Server) setupRoutes() {
-
-	// initialize repositories
-	bookRepo := repository.NewBookRepository(s.db)
-	inventoryRepo := repository.NewInventoryRepository(s.db)
-
-	// google books fetcher
-	googleProvider := googlebooks.NewGoogleBooksProvider("", 5*time.Second)
-
-	// initialize fetchers
-	fetchers := map[string]bookfetcher.BookFetcher{
-		"googlebooks": googleProvider,
-	}
-	// initialize services
-	bookService := service.NewBookService(
-		bookRepo,
-		inventoryRepo,
-		fetchers,
-		"googlebooks")
-
 	// initialize handlers
-	bookHandler := httphandler.NewBookHandler(bookService)
+	exportOptions := export.Options{
+		OnixSender:  s.cfg.Onix.SenderName,
+		Currency:    s.cfg.Onix.Currency,
+		MARCMapping: s.app.MARCMapping,
+	}
+	bookHandler := httphandler.NewBookHandler(s.app.BookService, exportOptions)
+	catalogHandler := httphandler.NewCatalogHandler(s.app.BookService)
+	receivingHandler := httphandler.NewReceivingHandler(s.app.ReceivingService)
+	importHandler := httphandler.NewImportHandler(s.app.ImportService)
+	exportHandler := httphandler.NewExportHandler(s.app.BookService, exportOptions)
+	opdsHandler := httphandler.NewOPDSHandler(s.app.BookService, s.cfg.OPDS.Title, s.cfg.OPDS.PageSize)
+	providerHandler := httphandler.NewProviderHandler(s.app.Monitor)
+	authHandler := httphandler.NewAuthHandler(s.app.AuthService)
+	stockHandler := httphandler.NewStockHandler(s.app.StockService)
+
+	s.e.Use(authHandler.Middleware)
 
 	bookHandler.RegisterRoutes(s.e)
+	catalogHandler.RegisterRoutes(s.e)
+	receivingHandler.RegisterRoutes(s.e)
+	importHandler.RegisterRoutes(s.e)
+	exportHandler.RegisterRoutes(s.e)
+	opdsHandler.RegisterRoutes(s.e)
+	providerHandler.RegisterRoutes(s.e)
+	authHandler.RegisterRoutes(s.e)
+	stockHandler.RegisterRoutes(s.e)
 
 	s.e.GET("/health", func(c echo.Context) error {
-		return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
+		status := "ok"
+		if !s.app.Monitor.Healthy() {
+			status = "degraded"
+		}
+		// the health check is public, so it leaves out provider errors
+		type providerHealth struct {
+			Name    string                   `json:"name"`
+			State   bookfetcher.CircuitState `json:"state"`
+			Healthy bool                     `json:"healthy"`
+		}
+		providers := []providerHealth{}
+		for _, provider := range s.app.Monitor.Status() {
+			providers = append(providers, providerHealth{Name: provider.Name, State: provider.State, Healthy: provider.Healthy})
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"status":    status,
+			"providers": providers,
+		})
 	})
 }
 
 func (s *Server) Run() error {
-	if err := s.setupDB(); err != nil {
+	app, err := NewApp(s.cfg)
+	if err != nil {
 		return err
 	}
+	s.app = app
 
 	s.setupRoutes()
 
+	s.app.Monitor.Start(context.Background())
+	if interval := s.cfg.Stock.ReconcileInterval; interval > 0 {
+		go s.app.StockService.RunReconciliation(context.Background(), interval)
+	}
+
 	log.Info().
 		Str("port", s.cfg.Server.Port).
 		Msg("starting server")
@@ -0,0 +1,391 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/gracchi-stdio/barf/internal/domain"
+	"github.com/gracchi-stdio/barf/internal/repository"
+	domainErr "github.com/gracchi-stdio/barf/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"time"
+)
+
+// apiKeyScheme starts every api key, so leaked keys are easy to spot
+const apiKeyScheme = "barf"
+
+// minPasswordLength is the shortest password a user may have
+const minPasswordLength = 8
+
+// AuthService authenticates integrations by api key and staff by password.
+// A login returns a short-lived jwt access token and a refresh token; only
+// hashes of api keys and refresh tokens are stored. Keys and users are
+// managed by operators with barfctl, so those methods need no principal.
+type AuthService struct {
+	userRepo    repository.UserRepository
+	apiKeyRepo  repository.APIKeyRepository
+	refreshRepo repository.RefreshTokenRepository
+	secret      []byte
+	issuer      string
+	accessTTL   time.Duration
+	refreshTTL  time.Duration
+	now         func() time.Time
+}
+
+func NewAuthService(
+	userRepo repository.UserRepository,
+	apiKeyRepo repository.APIKeyRepository,
+	refreshRepo repository.RefreshTokenRepository,
+	secret []byte,
+	issuer string,
+	accessTTL time.Duration,
+	refreshTTL time.Duration,
+) *AuthService {
+	return &AuthService{
+		userRepo:    userRepo,
+		apiKeyRepo:  apiKeyRepo,
+		refreshRepo: refreshRepo,
+		secret:      secret,
+		issuer:      issuer,
+		accessTTL:   accessTTL,
+		refreshTTL:  refreshTTL,
+		now:         time.Now,
+	}
+}
+
+// accessClaims are the claims of an access token. The subject is the user id.
+type accessClaims struct {
+	Name string      `json:"name"`
+	Role domain.Role `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// CreateAPIKey mints a key for an integration acting with role. The returned
+// key is the only copy of the secret; it can't be recovered later. A zero ttl
+// never expires.
+func (s *AuthService) CreateAPIKey(ctx context.Context, name string, role domain.Role, ttl time.Duration) (*domain.APIKey, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", domainErr.Validation("api key is invalid",
+			domainErr.FieldError{Field: "name", Code: "required", Message: "is required"})
+	}
+
+	prefix, err := randomHex(6)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := randomHex(24)
+	if err != nil {
+		return nil, "", err
+	}
+
+	key := &domain.APIKey{
+		Name:   name,
+		Prefix: prefix,
+		Hash:   hashToken(secret),
+		Role:   role,
+	}
+	if ttl > 0 {
+		expiresAt := s.now().Add(ttl)
+		key.ExpiresAt = &expiresAt
+	}
+
+	if err := s.apiKeyRepo.Create(ctx, key); err != nil {
+		return nil, "", err
+	}
+	return key, strings.Join([]string{apiKeyScheme, prefix, secret}, "_"), nil
+}
+
+func (s *AuthService) ListAPIKeys(ctx context.Context) ([]domain.APIKey, error) {
+	return s.apiKeyRepo.List(ctx)
+}
+
+// RevokeAPIKey revokes the key with prefix, which may also be given as the
+// whole key
+func (s *AuthService) RevokeAPIKey(ctx context.Context, prefix string) (*domain.APIKey, error) {
+	if p, _, ok := parseAPIKey(prefix); ok {
+		prefix = p
+	}
+
+	key, err := s.apiKeyRepo.GetByPrefix(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	if key.RevokedAt == nil {
+		now := s.now()
+		if err := s.apiKeyRepo.Revoke(ctx, key.ID, now); err != nil {
+			return nil, err
+		}
+		key.RevokedAt = &now
+	}
+	return key, nil
+}
+
+// AuthenticateAPIKey returns the principal of an active api key
+func (s *AuthService) AuthenticateAPIKey(ctx context.Context, raw string) (*domain.Principal, error) {
+	prefix, secret, ok := parseAPIKey(raw)
+	if !ok {
+		return nil, domainErr.ErrInvalidAPIKey
+	}
+
+	key, err := s.apiKeyRepo.GetByPrefix(ctx, prefix)
+	if err != nil {
+		if errors.Is(err, domainErr.ErrNotFound) {
+			return nil, domainErr.ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+
+	now := s.now()
+	if subtle.ConstantTimeCompare([]byte(hashToken(secret)), []byte(key.Hash)) != 1 || !key.Active(now) {
+		return nil, domainErr.ErrInvalidAPIKey
+	}
+
+	if err := s.apiKeyRepo.Touch(ctx, key.ID, now); err != nil {
+		log.Warn().Err(err).Str("prefix", key.Prefix).Msg("failed to record api key use")
+	}
+
+	return &domain.Principal{Kind: domain.PrincipalAPIKey, ID: key.ID, Name: key.Name, Role: key.Role}, nil
+}
+
+// CreateUser adds a staff user who can log in with email and password
+func (s *AuthService) CreateUser(ctx context.Context, email, name, password string, role domain.Role) (*domain.User, error) {
+	email = strings.TrimSpace(email)
+	var fields []domainErr.FieldError
+	if !strings.Contains(email, "@") {
+		fields = append(fields, domainErr.FieldError{Field: "email", Code: "invalid_email", Message: "is not an email address"})
+	}
+	if len([]rune(password)) < minPasswordLength {
+		fields = append(fields, domainErr.FieldError{Field: "password", Code: "too_short", Message: fmt.Sprintf("must be at least %d characters", minPasswordLength)})
+	}
+	if len(fields) > 0 {
+		return nil, domainErr.Validation("user is invalid", fields...)
+	}
+
+	if _, err := s.userRepo.GetByEmail(ctx, email); err == nil {
+		return nil, domainErr.New(domainErr.KindConflict, "user_exists", "a user with this email already exists")
+	} else if !errors.Is(err, domainErr.ErrNotFound) {
+		return nil, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &domain.User{
+		Email:        email,
+		Name:         strings.TrimSpace(name),
+		PasswordHash: string(hash),
+		Role:         role,
+	}
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// SetUserRole changes the role of the user with email. Access tokens
+// already issued keep the old role until they expire.
+func (s *AuthService) SetUserRole(ctx context.Context, email string, role domain.Role) (*domain.User, error) {
+	user, err := s.userRepo.GetByEmail(ctx, strings.TrimSpace(email))
+	if err != nil {
+		return nil, err
+	}
+
+	user.Role = role
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// dummyHash is compared against when a login names an unknown user, so
+// unknown and known emails take as long to reject
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("barf-dummy-password"), bcrypt.DefaultCost)
+
+// Login checks a user's password and starts a new refresh token family
+func (s *AuthService) Login(ctx context.Context, email, password string) (*domain.TokenPair, error) {
+	user, err := s.userRepo.GetByEmail(ctx, strings.TrimSpace(email))
+	if err != nil {
+		if errors.Is(err, domainErr.ErrNotFound) {
+			bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+			return nil, domainErr.ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil || user.Disabled {
+		return nil, domainErr.ErrInvalidCredentials
+	}
+
+	return s.issueTokens(ctx, user, uuid.New())
+}
+
+// Refresh trades a refresh token for a new token pair. The token is used up;
+// presenting a used token again revokes every token descended from the same
+// login, since one of its holders must not have it.
+func (s *AuthService) Refresh(ctx context.Context, raw string) (*domain.TokenPair, error) {
+	token, err := s.refreshRepo.GetByHash(ctx, hashToken(raw))
+	if err != nil {
+		if errors.Is(err, domainErr.ErrNotFound) {
+			return nil, domainErr.ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	now := s.now()
+	if token.RevokedAt != nil {
+		return nil, s.revokeReused(ctx, token, now)
+	}
+	if !now.Before(token.ExpiresAt) || token.User.Disabled {
+		return nil, domainErr.ErrInvalidToken
+	}
+
+	revoked, err := s.refreshRepo.Revoke(ctx, token.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !revoked {
+		// another request used the token first
+		return nil, s.revokeReused(ctx, token, now)
+	}
+
+	return s.issueTokens(ctx, &token.User, token.FamilyID)
+}
+
+func (s *AuthService) revokeReused(ctx context.Context, token *domain.RefreshToken, now time.Time) error {
+	log.Warn().Str("user_id", token.UserID.String()).Msg("refresh token reused, revoking its family")
+	if err := s.refreshRepo.RevokeFamily(ctx, token.FamilyID, now); err != nil {
+		return err
+	}
+	return domainErr.ErrInvalidToken
+}
+
+// Logout revokes the refresh token and every token of its login. Access
+// tokens already issued stay valid until they expire.
+func (s *AuthService) Logout(ctx context.Context, raw string) error {
+	token, err := s.refreshRepo.GetByHash(ctx, hashToken(raw))
+	if err != nil {
+		if errors.Is(err, domainErr.ErrNotFound) {
+			return domainErr.ErrInvalidToken
+		}
+		return err
+	}
+	return s.refreshRepo.RevokeFamily(ctx, token.FamilyID, s.now())
+}
+
+// AuthenticateAccessToken returns the principal of a valid access token.
+// Tokens are checked by signature and expiry only, so a disabled user keeps
+// access until the token expires.
+func (s *AuthService) AuthenticateAccessToken(raw string) (*domain.Principal, error) {
+	var claims accessClaims
+	_, err := jwt.ParseWithClaims(raw, &claims, func(token *jwt.Token) (interface{}, error) {
+		return s.secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(s.issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(s.now),
+	)
+	if err != nil {
+		return nil, domainErr.ErrInvalidToken
+	}
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return nil, domainErr.ErrInvalidToken
+	}
+	role, err := domain.ParseRole(string(claims.Role))
+	if err != nil {
+		return nil, domainErr.ErrInvalidToken
+	}
+
+	return &domain.Principal{Kind: domain.PrincipalUser, ID: userID, Name: claims.Name, Role: role}, nil
+}
+
+func (s *AuthService) issueTokens(ctx context.Context, user *domain.User, familyID uuid.UUID) (*domain.TokenPair, error) {
+	now := s.now()
+	expiresAt := now.Add(s.accessTTL)
+
+	access, err := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims{
+		Name: user.Name,
+		Role: user.Role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.ID.String(),
+			Issuer:    s.issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}).SignedString(s.secret)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.refreshRepo.Create(ctx, &domain.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		Hash:      hashToken(refresh),
+		ExpiresAt: now.Add(s.refreshTTL),
+	}); err != nil {
+		return nil, err
+	}
+
+	return &domain.TokenPair{
+		AccessToken:  access,
+		TokenType:    "Bearer",
+		ExpiresAt:    expiresAt,
+		RefreshToken: refresh,
+	}, nil
+}
+
+// authorize checks that the principal on ctx has every permission in perms.
+// Services call it before acting, so the http api, barfctl and background
+// jobs are held to the same permission matrix.
+func authorize(ctx context.Context, perms ...domain.Permission) error {
+	principal, ok := domain.PrincipalFrom(ctx)
+	if !ok {
+		return domainErr.ErrUnauthenticated
+	}
+	for _, perm := range perms {
+		if !principal.Can(perm) {
+			return domainErr.ErrForbidden.Withf("role %s may not %s", principal.Role, perm)
+		}
+	}
+	return nil
+}
+
+// parseAPIKey splits a key of the form barf_PREFIX_SECRET
+func parseAPIKey(raw string) (prefix, secret string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(raw), "_")
+	if len(parts) != 3 || parts[0] != apiKeyScheme || parts[1] == "" || parts[2] == "" {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+// hashToken hashes a high entropy token for storage. Such tokens need no
+// salt or slow hash, unlike passwords.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,812 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/gracchi-stdio/barf/internal/domain"
+	"github.com/gracchi-stdio/barf/internal/repository"
+	"github.com/gracchi-stdio/barf/pkg/bookfetcher"
+	domainErr "github.com/gracchi-stdio/barf/pkg/errors"
+	"github.com/gracchi-stdio/barf/pkg/isbn"
+	"github.com/gracchi-stdio/barf/pkg/marc"
+	"github.com/gracchi-stdio/barf/pkg/onix"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type ImportFormat string
+
+const (
+	ImportCSV     ImportFormat = "csv"
+	ImportJSONL   ImportFormat = "jsonl"
+	ImportONIX    ImportFormat = "onix"
+	ImportMARC    ImportFormat = "marc"
+	ImportMARCXML ImportFormat = "marcxml"
+)
+
+// ParseImportFormat accepts a format name or a file extension.
+func ParseImportFormat(name string) (ImportFormat, error) {
+	switch strings.TrimPrefix(strings.ToLower(name), ".") {
+	case "csv":
+		return ImportCSV, nil
+	case "jsonl", "ndjson":
+		return ImportJSONL, nil
+	case "onix", "xml":
+		return ImportONIX, nil
+	case "marc", "mrc", "iso2709":
+		return ImportMARC, nil
+	case "marcxml":
+		return ImportMARCXML, nil
+	}
+	return "", domainErr.ErrUnsupportedFormat.Withf("%q", name)
+}
+
+// ImportMode decides how the quantity of a row is applied to a book that is
+// already in stock.
+type ImportMode string
+
+const (
+	// ImportSet replaces the stock quantity, as when loading a stock count
+	ImportSet ImportMode = "set"
+	// ImportAdd adds the quantity to the stock, as when loading a delivery
+	ImportAdd ImportMode = "add"
+)
+
+const (
+	defaultImportBatchSize = 500
+	importEnrichWorkers    = 8
+)
+
+type ImportOptions struct {
+	Format ImportFormat
+	Mode   ImportMode
+	// Enrich fills in missing book details from the book fetchers. Provider
+	// selects one fetcher, otherwise the fetcher chain is used.
+	Enrich    bool
+	Provider  string
+	BatchSize int
+	// Currency selects the price read from ONIX feeds, defaulting to the
+	// service currency
+	Currency string
+	// Location is the code of the location whose stock the import counts or
+	// receives, the default location when empty. A set quantity is the count
+	// at that location; stock at other locations is left alone.
+	Location string
+}
+
+// ImportService loads books and their inventory in bulk, upserting by ISBN.
+type ImportService struct {
+	bookRepo      repository.BookRepository
+	inventoryRepo repository.InventoryRepository
+	locationRepo  repository.LocationRepository
+	bookService   *BookService
+	currency      string
+	mapping       marc.Mapping
+}
+
+// NewImportService creates the import service. currency is the default
+// currency of prices read from ONIX feeds and mapping locates book fields in
+// MARC records.
+func NewImportService(
+	bookRepo repository.BookRepository,
+	inventoryRepo repository.InventoryRepository,
+	locationRepo repository.LocationRepository,
+	bookService *BookService,
+	currency string,
+	mapping marc.Mapping,
+) *ImportService {
+	return &ImportService{
+		bookRepo:      bookRepo,
+		inventoryRepo: inventoryRepo,
+		locationRepo:  locationRepo,
+		bookService:   bookService,
+		currency:      currency,
+		mapping:       mapping,
+	}
+}
+
+// importRow is one parsed input row. Quantity and Price are nil when the row
+// leaves them out.
+type importRow struct {
+	line            int
+	reference       string
+	Title           string   `json:"title"`
+	ISBN            string   `json:"isbn"`
+	Author          string   `json:"author"`
+	Publisher       string   `json:"publisher"`
+	PublicationDate string   `json:"publication_date"`
+	InitialQuantity *int     `json:"initial_quantity"`
+	Quantity        *int     `json:"quantity"`
+	Price           *float64 `json:"price"`
+
+	// rejected is set for rows that are read but must not be applied
+	rejected error
+	result   *domain.ImportRowResult
+}
+
+// rowError is a row that could not be parsed. Reading continues after it.
+type rowError struct {
+	line int
+	err  error
+}
+
+func (e *rowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.line, e.err)
+}
+
+type rowReader interface {
+	// Next returns the next row, a *rowError for a row that could not be
+	// parsed, or io.EOF.
+	Next() (*importRow, error)
+}
+
+// Import reads rows from r and upserts them by ISBN, one transaction per
+// batch. Rows that fail are reported and do not affect the rest of their
+// batch. An error is returned only when the input cannot be read at all or
+// the database fails; the report then covers the batches committed so far.
+// Imports add books, stock and prices, so they need all three permissions.
+func (s *ImportService) Import(ctx context.Context, r io.Reader, opts ImportOptions) (*domain.ImportReport, error) {
+	if err := authorize(ctx, domain.PermEditCatalog, domain.PermAdjustInventory, domain.PermChangePrice); err != nil {
+		return nil, err
+	}
+
+	if opts.Mode == "" {
+		opts.Mode = ImportSet
+	}
+	if opts.Mode != ImportSet && opts.Mode != ImportAdd {
+		return nil, fmt.Errorf("unknown import mode %q", opts.Mode)
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultImportBatchSize
+	}
+	if opts.Enrich && opts.Provider != "" {
+		if _, ok := s.bookService.BookFetchers[opts.Provider]; !ok {
+			return nil, bookfetcher.ErrProviderNotFound
+		}
+	}
+	location, err := resolveLocation(ctx, s.locationRepo, opts.Location)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows rowReader
+	switch opts.Format {
+	case ImportCSV:
+		reader, err := newCSVRowReader(r)
+		if err != nil {
+			return nil, err
+		}
+		rows = reader
+	case ImportJSONL:
+		rows = newJSONLRowReader(r)
+	case ImportONIX:
+		currency := opts.Currency
+		if currency == "" {
+			currency = s.currency
+		}
+		rows = newONIXRowReader(r, currency)
+	case ImportMARC:
+		rows = &marcRowReader{r: marc.NewReader(r), mapping: s.mapping}
+	case ImportMARCXML:
+		rows = &marcRowReader{r: marc.NewXMLReader(r), mapping: s.mapping}
+	default:
+		return nil, domainErr.ErrUnsupportedFormat.Withf("%q", opts.Format)
+	}
+
+	report := &domain.ImportReport{Rows: []domain.ImportRowResult{}}
+	batch := make([]*importRow, 0, opts.BatchSize)
+	for {
+		row, err := rows.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		var rowErr *rowError
+		switch {
+		case errors.As(err, &rowErr):
+			row = &importRow{line: rowErr.line}
+			row.result = &domain.ImportRowResult{Line: rowErr.line}
+			row.fail(rowErr.err)
+		case err != nil:
+			return report, err
+		default:
+			row.result = &domain.ImportRowResult{Line: row.line, Reference: row.reference, ISBN: row.ISBN}
+			if row.rejected != nil {
+				row.fail(row.rejected)
+			}
+		}
+
+		batch = append(batch, row)
+		if len(batch) == opts.BatchSize {
+			if err := s.importBatch(ctx, batch, opts, location.ID, report); err != nil {
+				return report, err
+			}
+			batch = batch[:0]
+		}
+	}
+
+	if len(batch) > 0 {
+		if err := s.importBatch(ctx, batch, opts, location.ID, report); err != nil {
+			return report, err
+		}
+	}
+
+	log.Info().
+		Int("total", report.Total).
+		Int("created", report.Created).
+		Int("updated", report.Updated).
+		Int("failed", report.Failed).
+		Msg("import finished")
+
+	return report, nil
+}
+
+func (s *ImportService) importBatch(ctx context.Context, batch []*importRow, opts ImportOptions, locationID uuid.UUID, report *domain.ImportReport) error {
+	pending := make([]*importRow, 0, len(batch))
+	for _, row := range batch {
+		if row.result.Status == domain.ImportFailed {
+			continue
+		}
+		if err := row.validate(); err != nil {
+			row.fail(err)
+			continue
+		}
+		pending = append(pending, row)
+	}
+
+	if opts.Enrich {
+		s.enrich(ctx, pending, opts.Provider)
+	}
+
+	if len(pending) > 0 {
+		if err := s.applyBatch(ctx, pending, opts.Mode, locationID); err != nil {
+			return err
+		}
+	}
+
+	for _, row := range batch {
+		report.Total++
+		switch row.result.Status {
+		case domain.ImportCreated:
+			report.Created++
+		case domain.ImportUpdated:
+			report.Updated++
+		default:
+			report.Failed++
+		}
+		report.Rows = append(report.Rows, *row.result)
+	}
+
+	return nil
+}
+
+// enrich fills in the details a row leaves out from the book fetchers. A
+// failed lookup is not an error by itself; rows that still lack a title fail
+// when they are applied.
+func (s *ImportService) enrich(ctx context.Context, rows []*importRow, provider string) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, importEnrichWorkers)
+	for _, row := range rows {
+		if row.Title != "" && row.Author != "" && row.Publisher != "" && row.PublicationDate != "" {
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(row *importRow) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			info, err := s.bookService.FetchBookDetails(ctx, row.ISBN, provider)
+			if err != nil {
+				log.Debug().Err(err).Str("isbn", row.ISBN).Msg("import enrichment failed")
+				return
+			}
+
+			row.Title = firstNonEmpty(row.Title, info.Title)
+			row.Author = firstNonEmpty(row.Author, strings.Join(info.Authors, "; "))
+			row.Publisher = firstNonEmpty(row.Publisher, info.Publisher)
+			row.PublicationDate = firstNonEmpty(row.PublicationDate, info.PublicationDate)
+			row.result.Enriched = true
+		}(row)
+	}
+	wg.Wait()
+}
+
+// applyBatch upserts the rows in one transaction. Each row runs in its own
+// savepoint so a failing row is rolled back without aborting the batch.
+func (s *ImportService) applyBatch(ctx context.Context, rows []*importRow, mode ImportMode, locationID uuid.UUID) error {
+	tx, err := s.bookRepo.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	isbns := make([]string, 0, len(rows))
+	for _, row := range rows {
+		isbns = append(isbns, row.ISBN)
+	}
+	books := make(map[string]*domain.Book, len(rows))
+	inventories := make(map[uuid.UUID]*domain.Inventory, len(rows))
+	if err := s.loadStock(ctx, tx, isbns, locationID, books, inventories); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, row := range rows {
+		if err := tx.SavePoint("import_row").Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		book, inventory, err := s.applyRow(ctx, tx, row, mode, locationID, books[row.ISBN], inventories)
+		if errors.Is(err, domainErr.ErrBookExists) {
+			// another import or receiving created the book meanwhile, so
+			// apply the row to it instead
+			if err := tx.RollbackTo("import_row").Error; err != nil {
+				tx.Rollback()
+				return err
+			}
+			if err := s.loadStock(ctx, tx, []string{row.ISBN}, locationID, books, inventories); err != nil {
+				tx.Rollback()
+				return err
+			}
+			book, inventory, err = s.applyRow(ctx, tx, row, mode, locationID, books[row.ISBN], inventories)
+		}
+		if err != nil {
+			if err := tx.RollbackTo("import_row").Error; err != nil {
+				tx.Rollback()
+				return err
+			}
+			row.fail(err)
+			continue
+		}
+
+		books[row.ISBN] = book
+		inventories[book.ID] = inventory
+		row.result.BookID = &book.ID
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		for _, row := range rows {
+			if row.result.Status != domain.ImportFailed {
+				row.result.BookID = nil
+				row.fail(err)
+			}
+		}
+		return err
+	}
+
+	return nil
+}
+
+// loadStock adds the stored books with the given isbns to books, keyed by
+// isbn, and their inventories to inventories, keyed by book id. The
+// inventories are locked until tx ends and carry their stock at locationID.
+func (s *ImportService) loadStock(
+	ctx context.Context,
+	tx *gorm.DB,
+	isbns []string,
+	locationID uuid.UUID,
+	books map[string]*domain.Book,
+	inventories map[uuid.UUID]*domain.Inventory,
+) error {
+	existing, err := s.bookRepo.GetByISBNs(ctx, tx, isbns)
+	if err != nil {
+		return err
+	}
+
+	bookIDs := make([]uuid.UUID, 0, len(existing))
+	for i := range existing {
+		books[existing[i].ISBN] = &existing[i]
+		bookIDs = append(bookIDs, existing[i].ID)
+	}
+
+	// a sale between reading a quantity and setting it would be lost
+	stock, err := s.inventoryRepo.GetByBookIDsForUpdate(ctx, tx, bookIDs)
+	if err != nil {
+		return err
+	}
+	for i := range stock {
+		inventories[stock[i].BookID] = &stock[i]
+	}
+
+	// set quantities are counts of the import location
+	located, err := s.inventoryRepo.GetLocationStocks(ctx, tx, &locationID, bookIDs)
+	if err != nil {
+		return err
+	}
+	for _, stock := range located {
+		if inventory, ok := inventories[stock.BookID]; ok {
+			inventory.Locations = []domain.LocationStock{stock}
+		}
+	}
+
+	return nil
+}
+
+func (s *ImportService) applyRow(
+	ctx context.Context,
+	tx *gorm.DB,
+	row *importRow,
+	mode ImportMode,
+	locationID uuid.UUID,
+	book *domain.Book,
+	inventories map[uuid.UUID]*domain.Inventory,
+) (*domain.Book, *domain.Inventory, error) {
+	quantity := 0
+	if row.quantity() != nil {
+		quantity = *row.quantity()
+	}
+
+	if book == nil {
+		if row.Title == "" {
+			return nil, nil, domainErr.ErrTitleRequired
+		}
+
+		book = &domain.Book{
+			Title:           row.Title,
+			ISBN:            row.ISBN,
+			Author:          row.Author,
+			Publisher:       row.Publisher,
+			PublicationDate: row.PublicationDate,
+		}
+		if err := s.bookRepo.Create(ctx, tx, book); err != nil {
+			return nil, nil, err
+		}
+
+		inventory := &domain.Inventory{BookID: book.ID}
+		if row.Price != nil {
+			inventory.Price = *row.Price
+		}
+		if err := s.inventoryRepo.Create(ctx, tx, inventory); err != nil {
+			return nil, nil, err
+		}
+		if err := s.moveStock(ctx, tx, book.ID, locationID, quantity, mode); err != nil {
+			return nil, nil, err
+		}
+		inventory.Quantity = quantity
+		inventory.Locations = []domain.LocationStock{{BookID: book.ID, LocationID: locationID, Quantity: quantity}}
+
+		row.result.Status = domain.ImportCreated
+		return book, inventory, nil
+	}
+
+	// only the details a row gives overwrite the stored book
+	updated := *book
+	updated.Title = firstNonEmpty(row.Title, book.Title)
+	updated.Author = firstNonEmpty(row.Author, book.Author)
+	updated.Publisher = firstNonEmpty(row.Publisher, book.Publisher)
+	updated.PublicationDate = firstNonEmpty(row.PublicationDate, book.PublicationDate)
+	if updated != *book {
+		if err := s.bookRepo.Update(ctx, tx, &updated); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	inventory, ok := inventories[book.ID]
+	if !ok {
+		inventory = &domain.Inventory{BookID: book.ID}
+	}
+
+	// a set quantity is a count of the import location, so stock elsewhere
+	// is left alone and the total changes by the difference there
+	located := quantityAt(inventory, locationID)
+	delta := 0
+	if mode == ImportAdd {
+		delta = quantity
+	} else if row.quantity() != nil {
+		delta = quantity - located
+	}
+
+	changed := *inventory
+	changed.Quantity += delta
+	changed.Locations = []domain.LocationStock{{BookID: book.ID, LocationID: locationID, Quantity: located + delta}}
+	if row.Price != nil {
+		changed.Price = *row.Price
+	}
+
+	// the quantity changes through the ledger, the rest is saved as is
+	stocked := changed
+	stocked.Quantity = inventory.Quantity
+	stocked.Locations = nil
+	switch {
+	case !ok:
+		if err := s.inventoryRepo.Create(ctx, tx, &stocked); err != nil {
+			return nil, nil, err
+		}
+	case stocked.Price != inventory.Price:
+		if err := s.inventoryRepo.Update(ctx, tx, &stocked); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := s.moveStock(ctx, tx, book.ID, locationID, delta, mode); err != nil {
+		return nil, nil, err
+	}
+
+	row.result.Status = domain.ImportUpdated
+	return &updated, &changed, nil
+}
+
+// quantityAt returns the copies of an inventory's book at a location, as far
+// as its Locations were loaded
+func quantityAt(inventory *domain.Inventory, locationID uuid.UUID) int {
+	for _, stock := range inventory.Locations {
+		if stock.LocationID == locationID {
+			return stock.Quantity
+		}
+	}
+	return 0
+}
+
+// moveStock records an imported change of stock: added copies are received,
+// set quantities are adjustments
+func (s *ImportService) moveStock(ctx context.Context, tx *gorm.DB, bookID, locationID uuid.UUID, delta int, mode ImportMode) error {
+	if delta == 0 {
+		return nil
+	}
+
+	reason := domain.MovementAdjustment
+	if mode == ImportAdd {
+		reason = domain.MovementReceived
+	}
+	return s.inventoryRepo.Move(ctx, tx, newMovement(ctx, bookID, locationID, delta, reason, "import"))
+}
+
+// quantity returns the row quantity, which either column name may carry
+func (r *importRow) quantity() *int {
+	if r.InitialQuantity != nil {
+		return r.InitialQuantity
+	}
+	return r.Quantity
+}
+
+func (r *importRow) validate() error {
+	code, err := isbn.Normalize(r.ISBN)
+	if err != nil {
+		return err
+	}
+	r.ISBN = code
+	r.result.ISBN = code
+
+	if q := r.quantity(); q != nil && *q < 0 {
+		return domainErr.ErrInvalidQuantity
+	}
+	if r.Price != nil && *r.Price < 0 {
+		return domainErr.ErrInvalidPrice
+	}
+	return nil
+}
+
+func (r *importRow) fail(err error) {
+	r.result.Status = domain.ImportFailed
+	r.result.Error = err.Error()
+}
+
+// csvRowReader reads rows of a csv file with a header line. Columns are
+// matched by name; unknown columns are ignored.
+type csvRowReader struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+var csvColumns = []string{"title", "isbn", "author", "publisher", "publication_date", "initial_quantity", "quantity", "price"}
+
+func newCSVRowReader(r io.Reader) (*csvRowReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, domainErr.ErrMalformedInput.Withf("empty csv file")
+		}
+		return nil, domainErr.ErrMalformedInput.Withf("%v", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		for _, known := range csvColumns {
+			if name == known {
+				columns[name] = i
+			}
+		}
+	}
+	if _, ok := columns["isbn"]; !ok {
+		return nil, domainErr.ErrMalformedInput.Withf("csv header has no isbn column")
+	}
+
+	return &csvRowReader{r: reader, columns: columns}, nil
+}
+
+func (c *csvRowReader) Next() (*importRow, error) {
+	record, err := c.r.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, &rowError{line: parseErr.StartLine, err: parseErr.Err}
+		}
+		return nil, err
+	}
+
+	line, _ := c.r.FieldPos(0)
+	field := func(name string) string {
+		i, ok := c.columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	row := &importRow{
+		line:            line,
+		Title:           field("title"),
+		ISBN:            field("isbn"),
+		Author:          field("author"),
+		Publisher:       field("publisher"),
+		PublicationDate: field("publication_date"),
+	}
+
+	for name, dst := range map[string]**int{
+		"initial_quantity": &row.InitialQuantity,
+		"quantity":         &row.Quantity,
+	} {
+		if value := field(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, &rowError{line: line, err: fmt.Errorf("invalid %s %q", name, value)}
+			}
+			*dst = &n
+		}
+	}
+
+	if value := field("price"); value != "" {
+		price, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, &rowError{line: line, err: fmt.Errorf("invalid price %q", value)}
+		}
+		row.Price = &price
+	}
+
+	return row, nil
+}
+
+// jsonlRowReader reads one json object per line. Blank lines are skipped.
+type jsonlRowReader struct {
+	s    *bufio.Scanner
+	line int
+}
+
+func newJSONLRowReader(r io.Reader) *jsonlRowReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	return &jsonlRowReader{s: scanner}
+}
+
+func (j *jsonlRowReader) Next() (*importRow, error) {
+	for j.s.Scan() {
+		j.line++
+		text := strings.TrimSpace(j.s.Text())
+		if j.line == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		if text == "" {
+			continue
+		}
+
+		row := &importRow{line: j.line}
+		if err := json.Unmarshal([]byte(text), row); err != nil {
+			return nil, &rowError{line: j.line, err: err}
+		}
+		row.line = j.line
+		row.Title = strings.TrimSpace(row.Title)
+		row.ISBN = strings.TrimSpace(row.ISBN)
+		return row, nil
+	}
+
+	if err := j.s.Err(); err != nil {
+		return nil, domainErr.ErrMalformedInput.Withf("%v", err)
+	}
+	return nil, io.EOF
+}
+
+// onixRowReader reads the products of an ONIX 3.0 feed. Prices are taken
+// from the recommended retail price in currency; stock figures in the feed
+// are the supplier's and are not imported.
+type onixRowReader struct {
+	d        *onix.Decoder
+	currency string
+	record   int
+}
+
+func newONIXRowReader(r io.Reader, currency string) *onixRowReader {
+	return &onixRowReader{d: onix.NewDecoder(r), currency: currency}
+}
+
+func (o *onixRowReader) Next() (*importRow, error) {
+	product, err := o.d.Next()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, domainErr.ErrMalformedInput.Withf("product %d: %v", o.record+1, err)
+	}
+	o.record++
+
+	title, _ := product.Title()
+	authors := product.Contributors(onix.RoleAuthor)
+	if len(authors) == 0 {
+		authors = product.Contributors(onix.RoleEditor)
+	}
+
+	row := &importRow{
+		line:            o.record,
+		reference:       product.RecordReference,
+		Title:           title,
+		ISBN:            product.ISBN13(),
+		Author:          strings.Join(authors, "; "),
+		Publisher:       product.PublisherName(),
+		PublicationDate: product.PublicationDate(),
+	}
+	if price, ok := product.RetailPrice(o.currency); ok {
+		row.Price = &price
+	}
+	if product.Deleted() {
+		row.rejected = errors.New("delete notifications are not applied")
+	}
+
+	return row, nil
+}
+
+// marcRowReader reads MARC 21 bibliographic records, in ISO 2709 or
+// MARCXML. Records carry no stock or price.
+type marcRowReader struct {
+	r interface {
+		Next() (*marc.Record, error)
+	}
+	mapping marc.Mapping
+	record  int
+}
+
+func (m *marcRowReader) Next() (*importRow, error) {
+	record, err := m.r.Next()
+	m.record++
+	switch {
+	case errors.Is(err, io.EOF):
+		return nil, io.EOF
+	case errors.Is(err, marc.ErrMalformedRecord):
+		return nil, &rowError{line: m.record, err: err}
+	case err != nil:
+		return nil, domainErr.ErrMalformedInput.Withf("record %d: %v", m.record, err)
+	}
+
+	info := m.mapping.BookInfo(record)
+	return &importRow{
+		line:            m.record,
+		reference:       info.ProviderID,
+		Title:           info.Title,
+		ISBN:            info.ISBN,
+		Author:          strings.Join(info.Authors, "; "),
+		Publisher:       info.Publisher,
+		PublicationDate: info.PublicationDate,
+	}, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,427 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/gracchi-stdio/barf/internal/domain"
+	"github.com/gracchi-stdio/barf/internal/repository"
+	"github.com/gracchi-stdio/barf/pkg/bookfetcher"
+	domainErr "github.com/gracchi-stdio/barf/pkg/errors"
+	"github.com/gracchi-stdio/barf/pkg/isbn"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+// ReceivingService stages scanned stock in receiving sessions and applies a
+// whole session to the catalog and inventory when it is closed.
+type ReceivingService struct {
+	receivingRepo repository.ReceivingRepository
+	bookRepo      repository.BookRepository
+	inventoryRepo repository.InventoryRepository
+	locationRepo  repository.LocationRepository
+	bookService   *BookService
+}
+
+func NewReceivingService(
+	receivingRepo repository.ReceivingRepository,
+	bookRepo repository.BookRepository,
+	inventoryRepo repository.InventoryRepository,
+	locationRepo repository.LocationRepository,
+	bookService *BookService,
+) *ReceivingService {
+	return &ReceivingService{
+		receivingRepo: receivingRepo,
+		bookRepo:      bookRepo,
+		inventoryRepo: inventoryRepo,
+		locationRepo:  locationRepo,
+		bookService:   bookService,
+	}
+}
+
+// ReceivingLineUpdate holds the fields a clerk may change on a staged line.
+// Nil fields are left unchanged.
+type ReceivingLineUpdate struct {
+	Quantity        *int
+	Title           *string
+	Author          *string
+	Publisher       *string
+	PublicationDate *string
+	Price           *float64
+	Confirmed       *bool
+}
+
+// OpenSession opens a session receiving stock at location, a location code,
+// or at the default location when it is empty
+func (s *ReceivingService) OpenSession(ctx context.Context, note, location string) (*domain.ReceivingSession, error) {
+	if err := authorize(ctx, domain.PermAdjustInventory); err != nil {
+		return nil, err
+	}
+
+	loc, err := resolveLocation(ctx, s.locationRepo, location)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &domain.ReceivingSession{
+		Status:     domain.ReceivingOpen,
+		Note:       note,
+		LocationID: &loc.ID,
+		Lines:      []domain.ReceivingLine{},
+	}
+
+	if err := s.receivingRepo.Create(ctx, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (s *ReceivingService) GetSession(ctx context.Context, id string) (*domain.ReceivingSession, error) {
+	if err := authorize(ctx, domain.PermAdjustInventory); err != nil {
+		return nil, err
+	}
+
+	return s.receivingRepo.GetByID(ctx, id)
+}
+
+// Scan records quantity copies of the scanned EAN-13 or ISBN. A title the
+// catalog already has is staged as a restock; otherwise the isbn is looked up
+// and staged as a new book awaiting confirmation.
+func (s *ReceivingService) Scan(ctx context.Context, sessionID string, code string, quantity int) (*domain.ReceivingLine, error) {
+	if err := authorize(ctx, domain.PermAdjustInventory); err != nil {
+		return nil, err
+	}
+
+	if quantity < 1 {
+		return nil, domainErr.Validation("scan is invalid",
+			domainErr.FieldError{Field: "quantity", Code: "min", Message: "must be at least 1"})
+	}
+
+	code, err := isbn.Normalize(code)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := s.openSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	// another copy of a title already scanned in this session
+	for _, line := range session.Lines {
+		if line.ISBN == code {
+			return s.receivingRepo.AddLineQuantity(ctx, line.ID, quantity)
+		}
+	}
+
+	line := &domain.ReceivingLine{
+		SessionID: session.ID,
+		ISBN:      code,
+		Quantity:  quantity,
+	}
+
+	book, err := s.bookRepo.GetByISBN(ctx, code)
+	switch {
+	case err == nil:
+		line.Action = domain.ReceivingRestock
+		line.BookID = &book.ID
+		line.Title = book.Title
+		line.Author = book.Author
+		line.Publisher = book.Publisher
+		line.PublicationDate = book.PublicationDate
+		line.Confirmed = true
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		info, err := s.bookService.FetchBookDetails(ctx, code, "")
+		if err != nil {
+			// the line is shown to clerks, so provider errors stay in the log
+			log.Info().Err(err).Str("isbn", code).Msg("receiving lookup failed")
+			line.Action = domain.ReceivingFailed
+			line.Error = lookupFailure(err)
+			break
+		}
+		line.Action = domain.ReceivingNew
+		line.Title = info.Title
+		line.Author = strings.Join(info.Authors, "; ")
+		line.Publisher = info.Publisher
+		line.PublicationDate = info.PublicationDate
+		line.Provider = info.Provider
+	default:
+		return nil, err
+	}
+
+	if err := s.receivingRepo.SaveLine(ctx, nil, line); err != nil {
+		return nil, err
+	}
+	return line, nil
+}
+
+// UpdateLine edits a staged line, e.g. to confirm a new title, set its price
+// or enter the details of a failed lookup by hand. Confirming and describing
+// titles is cataloging and pricing them is a price change, so a clerk may
+// only correct quantities.
+func (s *ReceivingService) UpdateLine(ctx context.Context, sessionID, lineID string, update ReceivingLineUpdate) (*domain.ReceivingLine, error) {
+	perms := []domain.Permission{domain.PermAdjustInventory}
+	if update.Title != nil || update.Author != nil || update.Publisher != nil ||
+		update.PublicationDate != nil || update.Confirmed != nil {
+		perms = append(perms, domain.PermEditCatalog)
+	}
+	if update.Price != nil {
+		perms = append(perms, domain.PermChangePrice)
+	}
+	if err := authorize(ctx, perms...); err != nil {
+		return nil, err
+	}
+
+	id, err := uuid.Parse(lineID)
+	if err != nil {
+		return nil, domainErr.InvalidID(lineID)
+	}
+
+	session, err := s.openSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var line *domain.ReceivingLine
+	for i := range session.Lines {
+		if session.Lines[i].ID == id {
+			line = &session.Lines[i]
+			break
+		}
+	}
+	if line == nil {
+		return nil, domainErr.NotFound("receiving line", gorm.ErrRecordNotFound)
+	}
+
+	if update.Quantity != nil {
+		line.Quantity = max(*update.Quantity, 0)
+	}
+	if update.Price != nil {
+		line.Price = *update.Price
+	}
+
+	// restocked books keep their catalog details
+	if line.Action != domain.ReceivingRestock {
+		if update.Title != nil {
+			line.Title = *update.Title
+		}
+		if update.Author != nil {
+			line.Author = *update.Author
+		}
+		if update.Publisher != nil {
+			line.Publisher = *update.Publisher
+		}
+		if update.PublicationDate != nil {
+			line.PublicationDate = *update.PublicationDate
+		}
+		if update.Confirmed != nil {
+			line.Confirmed = *update.Confirmed
+		}
+
+		if line.Confirmed {
+			if strings.TrimSpace(line.Title) == "" {
+				return nil, domainErr.ErrTitleRequired
+			}
+			// details entered by hand turn a failed lookup into a new title
+			line.Action = domain.ReceivingNew
+			line.Error = ""
+		}
+	}
+
+	if err := s.receivingRepo.SaveLine(ctx, nil, line); err != nil {
+		return nil, err
+	}
+	return line, nil
+}
+
+// CloseSession applies every line of the session in a single transaction:
+// restocks update inventory and take the line's price when one was set,
+// confirmed new titles are created with their inventory. Unconfirmed and failed lines are reported but not applied.
+// New titles were approved when their lines were confirmed, so closing only
+// needs permission to adjust inventory.
+func (s *ReceivingService) CloseSession(ctx context.Context, sessionID string) (*domain.ReceivingSummary, error) {
+	if err := authorize(ctx, domain.PermAdjustInventory); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.bookRepo.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := s.receivingRepo.GetByIDForUpdate(ctx, tx, sessionID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if session.Status != domain.ReceivingOpen {
+		tx.Rollback()
+		return nil, domainErr.ErrSessionClosed
+	}
+
+	var locationID uuid.UUID
+	if session.LocationID != nil {
+		locationID = *session.LocationID
+	} else {
+		location, err := s.locationRepo.GetDefault(ctx)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		locationID = location.ID
+	}
+
+	summary := &domain.ReceivingSummary{
+		SessionID:   session.ID,
+		NewTitles:   []domain.ReceivingLine{},
+		Restocked:   []domain.ReceivingLine{},
+		Failed:      []domain.ReceivingLine{},
+		Unconfirmed: []domain.ReceivingLine{},
+	}
+
+	for i := range session.Lines {
+		line := &session.Lines[i]
+		if line.Quantity <= 0 {
+			continue
+		}
+
+		switch {
+		case line.Action == domain.ReceivingFailed:
+			summary.Failed = append(summary.Failed, *line)
+			continue
+		case line.Action == domain.ReceivingNew && !line.Confirmed:
+			summary.Unconfirmed = append(summary.Unconfirmed, *line)
+			continue
+		}
+
+		// a staged new title may have been added to the catalog meanwhile
+		if line.Action == domain.ReceivingNew {
+			books, err := s.bookRepo.GetByISBNs(ctx, tx, []string{line.ISBN})
+			if err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+			if len(books) > 0 {
+				line.Action = domain.ReceivingRestock
+				line.BookID = &books[0].ID
+			}
+		}
+
+		if line.Action == domain.ReceivingNew {
+			book, created, err := s.createTitle(ctx, tx, line)
+			if err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+			line.BookID = &book.ID
+			if !created {
+				line.Action = domain.ReceivingRestock
+			}
+		}
+
+		// a new title got its price when its inventory was created
+		if line.Action == domain.ReceivingRestock && line.Price > 0 {
+			if err := s.inventoryRepo.UpdatePrice(ctx, tx, line.BookID.String(), line.Price); err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+		}
+
+		reference := "receiving session " + session.ID.String()
+		movement := newMovement(ctx, *line.BookID, locationID, line.Quantity, domain.MovementReceived, reference)
+		if err := s.inventoryRepo.Move(ctx, tx, movement); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if line.Action == domain.ReceivingRestock {
+			summary.Restocked = append(summary.Restocked, *line)
+		} else {
+			summary.NewTitles = append(summary.NewTitles, *line)
+		}
+
+		if err := s.receivingRepo.SaveLine(ctx, tx, line); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		summary.TotalCopies += line.Quantity
+	}
+
+	now := time.Now()
+	session.Status = domain.ReceivingClosed
+	session.ClosedAt = &now
+	if err := s.receivingRepo.Update(ctx, tx, session); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
+
+// createTitle creates the book and empty inventory of a confirmed new title
+// line. If the isbn was stored by another transaction since the line was
+// staged, that book is returned instead and created is false.
+func (s *ReceivingService) createTitle(ctx context.Context, tx *gorm.DB, line *domain.ReceivingLine) (*domain.Book, bool, error) {
+	if err := tx.SavePoint("receiving_title").Error; err != nil {
+		return nil, false, err
+	}
+
+	book := &domain.Book{
+		Title:           line.Title,
+		ISBN:            line.ISBN,
+		Author:          line.Author,
+		Publisher:       line.Publisher,
+		PublicationDate: line.PublicationDate,
+	}
+	if err := s.bookRepo.Create(ctx, tx, book); err != nil {
+		if !errors.Is(err, domainErr.ErrBookExists) {
+			return nil, false, err
+		}
+		if err := tx.RollbackTo("receiving_title").Error; err != nil {
+			return nil, false, err
+		}
+		existing, err := s.bookRepo.GetByISBNs(ctx, tx, []string{line.ISBN})
+		if err != nil {
+			return nil, false, err
+		}
+		if len(existing) == 0 {
+			return nil, false, domainErr.NotFound("book", gorm.ErrRecordNotFound)
+		}
+		return &existing[0], false, nil
+	}
+
+	inventory := &domain.Inventory{
+		BookID: book.ID,
+		Price:  line.Price,
+	}
+	if err := s.inventoryRepo.Create(ctx, tx, inventory); err != nil {
+		return nil, false, err
+	}
+	return book, true, nil
+}
+
+// lookupFailure describes a failed lookup for the clerk without the provider
+// error, which may name request urls
+func lookupFailure(err error) string {
+	if errors.Is(err, bookfetcher.ErrBookNotFound) {
+		return "no book provider knows this isbn"
+	}
+	return "book lookup failed, enter the details by hand"
+}
+
+// openSession loads a session that still accepts scans
+func (s *ReceivingService) openSession(ctx context.Context, id string) (*domain.ReceivingSession, error) {
+	session, err := s.receivingRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if session.Status != domain.ReceivingOpen {
+		return nil, domainErr.ErrSessionClosed
+	}
+	return session, nil
+}
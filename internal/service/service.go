@@ -3,70 +3,153 @@ package service
 import (
 	"context"
 	"errors"
+	"github.com/google/uuid"
 	"github.com/gracchi-stdio/barf/internal/domain"
 	"github.com/gracchi-stdio/barf/internal/repository"
 	"github.com/gracchi-stdio/barf/pkg/bookfetcher"
 	domainErr "github.com/gracchi-stdio/barf/pkg/errors"
+	"github.com/gracchi-stdio/barf/pkg/isbn"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
 	"strings"
 )
 
 type BookService struct {
-	bookRepo       repository.BookRepository
-	inventoryRepo  repository.InventoryRepository
-	BookFetchers   map[string]bookfetcher.BookFetcher
-	defaultFetcher string
+	bookRepo      repository.BookRepository
+	inventoryRepo repository.InventoryRepository
+	locationRepo  repository.LocationRepository
+	BookFetchers  map[string]bookfetcher.BookFetcher
+	fetcherChain  *bookfetcher.ChainFetcher
 }
 
+// NewBookService creates the book service. fetcherChain lists the keys of
+// fetchers in the order they are tried when no provider is requested
+// explicitly; keys without a registered fetcher are skipped.
 func NewBookService(
 	bookRepo repository.BookRepository,
 	inventoryRepo repository.InventoryRepository,
+	locationRepo repository.LocationRepository,
 	fetchers map[string]bookfetcher.BookFetcher,
-	defaultFetcher string,
+	fetcherChain []string,
 ) *BookService {
+	chain := make([]bookfetcher.BookFetcher, 0, len(fetcherChain))
+	for _, name := range fetcherChain {
+		fetcher, ok := fetchers[name]
+		if !ok {
+			log.Warn().Str("provider", name).Msg("book fetcher in chain is not registered, skipping")
+			continue
+		}
+		chain = append(chain, fetcher)
+	}
+
 	return &BookService{
-		bookRepo:       bookRepo,
-		inventoryRepo:  inventoryRepo,
-		BookFetchers:   fetchers,
-		defaultFetcher: defaultFetcher,
+		bookRepo:      bookRepo,
+		inventoryRepo: inventoryRepo,
+		locationRepo:  locationRepo,
+		BookFetchers:  fetchers,
+		fetcherChain:  bookfetcher.NewChainFetcher(chain...),
 	}
 }
 
-func (s *BookService) FetchBookDetails(ctx context.Context, isbn string, provider string) (*bookfetcher.BookInfo, error) {
-	var fetcher bookfetcher.BookFetcher
-	var ok bool
+// InventoryUpdate changes the stock of a book at Location, a location code,
+// or the default location when it is empty. A nil Price keeps the price.
+// Reason and Reference go in the stock ledger; without a reason a negative
+// change is a sale and a positive one an adjustment.
+type InventoryUpdate struct {
+	QuantityChange int
+	Location       string
+	Reason         domain.MovementReason
+	Reference      string
+	Price          *float64
+}
+
+// FetchBookDetails looks up a book by ISBN. If provider is empty the
+// configured fetcher chain is walked until a provider answers; the answering
+// provider is reported in BookInfo.Provider.
+func (s *BookService) FetchBookDetails(ctx context.Context, code string, provider string) (*bookfetcher.BookInfo, error) {
+	if err := authorize(ctx, domain.PermReadCatalog); err != nil {
+		return nil, err
+	}
+
+	code, err := isbn.Normalize(code)
+	if err != nil {
+		return nil, err
+	}
+
+	if provider == "" {
+		info, err := s.fetcherChain.GetBookByISBN(ctx, code)
+		if err != nil {
+			return nil, err
+		}
 
-	if provider != "" {
-		provider = s.defaultFetcher
+		log.Debug().Str("isbn", code).Str("provider", info.Provider).Msg("book details fetched")
+		return info, nil
 	}
 
-	if fetcher, ok = s.BookFetchers[provider]; !ok {
-		return nil, errors.New("provider not found")
+	fetcher, ok := s.BookFetchers[provider]
+	if !ok {
+		return nil, bookfetcher.ErrProviderNotFound
 	}
 
-	return fetcher.GetBookByISBN(ctx, isbn)
+	return fetcher.GetBookByISBN(ctx, code)
 }
 
-func (s *BookService) CreateBookWithISBN(ctx context.Context, isbn string, initialQuantity int, price float64) (*domain.Book, error) {
+// RefreshBookDetails looks up a book like FetchBookDetails but bypasses the
+// lookup cache, storing the fresh answer.
+func (s *BookService) RefreshBookDetails(ctx context.Context, code string, provider string) (*bookfetcher.BookInfo, error) {
+	if err := authorize(ctx, domain.PermManageProviders); err != nil {
+		return nil, err
+	}
+	return s.FetchBookDetails(bookfetcher.WithRefresh(ctx), code, provider)
+}
+
+// SearchCatalog searches the external catalog of provider, or the fetcher
+// chain when provider is empty. Nothing is saved.
+func (s *BookService) SearchCatalog(ctx context.Context, opts bookfetcher.SearchOptions, provider string) (*bookfetcher.SearchResult, error) {
+	if err := authorize(ctx, domain.PermReadCatalog); err != nil {
+		return nil, err
+	}
+
+	if provider == "" {
+		return s.fetcherChain.SearchBooks(ctx, opts)
+	}
+
+	fetcher, ok := s.BookFetchers[provider]
+	if !ok {
+		return nil, bookfetcher.ErrProviderNotFound
+	}
+
+	return fetcher.SearchBooks(ctx, opts)
+}
+
+// CreateBookWithISBN creates a book and its inventory from provider details.
+// If a book with the isbn already exists it is returned and created is false.
+func (s *BookService) CreateBookWithISBN(ctx context.Context, code string, initialQuantity int, price float64) (*domain.Book, bool, error) {
+	if err := authorize(ctx, createPermissions(initialQuantity, price)...); err != nil {
+		return nil, false, err
+	}
+
+	code, err := isbn.Normalize(code)
+	if err != nil {
+		return nil, false, err
+	}
+
 	// first check if book exists
-	existing, _ := s.bookRepo.GetByISBN(ctx, isbn)
+	existing, _ := s.bookRepo.GetByISBN(ctx, code)
 	if existing != nil {
-		return existing, nil
+		return existing, false, nil
 	}
 
 	// fetch book details
-	bookInfo, err := s.FetchBookDetails(ctx, isbn, "")
+	bookInfo, err := s.FetchBookDetails(ctx, code, "")
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
-	authors := &strings.Builder{}
-	for _, v := range m {
-		authors.WriteString(v + "; ")
-	}
 	book := &domain.Book{
 		Title:           bookInfo.Title,
-		ISBN:            isbn,
-		Author:          authors.String(),
+		ISBN:            code,
+		Author:          strings.Join(bookInfo.Authors, "; "),
 		Publisher:       bookInfo.Publisher,
 		PublicationDate: bookInfo.PublicationDate,
 	}
@@ -74,34 +157,48 @@ func (s *BookService) CreateBookWithISBN(ctx context.Context, isbn string, initi
 	// start transaction
 	tx, err := s.bookRepo.BeginTx(ctx)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	// create book
 	if err := s.bookRepo.Create(ctx, tx, book); err != nil {
 		tx.Rollback()
-		return nil, err
+		// it was created while its details were being fetched
+		if errors.Is(err, domainErr.ErrBookExists) {
+			existing, err := s.bookRepo.GetByISBN(ctx, code)
+			if err != nil {
+				return nil, false, err
+			}
+			return existing, false, nil
+		}
+		return nil, false, err
 	}
 
-	inventory := &domain.Inventory{
-		BookID:   book.ID,
-		Quantity: initialQuantity,
-		Price:    price,
-	}
-	if err := s.inventoryRepo.Create(ctx, tx, inventory); err != nil {
+	if err := s.stockNewBook(ctx, tx, book, initialQuantity, price); err != nil {
 		tx.Rollback()
-		return nil, err
+		return nil, false, err
 	}
 
 	// commit transaction
 	if err := tx.Commit().Error; err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
-	return book, nil
+	return book, true, nil
 }
 
 func (s *BookService) CreateBook(ctx context.Context, book *domain.Book, initialQuantity int, price float64) error {
+	if err := authorize(ctx, createPermissions(initialQuantity, price)...); err != nil {
+		return err
+	}
+
+	// store every isbn in its normalized 13 digit form
+	code, err := isbn.Normalize(book.ISBN)
+	if err != nil {
+		return err
+	}
+	book.ISBN = code
+
 	// start transaction
 	tx, err := s.bookRepo.BeginTx(ctx)
 	if err != nil {
@@ -114,12 +211,7 @@ func (s *BookService) CreateBook(ctx context.Context, book *domain.Book, initial
 		return err
 	}
 
-	inventory := &domain.Inventory{
-		BookID:   book.ID,
-		Quantity: initialQuantity,
-		Price:    price,
-	}
-	if err := s.inventoryRepo.Create(ctx, tx, inventory); err != nil {
+	if err := s.stockNewBook(ctx, tx, book, initialQuantity, price); err != nil {
 		tx.Rollback()
 		return err
 	}
@@ -131,48 +223,244 @@ func (s *BookService) CreateBook(ctx context.Context, book *domain.Book, initial
 	return nil
 }
 
+// stockNewBook creates the inventory of a new book, recording its initial
+// quantity as received at the default location
+func (s *BookService) stockNewBook(ctx context.Context, tx *gorm.DB, book *domain.Book, initialQuantity int, price float64) error {
+	inventory := &domain.Inventory{
+		BookID: book.ID,
+		Price:  price,
+	}
+	if err := s.inventoryRepo.Create(ctx, tx, inventory); err != nil {
+		return err
+	}
+	if initialQuantity == 0 {
+		return nil
+	}
+
+	location, err := s.locationRepo.GetDefault(ctx)
+	if err != nil {
+		return err
+	}
+	return s.inventoryRepo.Move(ctx, tx, newMovement(ctx, book.ID, location.ID, initialQuantity, domain.MovementReceived, ""))
+}
+
+// createPermissions returns the permissions needed to add a book with
+// stock: stocking it and pricing it are separate from cataloging it
+func createPermissions(initialQuantity int, price float64) []domain.Permission {
+	perms := []domain.Permission{domain.PermEditCatalog}
+	if initialQuantity != 0 {
+		perms = append(perms, domain.PermAdjustInventory)
+	}
+	if price != 0 {
+		perms = append(perms, domain.PermChangePrice)
+	}
+	return perms
+}
+
 func (s *BookService) UpdateBook(ctx context.Context, book *domain.Book) error {
-	return s.bookRepo.Update(ctx, book)
+	if err := authorize(ctx, domain.PermEditCatalog); err != nil {
+		return err
+	}
+
+	code, err := isbn.Normalize(book.ISBN)
+	if err != nil {
+		return err
+	}
+	book.ISBN = code
+
+	return s.bookRepo.Update(ctx, nil, book)
 }
 
+// DeleteBook deletes a book together with its stock. The stock left at
+// each location is written off in the ledger first, so the copies don't
+// vanish from it unaccounted.
 func (s *BookService) DeleteBook(ctx context.Context, id string) error {
-	return s.bookRepo.Delete(ctx, id)
+	if err := authorize(ctx, domain.PermDeleteBook); err != nil {
+		return err
+	}
+
+	bookID, err := uuid.Parse(id)
+	if err != nil {
+		return domainErr.InvalidID(id)
+	}
+
+	tx, err := s.bookRepo.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	// a sale after the stock is read would otherwise escape the write-off
+	if _, err := s.inventoryRepo.GetByBookIDsForUpdate(ctx, tx, []uuid.UUID{bookID}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	stocks, err := s.inventoryRepo.GetLocationStocks(ctx, tx, nil, []uuid.UUID{bookID})
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, stock := range stocks {
+		if stock.Quantity == 0 {
+			continue
+		}
+		movement := newMovement(ctx, bookID, stock.LocationID, -stock.Quantity, domain.MovementAdjustment, "book deleted")
+		if err := s.inventoryRepo.Move(ctx, tx, movement); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := s.bookRepo.Delete(ctx, tx, id); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
 }
 
 func (s *BookService) GetBookByID(ctx context.Context, id string) (*domain.Book, error) {
+	if err := authorize(ctx, domain.PermReadCatalog); err != nil {
+		return nil, err
+	}
+
 	return s.bookRepo.GetByID(ctx, id)
 }
 
 func (s *BookService) SearchBook(ctx context.Context, query string, page, pageSize int) ([]domain.Book, int64, error) {
+	if err := authorize(ctx, domain.PermReadCatalog); err != nil {
+		return nil, 0, err
+	}
+
 	offset := (page - 1) * pageSize
 	return s.bookRepo.Search(ctx, query, offset, pageSize)
 }
 
-func (s *BookService) UpdateInventory(ctx context.Context, bookID string, quantityChange int) error {
+// BrowseBooks lists the books matching query and facets, newest first
+func (s *BookService) BrowseBooks(ctx context.Context, query string, facets domain.BookFacets, page, pageSize int) ([]domain.Book, int64, error) {
+	if err := authorize(ctx, domain.PermReadCatalog); err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	switch {
+	case facets != domain.BookFacets{}:
+		return s.bookRepo.SearchFaceted(ctx, query, facets, offset, pageSize)
+	case query != "":
+		return s.bookRepo.Search(ctx, query, offset, pageSize)
+	default:
+		return s.bookRepo.List(ctx, pageSize, offset)
+	}
+}
+
+// FacetCounts returns a page of the values of a facet field among the books
+// BrowseBooks would list, most common first
+func (s *BookService) FacetCounts(ctx context.Context, field, query string, facets domain.BookFacets, page, pageSize int) ([]domain.FacetCount, int64, error) {
+	if err := authorize(ctx, domain.PermReadCatalog); err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	return s.bookRepo.FacetCounts(ctx, field, query, facets, offset, pageSize)
+}
+
+// ExportCatalog calls fn for every book matching query, with its stock. The
+// books are streamed from the database rather than loaded at once.
+func (s *BookService) ExportCatalog(ctx context.Context, query string, fn func(entry *domain.CatalogEntry) error) error {
+	if err := authorize(ctx, domain.PermReadCatalog); err != nil {
+		return err
+	}
+
+	return s.bookRepo.StreamCatalog(ctx, query, fn)
+}
+
+// UpdateInventory sells or restocks copies of a book and sets its price.
+// Changing the quantity and changing the price are separate permissions, so
+// a clerk may sell stock but not reprice it.
+func (s *BookService) UpdateInventory(ctx context.Context, bookID string, update InventoryUpdate) error {
+	var perms []domain.Permission
+	if update.QuantityChange != 0 {
+		perms = append(perms, domain.PermAdjustInventory)
+	}
+	if update.Price != nil {
+		perms = append(perms, domain.PermChangePrice)
+	}
+	if err := authorize(ctx, perms...); err != nil {
+		return err
+	}
+
+	if update.Price != nil && *update.Price < 0 {
+		return domainErr.ErrInvalidPrice
+	}
+
 	// verify the book exists
-	_, err := s.bookRepo.GetByID(ctx, bookID)
+	book, err := s.bookRepo.GetByID(ctx, bookID)
 	if err != nil {
 		return err
 	}
 
-	// get current inventory
-	inventory, err := s.inventoryRepo.GetByBookID(ctx, bookID)
+	var location *domain.Location
+	if update.QuantityChange != 0 {
+		if location, err = resolveLocation(ctx, s.locationRepo, update.Location); err != nil {
+			return err
+		}
+	}
+
+	tx, err := s.bookRepo.BeginTx(ctx)
 	if err != nil {
 		return err
 	}
 
-	// check if we have enough stock to sell
-	if quantityChange < 0 && (inventory.Quantity+quantityChange) < 0 {
-		return domainErr.ErrInsufficientStock
+	if update.QuantityChange != 0 {
+		reason := update.Reason
+		switch {
+		case reason != "":
+		case update.QuantityChange < 0:
+			reason = domain.MovementSold
+		default:
+			reason = domain.MovementAdjustment
+		}
+
+		// the stock check happens in the update itself, so concurrent sales
+		// can't both take the last copy
+		movement := newMovement(ctx, book.ID, location.ID, update.QuantityChange, reason, update.Reference)
+		if err := s.inventoryRepo.Move(ctx, tx, movement); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if update.Price != nil {
+		if err := s.inventoryRepo.UpdatePrice(ctx, tx, bookID, *update.Price); err != nil {
+			tx.Rollback()
+			return err
+		}
 	}
 
-	return s.inventoryRepo.UpdateQuantity(ctx, bookID, quantityChange)
+	return tx.Commit().Error
 }
 
+// GetInventory returns a book's total stock and its stock at each location
 func (s *BookService) GetInventory(ctx context.Context, bookID string) (*domain.Inventory, error) {
+	if err := authorize(ctx, domain.PermReadCatalog); err != nil {
+		return nil, err
+	}
+
 	return s.inventoryRepo.GetByBookID(ctx, bookID)
 }
 
-func (s *BookService) GetLowStockBooks(ctx context.Context, threshold int) ([]domain.Inventory, error) {
-	return s.inventoryRepo.ListLowStock(ctx, threshold)
+// GetLowStockBooks returns the books with at most threshold copies in total
+// or, given a location code, at that location
+func (s *BookService) GetLowStockBooks(ctx context.Context, threshold int, location string) ([]domain.Inventory, error) {
+	if err := authorize(ctx, domain.PermReadCatalog); err != nil {
+		return nil, err
+	}
+
+	if location == "" {
+		return s.inventoryRepo.ListLowStock(ctx, threshold, nil)
+	}
+
+	loc, err := s.locationRepo.GetByCode(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+	return s.inventoryRepo.ListLowStock(ctx, threshold, &loc.ID)
 }
@@ -0,0 +1,262 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/gracchi-stdio/barf/internal/domain"
+	"github.com/gracchi-stdio/barf/internal/repository"
+	domainErr "github.com/gracchi-stdio/barf/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"strings"
+	"time"
+)
+
+// StockService keeps the locations stock is held at, moves stock between
+// them, and reads the stock ledger and checks it against the stored
+// quantities.
+type StockService struct {
+	bookRepo      repository.BookRepository
+	inventoryRepo repository.InventoryRepository
+	locationRepo  repository.LocationRepository
+	movementRepo  repository.StockMovementRepository
+}
+
+func NewStockService(
+	bookRepo repository.BookRepository,
+	inventoryRepo repository.InventoryRepository,
+	locationRepo repository.LocationRepository,
+	movementRepo repository.StockMovementRepository,
+) *StockService {
+	return &StockService{
+		bookRepo:      bookRepo,
+		inventoryRepo: inventoryRepo,
+		locationRepo:  locationRepo,
+		movementRepo:  movementRepo,
+	}
+}
+
+// StockTransfer moves Quantity copies of a book from one location to
+// another, both given by code. Reference names the document behind the
+// transfer, such as a delivery note; one is made up when it is empty.
+type StockTransfer struct {
+	From      string
+	To        string
+	Quantity  int
+	Reference string
+}
+
+// ListLocations returns every location, the default first
+func (s *StockService) ListLocations(ctx context.Context) ([]domain.Location, error) {
+	if err := authorize(ctx, domain.PermReadCatalog); err != nil {
+		return nil, err
+	}
+
+	return s.locationRepo.List(ctx)
+}
+
+// CreateLocation adds a store or warehouse. Codes are unique regardless of
+// case.
+func (s *StockService) CreateLocation(ctx context.Context, location *domain.Location) error {
+	if err := authorize(ctx, domain.PermManageLocations); err != nil {
+		return err
+	}
+
+	location.Code = strings.TrimSpace(location.Code)
+	if location.Kind == "" {
+		location.Kind = domain.LocationStore
+	}
+	// there is one default location, made at startup
+	location.IsDefault = false
+
+	if _, err := s.locationRepo.GetByCode(ctx, location.Code); err == nil {
+		return domainErr.New(domainErr.KindConflict, "location_exists", "a location with this code already exists")
+	} else if !errors.Is(err, domainErr.ErrNotFound) {
+		return err
+	}
+
+	return s.locationRepo.Create(ctx, location)
+}
+
+// Transfer moves copies of a book between locations. Both movements are
+// written in one transaction, so the copies are never counted at both
+// locations or at neither, and the source must hold them: a concurrent sale
+// of the same copies makes one of the two fail with ErrInsufficientStock.
+func (s *StockService) Transfer(ctx context.Context, bookID string, transfer StockTransfer) (*domain.Transfer, error) {
+	if err := authorize(ctx, domain.PermAdjustInventory); err != nil {
+		return nil, err
+	}
+
+	book, err := s.bookRepo.GetByID(ctx, bookID)
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := resolveLocation(ctx, s.locationRepo, transfer.From)
+	if err != nil {
+		return nil, err
+	}
+	to, err := resolveLocation(ctx, s.locationRepo, transfer.To)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []domainErr.FieldError
+	if transfer.Quantity < 1 {
+		fields = append(fields, domainErr.FieldError{Field: "quantity", Code: "min", Message: "must be at least 1"})
+	}
+	if from.ID == to.ID {
+		fields = append(fields, domainErr.FieldError{Field: "to", Code: "same_location", Message: "must differ from the source location"})
+	}
+	if len(fields) > 0 {
+		return nil, domainErr.Validation("transfer is invalid", fields...)
+	}
+
+	reference := transfer.Reference
+	if reference == "" {
+		reference = fmt.Sprintf("transfer %s from %s to %s", uuid.NewString(), from.Code, to.Code)
+	}
+
+	out := newMovement(ctx, book.ID, from.ID, -transfer.Quantity, domain.MovementTransfer, reference)
+	in := newMovement(ctx, book.ID, to.ID, transfer.Quantity, domain.MovementTransfer, reference)
+
+	tx, err := s.bookRepo.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, movement := range []*domain.StockMovement{out, in} {
+		if err := s.inventoryRepo.Move(ctx, tx, movement); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return &domain.Transfer{Reference: reference, Out: *out, In: *in}, nil
+}
+
+// SetBin files a book's copies at a location under a bin or shelf code, or
+// clears it when bin is empty
+func (s *StockService) SetBin(ctx context.Context, bookID, location, bin string) (*domain.LocationStock, error) {
+	if err := authorize(ctx, domain.PermAdjustInventory); err != nil {
+		return nil, err
+	}
+
+	book, err := s.bookRepo.GetByID(ctx, bookID)
+	if err != nil {
+		return nil, err
+	}
+
+	loc, err := resolveLocation(ctx, s.locationRepo, location)
+	if err != nil {
+		return nil, err
+	}
+
+	stock, err := s.inventoryRepo.SetBin(ctx, book.ID, loc.ID, strings.TrimSpace(bin))
+	if err != nil {
+		return nil, err
+	}
+	stock.Location = loc
+	return stock, nil
+}
+
+// ListMovements returns a page of a book's stock movements, newest first
+func (s *StockService) ListMovements(ctx context.Context, bookID string, page, pageSize int) ([]domain.StockMovement, int64, error) {
+	if err := authorize(ctx, domain.PermReadCatalog); err != nil {
+		return nil, 0, err
+	}
+
+	if _, err := s.bookRepo.GetByID(ctx, bookID); err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	return s.movementRepo.ListByBook(ctx, bookID, offset, pageSize)
+}
+
+// Reconcile returns the books whose quantity, in total or at a location, is
+// not the sum of their ledger, logging each. Drift means stock was changed behind the ledger's back, e.g.
+// by hand in the database.
+func (s *StockService) Reconcile(ctx context.Context) ([]domain.StockDrift, error) {
+	if err := authorize(ctx, domain.PermReadCatalog); err != nil {
+		return nil, err
+	}
+
+	drift, err := s.movementRepo.FindDrift(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, d := range drift {
+		location := "total"
+		if d.LocationID != nil {
+			location = d.LocationID.String()
+		}
+		log.Warn().
+			Str("book_id", d.BookID.String()).
+			Str("location_id", location).
+			Int("quantity", d.Quantity).
+			Int("ledger_quantity", d.LedgerQuantity).
+			Int("drift", d.Drift).
+			Msg("stock differs from ledger")
+	}
+	return drift, nil
+}
+
+// RunReconciliation reconciles the stock every interval until ctx is done.
+// It runs as a system principal with the viewer role.
+func (s *StockService) RunReconciliation(ctx context.Context, interval time.Duration) {
+	ctx = domain.WithPrincipal(ctx, domain.SystemPrincipal("stock reconciliation", domain.RoleViewer))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		drift, err := s.Reconcile(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("stock reconciliation failed")
+		} else {
+			log.Info().Int("drifted", len(drift)).Msg("stock reconciled")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// newMovement returns a ledger entry for a change of a book's stock at a
+// location, made by the principal on ctx
+func newMovement(ctx context.Context, bookID, locationID uuid.UUID, delta int, reason domain.MovementReason, reference string) *domain.StockMovement {
+	movement := &domain.StockMovement{
+		BookID:     bookID,
+		LocationID: locationID,
+		Delta:      delta,
+		Reason:     reason,
+		Reference:  reference,
+		ActorKind:  domain.PrincipalSystem,
+	}
+	if principal, ok := domain.PrincipalFrom(ctx); ok {
+		movement.ActorKind = principal.Kind
+		movement.ActorName = principal.Name
+		if principal.ID != uuid.Nil {
+			id := principal.ID
+			movement.ActorID = &id
+		}
+	}
+	return movement
+}
+
+// resolveLocation returns the location with code, or the default location
+// when code is empty
+func resolveLocation(ctx context.Context, locationRepo repository.LocationRepository, code string) (*domain.Location, error) {
+	if code = strings.TrimSpace(code); code == "" {
+		return locationRepo.GetDefault(ctx)
+	}
+	return locationRepo.GetByCode(ctx, code)
+}
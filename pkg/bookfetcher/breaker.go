@@ -0,0 +1,278 @@
+package bookfetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen wraps ErrProviderError so a chain moves on to the next provider
+var ErrCircuitOpen = fmt.Errorf("%w: circuit open", ErrProviderError)
+
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"
+	CircuitOpen     CircuitState = "open"
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+// BreakerConfig controls when a circuit opens. It opens after
+// FailureThreshold consecutive failures, or when the error rate over the last
+// Window calls reaches ErrorRateThreshold. After OpenTimeout a single trial
+// call is let through.
+type BreakerConfig struct {
+	FailureThreshold   int
+	ErrorRateThreshold float64
+	Window             int
+	OpenTimeout        time.Duration
+}
+
+var DefaultBreakerConfig = BreakerConfig{
+	FailureThreshold:   5,
+	ErrorRateThreshold: 0.5,
+	Window:             20,
+	OpenTimeout:        30 * time.Second,
+}
+
+// ProviderStatus is a snapshot of a provider's circuit and health
+type ProviderStatus struct {
+	Name        string       `json:"name"`
+	State       CircuitState `json:"state"`
+	Healthy     bool         `json:"healthy"`
+	LastProbe   *time.Time   `json:"last_probe,omitempty"`
+	Calls       int64        `json:"calls"`
+	Failures    int64        `json:"failures"`
+	ErrorRate   float64      `json:"error_rate"`
+	LastError   string       `json:"last_error,omitempty"`
+	LastErrorAt *time.Time   `json:"last_error_at,omitempty"`
+	OpenedAt    *time.Time   `json:"opened_at,omitempty"`
+}
+
+// CircuitBreakerFetcher decorates a BookFetcher with a circuit breaker. While
+// the circuit is open calls fail fast with ErrCircuitOpen. Not found and
+// invalid ISBN answers count as successful calls; canceled calls don't count.
+type CircuitBreakerFetcher struct {
+	fetcher BookFetcher
+	config  BreakerConfig
+
+	mu          sync.Mutex
+	state       CircuitState
+	consecutive int
+	window      []bool
+	next        int
+	trial       bool
+	healthy     bool
+	lastProbe   time.Time
+	calls       int64
+	failures    int64
+	lastError   string
+	lastErrorAt time.Time
+	openedAt    time.Time
+}
+
+func NewCircuitBreakerFetcher(fetcher BookFetcher, config BreakerConfig) *CircuitBreakerFetcher {
+	if config.Window < 1 {
+		config.Window = 1
+	}
+
+	return &CircuitBreakerFetcher{
+		fetcher: fetcher,
+		config:  config,
+		state:   CircuitClosed,
+		window:  make([]bool, 0, config.Window),
+		healthy: true,
+	}
+}
+
+func (b *CircuitBreakerFetcher) GetBookByISBN(ctx context.Context, isbn string) (*BookInfo, error) {
+	if err := b.allow(); err != nil {
+		return nil, err
+	}
+
+	info, err := b.fetcher.GetBookByISBN(ctx, isbn)
+	b.record(err)
+	return info, err
+}
+
+func (b *CircuitBreakerFetcher) SearchBooks(ctx context.Context, opts SearchOptions) (*SearchResult, error) {
+	if err := b.allow(); err != nil {
+		return nil, err
+	}
+
+	result, err := b.fetcher.SearchBooks(ctx, opts)
+	b.record(err)
+	return result, err
+}
+
+func (b *CircuitBreakerFetcher) Name() string {
+	return b.fetcher.Name()
+}
+
+// IsHealthy returns false while the circuit is open
+func (b *CircuitBreakerFetcher) IsHealthy(ctx context.Context) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state != CircuitOpen && b.healthy
+}
+
+// Probe calls the wrapped fetcher's IsHealthy. A failed probe opens the
+// circuit; a successful probe lets an open circuit try a call.
+func (b *CircuitBreakerFetcher) Probe(ctx context.Context) bool {
+	healthy := b.fetcher.IsHealthy(ctx)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.healthy = healthy
+	b.lastProbe = time.Now()
+	switch {
+	case !healthy && b.state != CircuitOpen:
+		b.open()
+	case healthy && b.state == CircuitOpen:
+		b.state = CircuitHalfOpen
+		b.trial = false
+	}
+	return healthy
+}
+
+// Status returns a snapshot of the circuit
+func (b *CircuitBreakerFetcher) Status() ProviderStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := ProviderStatus{
+		Name:      b.fetcher.Name(),
+		State:     b.state,
+		Healthy:   b.healthy,
+		Calls:     b.calls,
+		Failures:  b.failures,
+		ErrorRate: b.errorRate(),
+		LastError: b.lastError,
+	}
+	if !b.lastProbe.IsZero() {
+		t := b.lastProbe
+		status.LastProbe = &t
+	}
+	if !b.lastErrorAt.IsZero() {
+		t := b.lastErrorAt
+		status.LastErrorAt = &t
+	}
+	if b.state != CircuitClosed {
+		t := b.openedAt
+		status.OpenedAt = &t
+	}
+	return status
+}
+
+func (b *CircuitBreakerFetcher) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitOpen && time.Since(b.openedAt) >= b.config.OpenTimeout {
+		b.state = CircuitHalfOpen
+		b.trial = false
+	}
+
+	switch b.state {
+	case CircuitOpen:
+		return fmt.Errorf("%s: %w", b.fetcher.Name(), ErrCircuitOpen)
+	case CircuitHalfOpen:
+		if b.trial {
+			return fmt.Errorf("%s: %w", b.fetcher.Name(), ErrCircuitOpen)
+		}
+		b.trial = true
+	}
+	return nil
+}
+
+func (b *CircuitBreakerFetcher) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// a canceled call says nothing about the provider, so it leaves the
+	// circuit as it was and a half-open circuit lets the next call try
+	if errors.Is(err, context.Canceled) {
+		b.trial = false
+		return
+	}
+
+	failed := isBreakerFailure(err)
+
+	b.calls++
+	if len(b.window) < b.config.Window {
+		b.window = append(b.window, failed)
+	} else {
+		b.window[b.next] = failed
+	}
+	b.next = (b.next + 1) % b.config.Window
+
+	if !failed {
+		b.consecutive = 0
+		if b.state == CircuitHalfOpen {
+			b.state = CircuitClosed
+			b.trial = false
+			b.window = b.window[:0]
+			b.next = 0
+		}
+		return
+	}
+
+	b.failures++
+	b.consecutive++
+	b.lastError = errorText(err)
+	b.lastErrorAt = time.Now()
+
+	switch {
+	case b.state == CircuitHalfOpen:
+		b.open()
+	case b.config.FailureThreshold > 0 && b.consecutive >= b.config.FailureThreshold:
+		b.open()
+	case len(b.window) == b.config.Window && b.config.ErrorRateThreshold > 0 &&
+		b.errorRate() >= b.config.ErrorRateThreshold:
+		b.open()
+	}
+}
+
+// open trips the circuit, the caller must hold mu
+func (b *CircuitBreakerFetcher) open() {
+	b.state = CircuitOpen
+	b.openedAt = time.Now()
+	b.trial = false
+}
+
+// errorRate is the share of failed calls in the window, the caller must hold mu
+func (b *CircuitBreakerFetcher) errorRate() float64 {
+	if len(b.window) == 0 {
+		return 0
+	}
+
+	failed := 0
+	for _, f := range b.window {
+		if f {
+			failed++
+		}
+	}
+	return float64(failed) / float64(len(b.window))
+}
+
+// errorText is err's message without the url of a failed request, which
+// may carry credentials in its query
+func errorText(err error) string {
+	text := err.Error()
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		text = strings.ReplaceAll(text, urlErr.Error(), urlErr.Op+": "+urlErr.Err.Error())
+	}
+	return text
+}
+
+func isBreakerFailure(err error) bool {
+	return err != nil &&
+		!errors.Is(err, ErrBookNotFound) &&
+		!errors.Is(err, ErrInvalidISBN)
+}
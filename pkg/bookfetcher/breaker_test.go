@@ -0,0 +1,83 @@
+package bookfetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// halfOpenBreaker returns a breaker around fetcher whose circuit has opened
+// and timed out, so its next call is the half-open trial
+func halfOpenBreaker(t *testing.T, fetcher *stubFetcher) *CircuitBreakerFetcher {
+	t.Helper()
+
+	breaker := NewCircuitBreakerFetcher(fetcher, BreakerConfig{FailureThreshold: 1, Window: 1, OpenTimeout: time.Millisecond})
+	fetcher.err = fmt.Errorf("%w: unavailable", ErrProviderError)
+	if _, err := breaker.GetBookByISBN(context.Background(), "9780306406157"); err == nil {
+		t.Fatal("GetBookByISBN succeeded, want the stub's error")
+	}
+	if state := breaker.Status().State; state != CircuitOpen {
+		t.Fatalf("state = %s, want %s", state, CircuitOpen)
+	}
+	time.Sleep(2 * time.Millisecond)
+	return breaker
+}
+
+func TestBreakerCanceledTrialKeepsState(t *testing.T) {
+	fetcher := &stubFetcher{name: "stub", info: &BookInfo{Title: "Found"}}
+	breaker := halfOpenBreaker(t, fetcher)
+
+	fetcher.err = fmt.Errorf("failed to get book: %w", context.Canceled)
+	if _, err := breaker.GetBookByISBN(context.Background(), "9780306406157"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	status := breaker.Status()
+	if status.State != CircuitHalfOpen {
+		t.Errorf("state = %s, want %s", status.State, CircuitHalfOpen)
+	}
+	if status.Calls != 1 || status.Failures != 1 {
+		t.Errorf("calls = %d, failures = %d, want the canceled call left uncounted", status.Calls, status.Failures)
+	}
+
+	// the canceled trial gives the next call its turn
+	fetcher.err = nil
+	if _, err := breaker.GetBookByISBN(context.Background(), "9780306406157"); err != nil {
+		t.Fatalf("GetBookByISBN: %v", err)
+	}
+	if state := breaker.Status().State; state != CircuitClosed {
+		t.Errorf("state = %s, want %s", state, CircuitClosed)
+	}
+}
+
+func TestBreakerFailedTrialReopens(t *testing.T) {
+	fetcher := &stubFetcher{name: "stub"}
+	breaker := halfOpenBreaker(t, fetcher)
+
+	if _, err := breaker.GetBookByISBN(context.Background(), "9780306406157"); err == nil {
+		t.Fatal("GetBookByISBN succeeded, want the stub's error")
+	}
+	if state := breaker.Status().State; state != CircuitOpen {
+		t.Errorf("state = %s, want %s", state, CircuitOpen)
+	}
+}
+
+func TestBreakerLastErrorLeavesOutURL(t *testing.T) {
+	urlErr := &url.Error{Op: "Get", URL: "https://books.example/volumes?q=isbn:9780306406157&key=secret", Err: context.DeadlineExceeded}
+	fetcher := &stubFetcher{name: "stub", err: fmt.Errorf("%w: failed to get book: %w", ErrProviderError, urlErr)}
+	breaker := NewCircuitBreakerFetcher(fetcher, DefaultBreakerConfig)
+
+	if _, err := breaker.GetBookByISBN(context.Background(), "9780306406157"); err == nil {
+		t.Fatal("GetBookByISBN succeeded, want the stub's error")
+	}
+	lastError := breaker.Status().LastError
+	if strings.Contains(lastError, "secret") || strings.Contains(lastError, "books.example") {
+		t.Errorf("last error = %q, want it without the request url", lastError)
+	}
+	if !strings.Contains(lastError, "failed to get book: Get: context deadline exceeded") {
+		t.Errorf("last error = %q, want the cause kept", lastError)
+	}
+}
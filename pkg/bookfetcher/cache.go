@@ -0,0 +1,140 @@
+package bookfetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gracchi-stdio/barf/pkg/isbn"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+var ErrCacheMiss = errors.New("cache miss")
+
+// CacheEntry is a cached lookup result. NotFound entries remember that the
+// provider has no record for the ISBN.
+type CacheEntry struct {
+	Provider  string
+	ISBN      string
+	Info      *BookInfo
+	NotFound  bool
+	FetchedAt time.Time
+	ExpiresAt time.Time
+}
+
+// CacheStore persists lookup results. Get returns ErrCacheMiss when there is
+// no entry for the provider and ISBN; expired entries are still returned.
+type CacheStore interface {
+	Get(ctx context.Context, provider, isbn string) (*CacheEntry, error)
+	Put(ctx context.Context, entry *CacheEntry) error
+}
+
+type refreshKey struct{}
+
+// WithRefresh marks ctx so caching fetchers skip cached entries and fetch
+// from the provider, storing the fresh result.
+func WithRefresh(ctx context.Context) context.Context {
+	return context.WithValue(ctx, refreshKey{}, true)
+}
+
+func isRefresh(ctx context.Context) bool {
+	refresh, _ := ctx.Value(refreshKey{}).(bool)
+	return refresh
+}
+
+// CachingFetcher decorates a BookFetcher with a persistent ISBN lookup cache.
+// Expired entries are still served when the provider fails, so lookups keep
+// working while a provider is down.
+type CachingFetcher struct {
+	fetcher     BookFetcher
+	store       CacheStore
+	ttl         time.Duration
+	negativeTTL time.Duration
+	now         func() time.Time
+}
+
+func NewCachingFetcher(fetcher BookFetcher, store CacheStore, ttl, negativeTTL time.Duration) *CachingFetcher {
+	return &CachingFetcher{
+		fetcher:     fetcher,
+		store:       store,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		now:         time.Now,
+	}
+}
+
+func (c *CachingFetcher) GetBookByISBN(ctx context.Context, code string) (*BookInfo, error) {
+	code, err := isbn.Normalize(code)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidISBN, err)
+	}
+
+	entry, err := c.store.Get(ctx, c.Name(), code)
+	if err != nil && !errors.Is(err, ErrCacheMiss) {
+		log.Warn().Err(err).Str("provider", c.Name()).Msg("failed to read lookup cache")
+	}
+
+	now := c.now()
+	if entry != nil && !isRefresh(ctx) && now.Before(entry.ExpiresAt) {
+		if entry.NotFound {
+			return nil, ErrBookNotFound
+		}
+		return entry.Info, nil
+	}
+
+	info, err := c.fetcher.GetBookByISBN(ctx, code)
+	switch {
+	case err == nil:
+		c.put(ctx, &CacheEntry{
+			Provider:  c.Name(),
+			ISBN:      code,
+			Info:      info,
+			FetchedAt: now,
+			ExpiresAt: now.Add(c.ttl),
+		})
+		return info, nil
+	case errors.Is(err, ErrBookNotFound):
+		c.put(ctx, &CacheEntry{
+			Provider:  c.Name(),
+			ISBN:      code,
+			NotFound:  true,
+			FetchedAt: now,
+			ExpiresAt: now.Add(c.negativeTTL),
+		})
+		return nil, err
+	case entry != nil && !entry.NotFound:
+		// provider is failing, fall back to the stale entry
+		log.Warn().Err(err).
+			Str("provider", c.Name()).
+			Str("isbn", code).
+			Time("fetched_at", entry.FetchedAt).
+			Msg("serving stale lookup from cache")
+		return entry.Info, nil
+	default:
+		return nil, err
+	}
+}
+
+// Refresh fetches isbn from the provider, bypassing and updating the cache
+func (c *CachingFetcher) Refresh(ctx context.Context, code string) (*BookInfo, error) {
+	return c.GetBookByISBN(WithRefresh(ctx), code)
+}
+
+// SearchBooks is not cached
+func (c *CachingFetcher) SearchBooks(ctx context.Context, opts SearchOptions) (*SearchResult, error) {
+	return c.fetcher.SearchBooks(ctx, opts)
+}
+
+func (c *CachingFetcher) Name() string {
+	return c.fetcher.Name()
+}
+
+func (c *CachingFetcher) IsHealthy(ctx context.Context) bool {
+	return c.fetcher.IsHealthy(ctx)
+}
+
+func (c *CachingFetcher) put(ctx context.Context, entry *CacheEntry) {
+	if err := c.store.Put(ctx, entry); err != nil {
+		log.Warn().Err(err).Str("provider", entry.Provider).Msg("failed to write lookup cache")
+	}
+}
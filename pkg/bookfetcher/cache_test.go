@@ -0,0 +1,178 @@
+package bookfetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryStore is a CacheStore in a map
+type memoryStore struct {
+	mu      sync.Mutex
+	entries map[string]CacheEntry
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{entries: map[string]CacheEntry{}}
+}
+
+func (s *memoryStore) Get(ctx context.Context, provider, isbn string) (*CacheEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[provider+"/"+isbn]
+	if !ok {
+		return nil, ErrCacheMiss
+	}
+	return &entry, nil
+}
+
+func (s *memoryStore) Put(ctx context.Context, entry *CacheEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[entry.Provider+"/"+entry.ISBN] = *entry
+	return nil
+}
+
+// cachingFetcher caches stub with a ttl of an hour and a negative ttl of a
+// minute on a clock that only moves when the test advances it
+func cachingFetcher(stub *stubFetcher, store CacheStore) (*CachingFetcher, func(time.Duration)) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fetcher := NewCachingFetcher(stub, store, time.Hour, time.Minute)
+	fetcher.now = func() time.Time { return now }
+	return fetcher, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestCacheTTL(t *testing.T) {
+	stub := &stubFetcher{name: "stub", info: &BookInfo{Title: "Dune"}}
+	store := newMemoryStore()
+	fetcher, advance := cachingFetcher(stub, store)
+	ctx := context.Background()
+
+	for range 2 {
+		info, err := fetcher.GetBookByISBN(ctx, "978-0-306-40615-7")
+		if err != nil || info.Title != "Dune" {
+			t.Fatalf("GetBookByISBN = %+v, %v", info, err)
+		}
+	}
+	if stub.calls != 1 {
+		t.Errorf("calls = %d, want the second lookup served from the cache", stub.calls)
+	}
+
+	// the isbn was normalized before it was used as the key
+	entry, err := store.Get(ctx, "stub", "9780306406157")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := entry.FetchedAt.Add(time.Hour); !entry.ExpiresAt.Equal(want) {
+		t.Errorf("expires at %v, want %v", entry.ExpiresAt, want)
+	}
+
+	advance(59 * time.Minute)
+	if _, err := fetcher.GetBookByISBN(ctx, "9780306406157"); err != nil {
+		t.Fatal(err)
+	}
+	if stub.calls != 1 {
+		t.Errorf("calls = %d, want a lookup within the ttl served from the cache", stub.calls)
+	}
+
+	advance(time.Minute)
+	stub.info = &BookInfo{Title: "Dune (revised)"}
+	info, err := fetcher.GetBookByISBN(ctx, "9780306406157")
+	if err != nil || info.Title != "Dune (revised)" {
+		t.Fatalf("GetBookByISBN after the ttl = %+v, %v, want the provider's new answer", info, err)
+	}
+	if stub.calls != 2 {
+		t.Errorf("calls = %d, want an expired entry fetched again", stub.calls)
+	}
+
+	if _, err := fetcher.Refresh(ctx, "9780306406157"); err != nil {
+		t.Fatal(err)
+	}
+	if stub.calls != 3 {
+		t.Errorf("calls = %d, want Refresh to skip the cache", stub.calls)
+	}
+}
+
+func TestCacheNegativeTTL(t *testing.T) {
+	stub := &stubFetcher{name: "stub", err: fmt.Errorf("lookup: %w", ErrBookNotFound)}
+	fetcher, advance := cachingFetcher(stub, newMemoryStore())
+	ctx := context.Background()
+
+	for range 2 {
+		if _, err := fetcher.GetBookByISBN(ctx, "9780306406157"); !errors.Is(err, ErrBookNotFound) {
+			t.Fatalf("GetBookByISBN = %v, want ErrBookNotFound", err)
+		}
+	}
+	if stub.calls != 1 {
+		t.Errorf("calls = %d, want the missing book cached", stub.calls)
+	}
+
+	// a book added by the provider shows up once the negative ttl is over
+	advance(time.Minute)
+	stub.err = nil
+	stub.info = &BookInfo{Title: "Dune"}
+	info, err := fetcher.GetBookByISBN(ctx, "9780306406157")
+	if err != nil || info.Title != "Dune" {
+		t.Fatalf("GetBookByISBN after the negative ttl = %+v, %v", info, err)
+	}
+	if stub.calls != 2 {
+		t.Errorf("calls = %d, want 2", stub.calls)
+	}
+}
+
+func TestCacheServesStaleOnError(t *testing.T) {
+	stub := &stubFetcher{name: "stub", info: &BookInfo{Title: "Dune"}}
+	fetcher, advance := cachingFetcher(stub, newMemoryStore())
+	ctx := context.Background()
+
+	if _, err := fetcher.GetBookByISBN(ctx, "9780306406157"); err != nil {
+		t.Fatal(err)
+	}
+
+	advance(2 * time.Hour)
+	stub.info = nil
+	stub.err = fmt.Errorf("%w: status 503", ErrProviderError)
+	info, err := fetcher.GetBookByISBN(ctx, "9780306406157")
+	if err != nil || info.Title != "Dune" {
+		t.Fatalf("GetBookByISBN = %+v, %v, want the stale entry", info, err)
+	}
+	if stub.calls != 2 {
+		t.Errorf("calls = %d, want the provider asked before falling back", stub.calls)
+	}
+
+	// a not found answer is not hidden by the stale entry
+	stub.err = ErrBookNotFound
+	if _, err := fetcher.GetBookByISBN(ctx, "9780306406157"); !errors.Is(err, ErrBookNotFound) {
+		t.Errorf("GetBookByISBN = %v, want ErrBookNotFound", err)
+	}
+}
+
+func TestCacheNoStaleEntry(t *testing.T) {
+	ctx := context.Background()
+
+	// without an entry the provider error is returned
+	stub := &stubFetcher{name: "stub", err: fmt.Errorf("%w: status 503", ErrProviderError)}
+	fetcher, _ := cachingFetcher(stub, newMemoryStore())
+	if _, err := fetcher.GetBookByISBN(ctx, "9780306406157"); !errors.Is(err, ErrProviderError) {
+		t.Errorf("GetBookByISBN = %v, want ErrProviderError", err)
+	}
+
+	// an expired not found entry is not served in place of a failure either
+	stub = &stubFetcher{name: "stub", err: ErrBookNotFound}
+	fetcher, advance := cachingFetcher(stub, newMemoryStore())
+	fetcher.GetBookByISBN(ctx, "9780306406157")
+	advance(time.Hour)
+	stub.err = fmt.Errorf("%w: status 503", ErrProviderError)
+	if _, err := fetcher.GetBookByISBN(ctx, "9780306406157"); !errors.Is(err, ErrProviderError) {
+		t.Errorf("GetBookByISBN = %v, want ErrProviderError", err)
+	}
+
+	if _, err := fetcher.GetBookByISBN(ctx, "not an isbn"); !errors.Is(err, ErrInvalidISBN) {
+		t.Errorf("GetBookByISBN = %v, want ErrInvalidISBN", err)
+	}
+}
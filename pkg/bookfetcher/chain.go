@@ -0,0 +1,103 @@
+package bookfetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// ChainFetcher queries a list of fetchers in order and returns the first
+// successful result. Not found, rate limit, provider and network errors move
+// the lookup on to the next fetcher; any other error, or the end of the
+// caller's context, stops the chain.
+type ChainFetcher struct {
+	fetchers []BookFetcher
+}
+
+func NewChainFetcher(fetchers ...BookFetcher) *ChainFetcher {
+	return &ChainFetcher{
+		fetchers: fetchers,
+	}
+}
+
+// IsFallbackError reports whether err allows the chain to try the next
+// fetcher. A provider that cannot be reached or does not answer in time
+// counts, whether or not it wrapped the error in ErrProviderError.
+func IsFallbackError(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, ErrBookNotFound) ||
+		errors.Is(err, ErrRateLimitExceeded) ||
+		errors.Is(err, ErrProviderError) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.As(err, &netErr)
+}
+
+// Fetchers returns the fetchers in the order they are tried.
+func (c *ChainFetcher) Fetchers() []BookFetcher {
+	return c.fetchers
+}
+
+func (c *ChainFetcher) GetBookByISBN(ctx context.Context, isbn string) (*BookInfo, error) {
+	errs := make([]error, 0, len(c.fetchers))
+	for _, fetcher := range c.fetchers {
+		info, err := fetcher.GetBookByISBN(ctx, isbn)
+		if err == nil {
+			if info.Provider == "" {
+				info.Provider = fetcher.Name()
+			}
+			return info, nil
+		}
+		if !IsFallbackError(err) || ctx.Err() != nil {
+			return nil, fmt.Errorf("%s: %w", fetcher.Name(), err)
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", fetcher.Name(), err))
+	}
+
+	return nil, chainError(errs)
+}
+
+func (c *ChainFetcher) SearchBooks(ctx context.Context, opts SearchOptions) (*SearchResult, error) {
+	errs := make([]error, 0, len(c.fetchers))
+	for _, fetcher := range c.fetchers {
+		result, err := fetcher.SearchBooks(ctx, opts)
+		if err == nil {
+			return result, nil
+		}
+		if !IsFallbackError(err) || ctx.Err() != nil {
+			return nil, fmt.Errorf("%s: %w", fetcher.Name(), err)
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", fetcher.Name(), err))
+	}
+
+	return nil, chainError(errs)
+}
+
+func (c *ChainFetcher) Name() string {
+	return "chain"
+}
+
+// IsHealthy returns true if at least one fetcher in the chain is healthy
+func (c *ChainFetcher) IsHealthy(ctx context.Context) bool {
+	for _, fetcher := range c.fetchers {
+		if fetcher.IsHealthy(ctx) {
+			return true
+		}
+	}
+	return false
+}
+
+// chainError collapses the errors of an exhausted chain. When every fetcher
+// reported the book as missing the result is plain ErrBookNotFound.
+func chainError(errs []error) error {
+	if len(errs) == 0 {
+		return ErrNoProviders
+	}
+
+	for _, err := range errs {
+		if !errors.Is(err, ErrBookNotFound) {
+			return errors.Join(errs...)
+		}
+	}
+	return ErrBookNotFound
+}
@@ -0,0 +1,101 @@
+package bookfetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"testing"
+)
+
+// stubFetcher answers every lookup with info or err
+type stubFetcher struct {
+	name  string
+	info  *BookInfo
+	err   error
+	calls int
+}
+
+func (s *stubFetcher) GetBookByISBN(ctx context.Context, isbn string) (*BookInfo, error) {
+	s.calls++
+	return s.info, s.err
+}
+
+func (s *stubFetcher) SearchBooks(ctx context.Context, opts SearchOptions) (*SearchResult, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &SearchResult{Books: []BookInfo{*s.info}}, nil
+}
+
+func (s *stubFetcher) Name() string                       { return s.name }
+func (s *stubFetcher) IsHealthy(ctx context.Context) bool { return s.err == nil }
+
+func TestChainFallsBack(t *testing.T) {
+	dnsErr := &url.Error{Op: "Get", URL: "https://books.example", Err: &net.DNSError{Err: "no such host", Name: "books.example", IsNotFound: true}}
+
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"not found", fmt.Errorf("lookup: %w", ErrBookNotFound)},
+		{"rate limited", ErrRateLimitExceeded},
+		{"provider error", fmt.Errorf("%w: failed to decode response: unexpected EOF", ErrProviderError)},
+		{"unwrapped dns failure", fmt.Errorf("failed to get book: %w", dnsErr)},
+		{"provider timeout", fmt.Errorf("failed to get book: %w", context.DeadlineExceeded)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failing := &stubFetcher{name: "first", err: tt.err}
+			answering := &stubFetcher{name: "second", info: &BookInfo{Title: "Found"}}
+			chain := NewChainFetcher(failing, answering)
+
+			info, err := chain.GetBookByISBN(context.Background(), "9780306406157")
+			if err != nil {
+				t.Fatalf("GetBookByISBN: %v", err)
+			}
+			if info.Title != "Found" || info.Provider != "second" {
+				t.Errorf("info = %+v, want the second fetcher's answer", info)
+			}
+
+			if _, err := chain.SearchBooks(context.Background(), SearchOptions{Query: "q"}); err != nil {
+				t.Errorf("SearchBooks: %v", err)
+			}
+		})
+	}
+}
+
+func TestChainStops(t *testing.T) {
+	failing := &stubFetcher{name: "first", err: errors.New("boom")}
+	answering := &stubFetcher{name: "second", info: &BookInfo{Title: "Found"}}
+
+	if _, err := NewChainFetcher(failing, answering).GetBookByISBN(context.Background(), "9780306406157"); err == nil {
+		t.Fatal("GetBookByISBN succeeded after an error that does not fall back")
+	}
+	if answering.calls != 0 {
+		t.Errorf("second fetcher was called %d times", answering.calls)
+	}
+
+	// a canceled caller stops the chain even on a fallback error
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	failing.err = fmt.Errorf("%w: failed to get book: %w", ErrProviderError, ctx.Err())
+	if _, err := NewChainFetcher(failing, answering).GetBookByISBN(ctx, "9780306406157"); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetBookByISBN = %v, want context.Canceled", err)
+	}
+	if answering.calls != 0 {
+		t.Errorf("second fetcher was called %d times after the caller gave up", answering.calls)
+	}
+}
+
+func TestChainNotFound(t *testing.T) {
+	chain := NewChainFetcher(
+		&stubFetcher{name: "first", err: ErrBookNotFound},
+		&stubFetcher{name: "second", err: ErrBookNotFound},
+	)
+	if _, err := chain.GetBookByISBN(context.Background(), "9780306406157"); err != ErrBookNotFound {
+		t.Errorf("GetBookByISBN = %v, want plain ErrBookNotFound", err)
+	}
+}
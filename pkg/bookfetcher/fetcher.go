@@ -3,6 +3,9 @@ package bookfetcher
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 )
 
 var (
@@ -10,6 +13,8 @@ var (
 	ErrInvalidISBN       = errors.New("invalid isbn")
 	ErrProviderError     = errors.New("provide error")
 	ErrRateLimitExceeded = errors.New("rate limit exceeded")
+	ErrProviderNotFound  = errors.New("provider not found")
+	ErrNoProviders       = errors.New("no providers configured")
 )
 
 type BookInfo struct {
@@ -29,6 +34,9 @@ type BookInfo struct {
 	Provider        string   `json:"provider"`
 	ProviderID      string   `json:"provider_id"`
 	RawData         any      `json:"raw_data"`
+
+	// Provenance records which provider supplied each field of a merged result
+	Provenance map[MergeField]string `json:"provenance,omitempty"`
 }
 
 // SearchResult represents a search response from providers
@@ -66,3 +74,35 @@ type BookFetcher interface {
 type FetcherFactory interface {
 	CreateFetcher(config map[string]string) (BookFetcher, error)
 }
+
+// ConfigValue returns the value of key in a factory config. Keys match case
+// insensitively because config loaders such as viper lowercase them.
+func ConfigValue(config map[string]string, key string) (string, bool) {
+	if v, ok := config[key]; ok {
+		return v, true
+	}
+	for k, v := range config {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// ConfigDuration returns the duration under key in a factory config, or def
+// when the key is unset. A value that doesn't parse, or is negative, is an
+// error.
+func ConfigDuration(config map[string]string, key string, def time.Duration) (time.Duration, error) {
+	v, ok := ConfigValue(config, key)
+	if !ok {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", key, v, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("invalid %s %q: must not be negative", key, v)
+	}
+	return d, nil
+}
@@ -0,0 +1,107 @@
+package bookfetcher
+
+import (
+	"context"
+	"github.com/rs/zerolog/log"
+	"sync"
+	"time"
+)
+
+// HealthMonitor wraps providers in circuit breakers and probes their
+// IsHealthy method in the background.
+type HealthMonitor struct {
+	config       BreakerConfig
+	interval     time.Duration
+	probeTimeout time.Duration
+
+	mu       sync.RWMutex
+	breakers []*CircuitBreakerFetcher
+}
+
+func NewHealthMonitor(config BreakerConfig, interval, probeTimeout time.Duration) *HealthMonitor {
+	return &HealthMonitor{
+		config:       config,
+		interval:     interval,
+		probeTimeout: probeTimeout,
+	}
+}
+
+// Register wraps fetcher in a circuit breaker tracked by the monitor
+func (m *HealthMonitor) Register(fetcher BookFetcher) *CircuitBreakerFetcher {
+	breaker := NewCircuitBreakerFetcher(fetcher, m.config)
+
+	m.mu.Lock()
+	m.breakers = append(m.breakers, breaker)
+	m.mu.Unlock()
+
+	return breaker
+}
+
+// Start probes every registered provider each interval until ctx is done.
+// An interval of zero or less disables probing; circuits then only follow
+// the outcome of lookups.
+func (m *HealthMonitor) Start(ctx context.Context) {
+	if m.interval <= 0 {
+		log.Info().Msg("provider health probes disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+
+		m.probe(ctx)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.probe(ctx)
+			}
+		}
+	}()
+}
+
+// Status returns the state of every registered provider
+func (m *HealthMonitor) Status() []ProviderStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	statuses := make([]ProviderStatus, 0, len(m.breakers))
+	for _, breaker := range m.breakers {
+		statuses = append(statuses, breaker.Status())
+	}
+	return statuses
+}
+
+// Healthy reports whether every registered provider has a closed circuit
+func (m *HealthMonitor) Healthy() bool {
+	for _, status := range m.Status() {
+		if status.State != CircuitClosed || !status.Healthy {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *HealthMonitor) probe(ctx context.Context) {
+	m.mu.RLock()
+	breakers := append([]*CircuitBreakerFetcher(nil), m.breakers...)
+	m.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, breaker := range breakers {
+		wg.Add(1)
+		go func(breaker *CircuitBreakerFetcher) {
+			defer wg.Done()
+
+			probeCtx, cancel := context.WithTimeout(ctx, m.probeTimeout)
+			defer cancel()
+
+			if !breaker.Probe(probeCtx) {
+				log.Warn().Str("provider", breaker.Name()).Msg("provider health probe failed")
+			}
+		}(breaker)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,24 @@
+package bookfetcher
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestHealthMonitorWithoutInterval(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Second} {
+		monitor := NewHealthMonitor(BreakerConfig{FailureThreshold: 1}, interval, time.Second)
+		monitor.Register(&stubFetcher{name: "stub", err: ErrProviderError})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		monitor.Start(ctx)
+		time.Sleep(2 * time.Millisecond)
+		cancel()
+
+		// a probe would have opened the circuit of the unhealthy provider
+		if !monitor.Healthy() {
+			t.Errorf("interval %v: provider was probed, want probing disabled", interval)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package bookfetcher
+
+import (
+	"context"
+	"sync"
+)
+
+// MergeField names a BookInfo field that MergingFetcher merges across providers
+type MergeField string
+
+const (
+	FieldTitle           MergeField = "title"
+	FieldAuthors         MergeField = "authors"
+	FieldPublisher       MergeField = "publisher"
+	FieldPublicationDate MergeField = "publication_date"
+	FieldDescription     MergeField = "description"
+	FieldPageCount       MergeField = "page_count"
+	FieldCategories      MergeField = "categories"
+	FieldLanguage        MergeField = "language"
+	FieldPreviewLink     MergeField = "preview_link"
+	FieldThumbnailURL    MergeField = "thumbnail_url"
+	FieldISBN10          MergeField = "isbn_10"
+	FieldISBN13          MergeField = "isbn_13"
+)
+
+const mergeProviderName = "merge"
+
+// Precedence maps a field to the provider names to prefer for it, best first.
+// Providers not listed for a field are tried afterwards in registration order.
+type Precedence map[MergeField][]string
+
+type mergeField struct {
+	field   MergeField
+	present func(info *BookInfo) bool
+	copy    func(dst, src *BookInfo)
+}
+
+var mergeFields = []mergeField{
+	{FieldTitle,
+		func(b *BookInfo) bool { return b.Title != "" },
+		func(dst, src *BookInfo) { dst.Title = src.Title }},
+	{FieldAuthors,
+		func(b *BookInfo) bool { return len(b.Authors) > 0 },
+		func(dst, src *BookInfo) { dst.Authors = src.Authors }},
+	{FieldPublisher,
+		func(b *BookInfo) bool { return b.Publisher != "" },
+		func(dst, src *BookInfo) { dst.Publisher = src.Publisher }},
+	{FieldPublicationDate,
+		func(b *BookInfo) bool { return b.PublicationDate != "" },
+		func(dst, src *BookInfo) { dst.PublicationDate = src.PublicationDate }},
+	{FieldDescription,
+		func(b *BookInfo) bool { return b.Description != "" },
+		func(dst, src *BookInfo) { dst.Description = src.Description }},
+	{FieldPageCount,
+		func(b *BookInfo) bool { return b.PageCount > 0 },
+		func(dst, src *BookInfo) { dst.PageCount = src.PageCount }},
+	{FieldCategories,
+		func(b *BookInfo) bool { return len(b.Categories) > 0 },
+		func(dst, src *BookInfo) { dst.Categories = src.Categories }},
+	{FieldLanguage,
+		func(b *BookInfo) bool { return b.Language != "" },
+		func(dst, src *BookInfo) { dst.Language = src.Language }},
+	{FieldPreviewLink,
+		func(b *BookInfo) bool { return b.PreviewLink != "" },
+		func(dst, src *BookInfo) { dst.PreviewLink = src.PreviewLink }},
+	{FieldThumbnailURL,
+		func(b *BookInfo) bool { return b.ThumbnailURL != "" },
+		func(dst, src *BookInfo) { dst.ThumbnailURL = src.ThumbnailURL }},
+	{FieldISBN10,
+		func(b *BookInfo) bool { return b.ISBN10 != "" },
+		func(dst, src *BookInfo) { dst.ISBN10 = src.ISBN10 }},
+	{FieldISBN13,
+		func(b *BookInfo) bool { return b.ISBN13 != "" },
+		func(dst, src *BookInfo) { dst.ISBN13 = src.ISBN13 }},
+}
+
+// MergingFetcher asks every fetcher in parallel and builds a single BookInfo
+// from the best non-empty value of each field. The provider that supplied a
+// value is recorded in BookInfo.Provenance.
+type MergingFetcher struct {
+	fetchers   []BookFetcher
+	precedence Precedence
+}
+
+func NewMergingFetcher(fetchers []BookFetcher, precedence Precedence) *MergingFetcher {
+	if precedence == nil {
+		precedence = Precedence{}
+	}
+
+	return &MergingFetcher{
+		fetchers:   fetchers,
+		precedence: precedence,
+	}
+}
+
+func (m *MergingFetcher) GetBookByISBN(ctx context.Context, isbn string) (*BookInfo, error) {
+	infos := make([]*BookInfo, len(m.fetchers))
+	errs := make([]error, len(m.fetchers))
+
+	var wg sync.WaitGroup
+	for i, fetcher := range m.fetchers {
+		wg.Add(1)
+		go func(i int, fetcher BookFetcher) {
+			defer wg.Done()
+			infos[i], errs[i] = fetcher.GetBookByISBN(ctx, isbn)
+		}(i, fetcher)
+	}
+	wg.Wait()
+
+	results := make(map[string]*BookInfo, len(m.fetchers))
+	failed := make([]error, 0, len(m.fetchers))
+	for i, fetcher := range m.fetchers {
+		if errs[i] != nil {
+			failed = append(failed, errs[i])
+			continue
+		}
+		results[fetcher.Name()] = infos[i]
+	}
+
+	if len(results) == 0 {
+		return nil, chainError(failed)
+	}
+
+	merged := m.merge(results)
+	merged.ISBN = isbn
+	return merged, nil
+}
+
+// SearchBooks is not merged; the first fetcher to answer wins.
+func (m *MergingFetcher) SearchBooks(ctx context.Context, opts SearchOptions) (*SearchResult, error) {
+	return NewChainFetcher(m.fetchers...).SearchBooks(ctx, opts)
+}
+
+func (m *MergingFetcher) Name() string {
+	return mergeProviderName
+}
+
+// IsHealthy returns true if at least one fetcher is healthy
+func (m *MergingFetcher) IsHealthy(ctx context.Context) bool {
+	return NewChainFetcher(m.fetchers...).IsHealthy(ctx)
+}
+
+func (m *MergingFetcher) merge(results map[string]*BookInfo) *BookInfo {
+	raw := make(map[string]any, len(results))
+	for name, info := range results {
+		raw[name] = info.RawData
+	}
+
+	merged := &BookInfo{
+		Provider:   mergeProviderName,
+		Provenance: make(map[MergeField]string, len(mergeFields)),
+		RawData:    raw,
+	}
+
+	for _, f := range mergeFields {
+		for _, name := range m.order(f.field) {
+			info, ok := results[name]
+			if !ok || !f.present(info) {
+				continue
+			}
+			f.copy(merged, info)
+			merged.Provenance[f.field] = name
+			break
+		}
+	}
+
+	if name, ok := merged.Provenance[FieldTitle]; ok {
+		merged.ProviderID = results[name].ProviderID
+	}
+
+	return merged
+}
+
+// order returns provider names for field: configured precedence first, then
+// the remaining fetchers in registration order.
+func (m *MergingFetcher) order(field MergeField) []string {
+	preferred := m.precedence[field]
+	names := make([]string, 0, len(preferred)+len(m.fetchers))
+	seen := make(map[string]bool, len(preferred)+len(m.fetchers))
+
+	for _, name := range preferred {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, fetcher := range m.fetchers {
+		if name := fetcher.Name(); !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
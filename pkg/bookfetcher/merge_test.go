@@ -0,0 +1,141 @@
+package bookfetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestMergePrecedence(t *testing.T) {
+	google := &stubFetcher{name: "googlebooks", info: &BookInfo{
+		Title:       "Dune (Google)",
+		Authors:     []string{"Frank Herbert"},
+		Description: "Set on the desert planet Arrakis",
+		PageCount:   604,
+		ProviderID:  "google-id",
+	}}
+	openLibrary := &stubFetcher{name: "openlibrary", info: &BookInfo{
+		Title:           "Dune",
+		Authors:         []string{"Herbert, Frank"},
+		Publisher:       "Chilton Books",
+		PublicationDate: "1965",
+		ProviderID:      "OL1M",
+	}}
+
+	precedence := Precedence{
+		FieldTitle:   {"openlibrary"},
+		FieldAuthors: {"googlebooks"},
+		// a preferred provider without the field falls through
+		FieldPageCount: {"openlibrary", "googlebooks"},
+		// unknown providers in the precedence are skipped
+		FieldPublisher: {"worldcat", "openlibrary"},
+	}
+	merged, err := NewMergingFetcher([]BookFetcher{google, openLibrary}, precedence).
+		GetBookByISBN(context.Background(), "9780801950773")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &BookInfo{
+		ISBN:            "9780801950773",
+		Title:           "Dune",
+		Authors:         []string{"Frank Herbert"},
+		Publisher:       "Chilton Books",
+		PublicationDate: "1965",
+		Description:     "Set on the desert planet Arrakis",
+		PageCount:       604,
+		Provider:        mergeProviderName,
+		ProviderID:      "OL1M",
+		Provenance: map[MergeField]string{
+			FieldTitle:           "openlibrary",
+			FieldAuthors:         "googlebooks",
+			FieldPublisher:       "openlibrary",
+			FieldPublicationDate: "openlibrary",
+			FieldDescription:     "googlebooks",
+			FieldPageCount:       "googlebooks",
+		},
+		RawData: map[string]any{"googlebooks": nil, "openlibrary": nil},
+	}
+	if !reflect.DeepEqual(merged, want) {
+		t.Errorf("merged = %+v\nwant %+v", merged, want)
+	}
+}
+
+func TestMergeRegistrationOrder(t *testing.T) {
+	first := &stubFetcher{name: "first", info: &BookInfo{Title: "First title"}}
+	second := &stubFetcher{name: "second", info: &BookInfo{Title: "Second title", Language: "en"}}
+
+	// without precedence each field comes from the first fetcher that has it
+	merged, err := NewMergingFetcher([]BookFetcher{first, second}, nil).
+		GetBookByISBN(context.Background(), "9780306406157")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.Title != "First title" || merged.Provenance[FieldTitle] != "first" {
+		t.Errorf("title = %q from %q, want the first fetcher's", merged.Title, merged.Provenance[FieldTitle])
+	}
+	if merged.Language != "en" || merged.Provenance[FieldLanguage] != "second" {
+		t.Errorf("language = %q from %q, want the second fetcher's", merged.Language, merged.Provenance[FieldLanguage])
+	}
+	if _, ok := merged.Provenance[FieldPublisher]; ok {
+		t.Errorf("provenance = %v, want no entry for a field no fetcher has", merged.Provenance)
+	}
+}
+
+func TestMergePartialFailure(t *testing.T) {
+	failing := &stubFetcher{name: "googlebooks", err: fmt.Errorf("%w: status 503", ErrProviderError)}
+	missing := &stubFetcher{name: "worldcat", err: ErrBookNotFound}
+	answering := &stubFetcher{name: "openlibrary", info: &BookInfo{Title: "Dune", Publisher: "Chilton Books"}}
+
+	precedence := Precedence{FieldTitle: {"googlebooks", "worldcat"}}
+	merged, err := NewMergingFetcher([]BookFetcher{failing, missing, answering}, precedence).
+		GetBookByISBN(context.Background(), "9780801950773")
+	if err != nil {
+		t.Fatalf("GetBookByISBN: %v", err)
+	}
+	if merged.Title != "Dune" || merged.Provenance[FieldTitle] != "openlibrary" {
+		t.Errorf("title = %q from %q, want the answering provider's", merged.Title, merged.Provenance[FieldTitle])
+	}
+	if raw := merged.RawData.(map[string]any); len(raw) != 1 {
+		t.Errorf("raw data = %v, want only the answering provider's", raw)
+	}
+	for _, fetcher := range []*stubFetcher{failing, missing, answering} {
+		if fetcher.calls != 1 {
+			t.Errorf("%s was called %d times, want once", fetcher.name, fetcher.calls)
+		}
+	}
+}
+
+func TestMergeAllFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		errs     []error
+		want     error
+		wantSame bool
+	}{
+		{"not found everywhere", []error{ErrBookNotFound, ErrBookNotFound}, ErrBookNotFound, true},
+		{"a provider error", []error{ErrBookNotFound, fmt.Errorf("%w: status 500", ErrProviderError)}, ErrProviderError, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetchers := make([]BookFetcher, 0, len(tt.errs))
+			for i, err := range tt.errs {
+				fetchers = append(fetchers, &stubFetcher{name: fmt.Sprintf("provider%d", i), err: err})
+			}
+
+			_, err := NewMergingFetcher(fetchers, nil).GetBookByISBN(context.Background(), "9780306406157")
+			if !errors.Is(err, tt.want) {
+				t.Errorf("GetBookByISBN = %v, want %v", err, tt.want)
+			}
+			if tt.wantSame && err != tt.want {
+				t.Errorf("GetBookByISBN = %v, want plain %v", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewMergingFetcher(nil, nil).GetBookByISBN(context.Background(), "9780306406157"); !errors.Is(err, ErrNoProviders) {
+		t.Errorf("GetBookByISBN without fetchers = %v, want ErrNoProviders", err)
+	}
+}
@@ -5,9 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/gracchi-stdio/barf/pkg/bookfetcher"
+	"github.com/gracchi-stdio/barf/pkg/isbn"
 	"net/http"
 	"net/url"
-	"strings"
 	"time"
 )
 
@@ -23,6 +23,18 @@ type GoogleBooksProvider struct {
 
 type GoogleBooksFactory struct{}
 
+// CreateFetcher builds a rate limited provider. apiKey is optional; see
+// bookfetcher.WithRateLimit for the rate limit keys.
+func (f GoogleBooksFactory) CreateFetcher(config map[string]string) (bookfetcher.BookFetcher, error) {
+	timeout, err := bookfetcher.ConfigDuration(config, "timeout", 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	apiKey, _ := bookfetcher.ConfigValue(config, "apiKey")
+	return bookfetcher.WithRateLimit(NewGoogleBooksProvider(apiKey, timeout), config)
+}
+
 func NewGoogleBooksProvider(apiKey string, timeout time.Duration) *GoogleBooksProvider {
 	return &GoogleBooksProvider{
 		apiKey:     apiKey,
@@ -55,36 +67,28 @@ type googleBook struct {
 	VolumeInfo volumeInfo `json:"volumeInfo"`
 }
 
-func (p *GoogleBooksProvider) GetBookByISBN(ctx context.Context, isbn string) (*bookfetcher.BookInfo, error) {
-	// clean isbn
-	isbn = strings.ReplaceAll(isbn, "-", "")
-
-	// build url
-	u := fmt.Sprintf("%s/volumes?q=isbn:%s", baseURL, url.QueryEscape(isbn))
-	if p.apiKey != "" {
-		u += fmt.Sprintf("&key=%s", p.apiKey) // if apiKey is set, use it
+func (p *GoogleBooksProvider) GetBookByISBN(ctx context.Context, code string) (*bookfetcher.BookInfo, error) {
+	// normalize isbn
+	code, err := isbn.Normalize(code)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", bookfetcher.ErrInvalidISBN, err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	// build url
+	u := fmt.Sprintf("%s/volumes?q=isbn:%s", baseURL, url.QueryEscape(code))
+	req, err := p.newRequest(ctx, u)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get book: %w", err)
+		return nil, fmt.Errorf("%w: failed to get book: %w", bookfetcher.ErrProviderError, err)
 	}
 	defer resp.Body.Close()
 
-	switch resp.StatusCode {
-	case http.StatusOK:
-		// convert response to BookInfo
-	case http.StatusNotFound:
-		return nil, bookfetcher.ErrBookNotFound
-	case http.StatusTooManyRequests:
-		return nil, bookfetcher.ErrRateLimitExceeded
-	default:
-		return nil, fmt.Errorf("%w: status %d", bookfetcher.ErrProviderError, resp.StatusCode)
+	if resp.StatusCode != http.StatusOK {
+		return nil, bookfetcher.NewStatusError(resp)
 	}
 
 	var result struct {
@@ -93,7 +97,7 @@ func (p *GoogleBooksProvider) GetBookByISBN(ctx context.Context, isbn string) (*
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+		return nil, fmt.Errorf("%w: failed to decode response: %w", bookfetcher.ErrProviderError, err)
 	}
 
 	if result.TotalItems == 0 || len(result.Items) == 0 {
@@ -114,7 +118,7 @@ func (p *GoogleBooksProvider) GetBookByISBN(ctx context.Context, isbn string) (*
 
 	return &bookfetcher.BookInfo{
 		Title:           book.VolumeInfo.Title,
-		ISBN:            isbn,
+		ISBN:            code,
 		ISBN10:          isbn10,
 		ISBN13:          isbn13,
 		Authors:         book.VolumeInfo.Authors,
@@ -150,30 +154,19 @@ func (p *GoogleBooksProvider) SearchBooks(ctx context.Context, opts bookfetcher.
 	if opts.OrderBy != "" {
 		u += fmt.Sprintf("&orderBy=%s", opts.OrderBy)
 	}
-	if p.apiKey != "" {
-		u += fmt.Sprintf("&key=%s", p.apiKey) // if apiKey is set, use it
-	}
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	req, err := p.newRequest(ctx, u)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get book: %w", err)
+		return nil, fmt.Errorf("%w: failed to get book: %w", bookfetcher.ErrProviderError, err)
 	}
 	defer resp.Body.Close()
 
-	switch resp.StatusCode {
-	case http.StatusOK:
-		// convert response to BookInfo
-	case http.StatusNotFound:
-		return nil, bookfetcher.ErrBookNotFound
-	case http.StatusTooManyRequests:
-		return nil, bookfetcher.ErrRateLimitExceeded
-	default:
-		return nil, fmt.Errorf("%w: status %d", bookfetcher.ErrProviderError, resp.StatusCode)
+	if resp.StatusCode != http.StatusOK {
+		return nil, bookfetcher.NewStatusError(resp)
 	}
 
 	var result struct {
@@ -182,7 +175,7 @@ func (p *GoogleBooksProvider) SearchBooks(ctx context.Context, opts bookfetcher.
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+		return nil, fmt.Errorf("%w: failed to decode response: %w", bookfetcher.ErrProviderError, err)
 	}
 
 	books := make([]bookfetcher.BookInfo, 0, len(result.Items))
@@ -232,11 +225,7 @@ func (p *GoogleBooksProvider) Name() string {
 
 func (p *GoogleBooksProvider) IsHealthy(ctx context.Context) bool {
 	u := fmt.Sprintf("%s/volumes?q=test&maxResults=1", baseURL)
-	if p.apiKey != "" {
-		u += fmt.Sprintf("&key=%s", p.apiKey) // if apiKey is set, use it
-	}
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	req, err := p.newRequest(ctx, u)
 	if err != nil {
 		return false
 	}
@@ -249,3 +238,16 @@ func (p *GoogleBooksProvider) IsHealthy(ctx context.Context) bool {
 
 	return resp.StatusCode == http.StatusOK
 }
+
+// newRequest builds a GET request for u. The api key goes in a header
+// rather than the query, so it can't leak through errors that quote the url.
+func (p *GoogleBooksProvider) newRequest(ctx context.Context, u string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if p.apiKey != "" {
+		req.Header.Set("X-Goog-Api-Key", p.apiKey)
+	}
+	return req, nil
+}
@@ -0,0 +1,354 @@
+package openlibrary
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gracchi-stdio/barf/pkg/bookfetcher"
+	"github.com/gracchi-stdio/barf/pkg/isbn"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	baseURL      = "https://openlibrary.org"
+	coversURL    = "https://covers.openlibrary.org"
+	providerName = "openlibrary"
+)
+
+// OpenLibraryProvider looks books up through the Open Library editions,
+// works, authors and search APIs. No API key is needed.
+type OpenLibraryProvider struct {
+	baseURL    string
+	coversURL  string
+	httpClient *http.Client
+}
+
+type OpenLibraryFactory struct{}
+
+// CreateFetcher builds a rate limited provider. baseURL and coversURL
+// override the Open Library hosts; see bookfetcher.WithRateLimit for the
+// rate limit keys.
+func (f OpenLibraryFactory) CreateFetcher(config map[string]string) (bookfetcher.BookFetcher, error) {
+	timeout, err := bookfetcher.ConfigDuration(config, "timeout", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := NewOpenLibraryProvider(timeout)
+	if u, ok := bookfetcher.ConfigValue(config, "baseURL"); ok && u != "" {
+		provider.baseURL = strings.TrimRight(u, "/")
+	}
+	if u, ok := bookfetcher.ConfigValue(config, "coversURL"); ok && u != "" {
+		provider.coversURL = strings.TrimRight(u, "/")
+	}
+
+	return bookfetcher.WithRateLimit(provider, config)
+}
+
+func NewOpenLibraryProvider(timeout time.Duration) *OpenLibraryProvider {
+	return &OpenLibraryProvider{
+		baseURL:    baseURL,
+		coversURL:  coversURL,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// keyRef is a reference to another Open Library record, e.g. {"key": "/authors/OL1A"}
+type keyRef struct {
+	Key string `json:"key"`
+}
+
+// text decodes fields that are either a plain string or a {"type", "value"} object
+type text string
+
+func (t *text) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*t = text(s)
+		return nil
+	}
+
+	var v struct {
+		Value string `json:"value"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*t = text(v.Value)
+	return nil
+}
+
+type edition struct {
+	Key           string   `json:"key"`
+	Title         string   `json:"title"`
+	Subtitle      string   `json:"subtitle"`
+	Authors       []keyRef `json:"authors"`
+	Works         []keyRef `json:"works"`
+	Publishers    []string `json:"publishers"`
+	PublishDate   string   `json:"publish_date"`
+	NumberOfPages int      `json:"number_of_pages"`
+	ISBN10        []string `json:"isbn_10"`
+	ISBN13        []string `json:"isbn_13"`
+	Covers        []int    `json:"covers"`
+	Subjects      []string `json:"subjects"`
+	Languages     []keyRef `json:"languages"`
+	Description   text     `json:"description"`
+}
+
+type work struct {
+	Key         string   `json:"key"`
+	Description text     `json:"description"`
+	Subjects    []string `json:"subjects"`
+	Authors     []struct {
+		Author keyRef `json:"author"`
+	} `json:"authors"`
+}
+
+type author struct {
+	Key  string `json:"key"`
+	Name string `json:"name"`
+}
+
+type searchDoc struct {
+	Key                 string   `json:"key"`
+	Title               string   `json:"title"`
+	Subtitle            string   `json:"subtitle"`
+	AuthorName          []string `json:"author_name"`
+	Publisher           []string `json:"publisher"`
+	FirstPublishYear    int      `json:"first_publish_year"`
+	ISBN                []string `json:"isbn"`
+	CoverID             int      `json:"cover_i"`
+	Subject             []string `json:"subject"`
+	Language            []string `json:"language"`
+	NumberOfPagesMedian int      `json:"number_of_pages_median"`
+}
+
+// rawRecord is kept in BookInfo.RawData
+type rawRecord struct {
+	Edition edition  `json:"edition"`
+	Work    *work    `json:"work,omitempty"`
+	Authors []author `json:"authors,omitempty"`
+}
+
+func (p *OpenLibraryProvider) GetBookByISBN(ctx context.Context, code string) (*bookfetcher.BookInfo, error) {
+	code, err := isbn.Normalize(code)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", bookfetcher.ErrInvalidISBN, err)
+	}
+
+	var ed edition
+	if err := p.getJSON(ctx, fmt.Sprintf("%s/isbn/%s.json", p.baseURL, code), &ed); err != nil {
+		return nil, err
+	}
+
+	raw := rawRecord{Edition: ed}
+
+	// subjects and descriptions usually live on the work
+	if len(ed.Works) > 0 {
+		var w work
+		if err := p.getJSON(ctx, p.baseURL+ed.Works[0].Key+".json", &w); err == nil {
+			raw.Work = &w
+		}
+	}
+
+	authorKeys := make([]string, 0, len(ed.Authors))
+	for _, a := range ed.Authors {
+		authorKeys = append(authorKeys, a.Key)
+	}
+	if len(authorKeys) == 0 && raw.Work != nil {
+		for _, a := range raw.Work.Authors {
+			authorKeys = append(authorKeys, a.Author.Key)
+		}
+	}
+	raw.Authors = p.resolveAuthors(ctx, authorKeys)
+
+	info := &bookfetcher.BookInfo{
+		Title:           joinTitle(ed.Title, ed.Subtitle),
+		ISBN:            code,
+		ISBN10:          first(ed.ISBN10),
+		ISBN13:          first(ed.ISBN13),
+		Publisher:       first(ed.Publishers),
+		PublicationDate: ed.PublishDate,
+		Description:     string(ed.Description),
+		PageCount:       ed.NumberOfPages,
+		Categories:      ed.Subjects,
+		PreviewLink:     p.baseURL + ed.Key,
+		Provider:        providerName,
+		ProviderID:      strings.TrimPrefix(ed.Key, "/books/"),
+		RawData:         raw,
+	}
+
+	for _, a := range raw.Authors {
+		info.Authors = append(info.Authors, a.Name)
+	}
+	if len(ed.Languages) > 0 {
+		info.Language = strings.TrimPrefix(ed.Languages[0].Key, "/languages/")
+	}
+	if raw.Work != nil {
+		if info.Description == "" {
+			info.Description = string(raw.Work.Description)
+		}
+		if len(info.Categories) == 0 {
+			info.Categories = raw.Work.Subjects
+		}
+	}
+	if len(ed.Covers) > 0 && ed.Covers[0] > 0 {
+		info.ThumbnailURL = fmt.Sprintf("%s/b/id/%d-M.jpg", p.coversURL, ed.Covers[0])
+	} else {
+		info.ThumbnailURL = fmt.Sprintf("%s/b/isbn/%s-M.jpg?default=false", p.coversURL, code)
+	}
+
+	return info, nil
+}
+
+func (p *OpenLibraryProvider) SearchBooks(ctx context.Context, opts bookfetcher.SearchOptions) (*bookfetcher.SearchResult, error) {
+	q := url.Values{}
+	q.Set("q", opts.Query)
+	if opts.MaxResults > 0 {
+		q.Set("limit", fmt.Sprintf("%d", opts.MaxResults))
+	}
+	if opts.StartIndex > 0 {
+		q.Set("offset", fmt.Sprintf("%d", opts.StartIndex))
+	}
+	if opts.Language != "" {
+		q.Set("language", opts.Language)
+	}
+	if opts.OrderBy == "newest" {
+		q.Set("sort", "new")
+	}
+
+	var result struct {
+		NumFound int         `json:"numFound"`
+		Start    int         `json:"start"`
+		Docs     []searchDoc `json:"docs"`
+	}
+	if err := p.getJSON(ctx, p.baseURL+"/search.json?"+q.Encode(), &result); err != nil {
+		return nil, err
+	}
+
+	books := make([]bookfetcher.BookInfo, 0, len(result.Docs))
+	for _, doc := range result.Docs {
+		book := bookfetcher.BookInfo{
+			Title:       joinTitle(doc.Title, doc.Subtitle),
+			Authors:     doc.AuthorName,
+			Publisher:   first(doc.Publisher),
+			PageCount:   doc.NumberOfPagesMedian,
+			Categories:  doc.Subject,
+			Language:    first(doc.Language),
+			PreviewLink: p.baseURL + doc.Key,
+			Provider:    providerName,
+			ProviderID:  strings.TrimPrefix(doc.Key, "/works/"),
+			RawData:     doc,
+		}
+		if doc.FirstPublishYear > 0 {
+			book.PublicationDate = fmt.Sprintf("%d", doc.FirstPublishYear)
+		}
+		for _, code := range doc.ISBN {
+			if parsed, err := isbn.Parse(code); err == nil {
+				book.ISBN = parsed.ISBN13()
+				book.ISBN13 = parsed.ISBN13()
+				book.ISBN10, _ = parsed.ISBN10()
+				break
+			}
+		}
+		if doc.CoverID > 0 {
+			book.ThumbnailURL = fmt.Sprintf("%s/b/id/%d-M.jpg", p.coversURL, doc.CoverID)
+		}
+		books = append(books, book)
+	}
+
+	return &bookfetcher.SearchResult{
+		Books:        books,
+		TotalResults: result.NumFound,
+		ItemsPerPage: len(books),
+		StartIndex:   result.Start,
+		HasMore:      result.Start+len(books) < result.NumFound,
+	}, nil
+}
+
+func (p *OpenLibraryProvider) Name() string {
+	return providerName
+}
+
+func (p *OpenLibraryProvider) IsHealthy(ctx context.Context) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/search.json?q=test&limit=1", nil)
+	if err != nil {
+		return false
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode == http.StatusOK
+}
+
+// resolveAuthors fetches author records in parallel, keeping their order and
+// skipping those that fail
+func (p *OpenLibraryProvider) resolveAuthors(ctx context.Context, keys []string) []author {
+	authors := make([]author, len(keys))
+
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		wg.Add(1)
+		go func(i int, key string) {
+			defer wg.Done()
+			var a author
+			if err := p.getJSON(ctx, p.baseURL+key+".json", &a); err == nil {
+				authors[i] = a
+			}
+		}(i, key)
+	}
+	wg.Wait()
+
+	resolved := authors[:0]
+	for _, a := range authors {
+		if a.Name != "" {
+			resolved = append(resolved, a)
+		}
+	}
+	return resolved
+}
+
+func (p *OpenLibraryProvider) getJSON(ctx context.Context, u string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: failed to get book: %w", bookfetcher.ErrProviderError, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return bookfetcher.NewStatusError(resp)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%w: failed to decode response: %w", bookfetcher.ErrProviderError, err)
+	}
+	return nil
+}
+
+func joinTitle(title, subtitle string) string {
+	if subtitle == "" {
+		return title
+	}
+	return title + ": " + subtitle
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
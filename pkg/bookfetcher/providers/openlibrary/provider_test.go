@@ -0,0 +1,235 @@
+package openlibrary
+
+import (
+	"context"
+	"errors"
+	"github.com/gracchi-stdio/barf/pkg/bookfetcher"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fixtureServer serves the recorded Open Library responses in testdata by
+// path, e.g. /works/OL45804W.json from testdata/works/OL45804W.json, and
+// answers 404 for anything else, as Open Library does. It records the paths
+// requested.
+type fixtureServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []string
+}
+
+func newFixtureServer(t *testing.T) *fixtureServer {
+	t.Helper()
+
+	s := &fixtureServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r.URL.Path)
+		s.mu.Unlock()
+
+		data, err := os.ReadFile(filepath.Join("testdata", filepath.FromSlash(strings.TrimPrefix(r.URL.Path, "/"))))
+		if err != nil {
+			http.Error(w, `{"error": "notfound"}`, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *fixtureServer) requested(path string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range s.requests {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+func newTestProvider(s *fixtureServer) *OpenLibraryProvider {
+	p := NewOpenLibraryProvider(5 * time.Second)
+	p.baseURL = s.URL
+	p.coversURL = "https://covers.example"
+	return p
+}
+
+func TestGetBookByISBN(t *testing.T) {
+	server := newFixtureServer(t)
+	p := newTestProvider(server)
+
+	// an isbn-10 is looked up by its isbn-13
+	info, err := p.GetBookByISBN(context.Background(), "0-14-032872-6")
+	if err != nil {
+		t.Fatalf("GetBookByISBN: %v", err)
+	}
+
+	if !server.requested("/isbn/9780140328721.json") {
+		t.Errorf("edition not looked up by isbn-13, requests: %v", server.requests)
+	}
+	want := map[string]string{
+		"Title":           "Fantastic Mr. Fox",
+		"ISBN":            "9780140328721",
+		"ISBN10":          "0140328726",
+		"ISBN13":          "9780140328721",
+		"Publisher":       "Puffin",
+		"PublicationDate": "October 1, 1988",
+		"Language":        "eng",
+		"Provider":        "openlibrary",
+		"ProviderID":      "OL7353617M",
+		"PreviewLink":     server.URL + "/books/OL7353617M",
+		"ThumbnailURL":    "https://covers.example/b/id/8739161-M.jpg",
+	}
+	got := map[string]string{
+		"Title":           info.Title,
+		"ISBN":            info.ISBN,
+		"ISBN10":          info.ISBN10,
+		"ISBN13":          info.ISBN13,
+		"Publisher":       info.Publisher,
+		"PublicationDate": info.PublicationDate,
+		"Language":        info.Language,
+		"Provider":        info.Provider,
+		"ProviderID":      info.ProviderID,
+		"PreviewLink":     info.PreviewLink,
+		"ThumbnailURL":    info.ThumbnailURL,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("book = %v, want %v", got, want)
+	}
+	if info.PageCount != 96 {
+		t.Errorf("PageCount = %d, want 96", info.PageCount)
+	}
+
+	// the author is followed up from the edition, the description and
+	// subjects from the work
+	if want := []string{"Roald Dahl"}; !reflect.DeepEqual(info.Authors, want) {
+		t.Errorf("Authors = %v, want %v", info.Authors, want)
+	}
+	if !strings.HasPrefix(info.Description, "The main character of Fantastic Mr. Fox") {
+		t.Errorf("Description = %q, want the work's description", info.Description)
+	}
+	if len(info.Categories) != 6 || info.Categories[0] != "Animals" {
+		t.Errorf("Categories = %v, want the work's subjects", info.Categories)
+	}
+
+	raw, ok := info.RawData.(rawRecord)
+	if !ok || raw.Work == nil || raw.Work.Key != "/works/OL45804W" || len(raw.Authors) != 1 {
+		t.Errorf("RawData = %+v, want the edition, work and author", info.RawData)
+	}
+}
+
+func TestGetBookByISBNAuthorsFromWork(t *testing.T) {
+	server := newFixtureServer(t)
+	p := newTestProvider(server)
+
+	info, err := p.GetBookByISBN(context.Background(), "9780262033848")
+	if err != nil {
+		t.Fatalf("GetBookByISBN: %v", err)
+	}
+
+	// the edition names no authors, so the work's are followed up in
+	// order; one of them is missing and skipped
+	if want := []string{"Thomas H. Cormen", "Charles E. Leiserson"}; !reflect.DeepEqual(info.Authors, want) {
+		t.Errorf("Authors = %v, want %v", info.Authors, want)
+	}
+	if !server.requested("/authors/OL9999999A.json") {
+		t.Errorf("missing author not requested, requests: %v", server.requests)
+	}
+	if info.Description != "A comprehensive introduction to the modern study of computer algorithms." {
+		t.Errorf("Description = %q, want the work's plain string description", info.Description)
+	}
+	if want := []string{"Computer programming", "Computer algorithms"}; !reflect.DeepEqual(info.Categories, want) {
+		t.Errorf("Categories = %v, want %v", info.Categories, want)
+	}
+	// without a cover id the cover is asked for by isbn
+	if want := "https://covers.example/b/isbn/9780262033848-M.jpg?default=false"; info.ThumbnailURL != want {
+		t.Errorf("ThumbnailURL = %q, want %q", info.ThumbnailURL, want)
+	}
+}
+
+func TestGetBookByISBNNotFound(t *testing.T) {
+	server := newFixtureServer(t)
+	p := newTestProvider(server)
+
+	_, err := p.GetBookByISBN(context.Background(), "9780306406157")
+	if !errors.Is(err, bookfetcher.ErrBookNotFound) {
+		t.Fatalf("GetBookByISBN = %v, want ErrBookNotFound", err)
+	}
+	var statusErr *bookfetcher.StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Errorf("GetBookByISBN = %v, want a 404 status error", err)
+	}
+	if !bookfetcher.IsFallbackError(err) {
+		t.Errorf("a missing book does not let a chain fall back")
+	}
+}
+
+func TestGetBookByISBNInvalid(t *testing.T) {
+	server := newFixtureServer(t)
+	p := newTestProvider(server)
+
+	_, err := p.GetBookByISBN(context.Background(), "9780140328722")
+	if !errors.Is(err, bookfetcher.ErrInvalidISBN) {
+		t.Fatalf("GetBookByISBN = %v, want ErrInvalidISBN", err)
+	}
+	if len(server.requests) != 0 {
+		t.Errorf("invalid isbn was looked up: %v", server.requests)
+	}
+}
+
+func TestSearchBooks(t *testing.T) {
+	server := newFixtureServer(t)
+	p := newTestProvider(server)
+
+	result, err := p.SearchBooks(context.Background(), bookfetcher.SearchOptions{Query: "roald dahl", MaxResults: 2})
+	if err != nil {
+		t.Fatalf("SearchBooks: %v", err)
+	}
+
+	if result.TotalResults != 2 || len(result.Books) != 2 || result.HasMore {
+		t.Fatalf("result = %+v, want 2 books and no more", result)
+	}
+	fox := result.Books[0]
+	if fox.ISBN13 != "9780140328721" || fox.ISBN10 != "0140328726" || fox.PublicationDate != "1970" ||
+		fox.ProviderID != "OL45804W" || fox.ThumbnailURL != "https://covers.example/b/id/6498519-M.jpg" {
+		t.Errorf("first book = %+v", fox)
+	}
+	if danny := result.Books[1]; danny.Title != "Danny: the Champion of the World" || danny.ISBN != "" {
+		t.Errorf("second book = %+v", danny)
+	}
+}
+
+func TestGetBookByISBNUnreachable(t *testing.T) {
+	server := newFixtureServer(t)
+	p := newTestProvider(server)
+	server.Close()
+
+	_, err := p.GetBookByISBN(context.Background(), "9780140328721")
+	if !errors.Is(err, bookfetcher.ErrProviderError) {
+		t.Fatalf("GetBookByISBN = %v, want ErrProviderError", err)
+	}
+	if !bookfetcher.IsFallbackError(err) {
+		t.Errorf("an unreachable provider does not let a chain fall back")
+	}
+}
+
+func TestCreateFetcherTimeout(t *testing.T) {
+	if _, err := (OpenLibraryFactory{}).CreateFetcher(map[string]string{"timeout": "30s"}); err != nil {
+		t.Errorf("CreateFetcher with a timeout: %v", err)
+	}
+	for _, timeout := range []string{"30", "soon", "-1s"} {
+		if _, err := (OpenLibraryFactory{}).CreateFetcher(map[string]string{"timeout": timeout}); err == nil {
+			t.Errorf("CreateFetcher with timeout %q = nil error, want one", timeout)
+		}
+	}
+}
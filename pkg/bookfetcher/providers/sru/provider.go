@@ -0,0 +1,209 @@
+package sru
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"github.com/gracchi-stdio/barf/pkg/bookfetcher"
+	"github.com/gracchi-stdio/barf/pkg/isbn"
+	"github.com/gracchi-stdio/barf/pkg/marc"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// baseURL is the Library of Congress SRU endpoint
+	baseURL      = "http://lx2.loc.gov:210/LCDB"
+	providerName = "sru"
+
+	defaultISBNIndex    = "bath.isbn"
+	defaultKeywordIndex = "cql.serverChoice"
+)
+
+// SRUProvider queries an SRU server for MARCXML records. It defaults to the
+// Library of Congress catalog but works with any SRU 1.1 endpoint.
+type SRUProvider struct {
+	name         string
+	baseURL      string
+	isbnIndex    string
+	keywordIndex string
+	httpClient   *http.Client
+}
+
+type SRUFactory struct{}
+
+// CreateFetcher builds a rate limited provider. Config keys: baseURL, name,
+// isbnIndex and keywordIndex (CQL indexes), timeout, plus the rate limit keys
+// of bookfetcher.WithRateLimit.
+func (f SRUFactory) CreateFetcher(config map[string]string) (bookfetcher.BookFetcher, error) {
+	timeout, err := bookfetcher.ConfigDuration(config, "timeout", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	u, _ := bookfetcher.ConfigValue(config, "baseURL")
+	provider := NewSRUProvider(u, timeout)
+	if name, ok := bookfetcher.ConfigValue(config, "name"); ok && name != "" {
+		provider.name = name
+	}
+	if index, ok := bookfetcher.ConfigValue(config, "isbnIndex"); ok && index != "" {
+		provider.isbnIndex = index
+	}
+	if index, ok := bookfetcher.ConfigValue(config, "keywordIndex"); ok && index != "" {
+		provider.keywordIndex = index
+	}
+
+	return bookfetcher.WithRateLimit(provider, config)
+}
+
+// NewSRUProvider creates a provider for the SRU server at u, or the Library
+// of Congress when u is empty
+func NewSRUProvider(u string, timeout time.Duration) *SRUProvider {
+	if u == "" {
+		u = baseURL
+	}
+
+	return &SRUProvider{
+		name:         providerName,
+		baseURL:      strings.TrimRight(u, "/"),
+		isbnIndex:    defaultISBNIndex,
+		keywordIndex: defaultKeywordIndex,
+		httpClient:   &http.Client{Timeout: timeout},
+	}
+}
+
+type searchRetrieveResponse struct {
+	NumberOfRecords int `xml:"numberOfRecords"`
+	Records         []struct {
+		RecordData struct {
+			Record marc.Record `xml:"record"`
+		} `xml:"recordData"`
+		RecordPosition int `xml:"recordPosition"`
+	} `xml:"records>record"`
+	NextRecordPosition int `xml:"nextRecordPosition"`
+	Diagnostics        []struct {
+		URI     string `xml:"uri"`
+		Details string `xml:"details"`
+		Message string `xml:"message"`
+	} `xml:"diagnostics>diagnostic"`
+}
+
+func (p *SRUProvider) GetBookByISBN(ctx context.Context, code string) (*bookfetcher.BookInfo, error) {
+	parsed, err := isbn.Parse(code)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", bookfetcher.ErrInvalidISBN, err)
+	}
+
+	// older records only carry the isbn-10
+	query := fmt.Sprintf("%s=%s", p.isbnIndex, parsed.ISBN13())
+	if isbn10, err := parsed.ISBN10(); err == nil {
+		query = fmt.Sprintf("%s or %s=%s", query, p.isbnIndex, isbn10)
+	}
+
+	result, err := p.searchRetrieve(ctx, query, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Records) == 0 {
+		return nil, bookfetcher.ErrBookNotFound
+	}
+
+	info := p.toBookInfo(result.Records[0].RecordData.Record)
+	info.ISBN = parsed.ISBN13()
+	return info, nil
+}
+
+func (p *SRUProvider) SearchBooks(ctx context.Context, opts bookfetcher.SearchOptions) (*bookfetcher.SearchResult, error) {
+	maxResults := opts.MaxResults
+	if maxResults <= 0 {
+		maxResults = 10
+	}
+
+	query := fmt.Sprintf("%s=%q", p.keywordIndex, opts.Query)
+	result, err := p.searchRetrieve(ctx, query, opts.StartIndex+1, maxResults)
+	if err != nil {
+		return nil, err
+	}
+
+	books := make([]bookfetcher.BookInfo, 0, len(result.Records))
+	for _, record := range result.Records {
+		books = append(books, *p.toBookInfo(record.RecordData.Record))
+	}
+
+	return &bookfetcher.SearchResult{
+		Books:        books,
+		TotalResults: result.NumberOfRecords,
+		ItemsPerPage: len(books),
+		StartIndex:   opts.StartIndex,
+		HasMore:      result.NextRecordPosition > 0,
+	}, nil
+}
+
+func (p *SRUProvider) Name() string {
+	return p.name
+}
+
+// IsHealthy sends an explain request
+func (p *SRUProvider) IsHealthy(ctx context.Context) bool {
+	u := fmt.Sprintf("%s?version=1.1&operation=explain", p.baseURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return false
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode == http.StatusOK
+}
+
+func (p *SRUProvider) searchRetrieve(ctx context.Context, query string, start, maximum int) (*searchRetrieveResponse, error) {
+	q := url.Values{}
+	q.Set("version", "1.1")
+	q.Set("operation", "searchRetrieve")
+	q.Set("query", query)
+	q.Set("recordSchema", "marcxml")
+	q.Set("startRecord", strconv.Itoa(start))
+	q.Set("maximumRecords", strconv.Itoa(maximum))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to get book: %w", bookfetcher.ErrProviderError, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, bookfetcher.NewStatusError(resp)
+	}
+
+	var result searchRetrieveResponse
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("%w: failed to decode response: %w", bookfetcher.ErrProviderError, err)
+	}
+
+	if len(result.Records) == 0 && len(result.Diagnostics) > 0 {
+		d := result.Diagnostics[0]
+		return nil, fmt.Errorf("%w: %s %s", bookfetcher.ErrProviderError, d.Message, d.Details)
+	}
+
+	return &result, nil
+}
+
+// toBookInfo maps a MARC 21 bibliographic record to a BookInfo
+func (p *SRUProvider) toBookInfo(record marc.Record) *bookfetcher.BookInfo {
+	info := marc.DefaultMapping.BookInfo(&record)
+	info.Provider = p.name
+	info.RawData = record
+	return info
+}
@@ -5,150 +5,103 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/gracchi-stdio/barf/pkg/bookfetcher"
+	"github.com/gracchi-stdio/barf/pkg/isbn"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 )
 
 const (
-	baseURL      = "https://www.worldcat.org/webservices/catalog/content"
-	providerName = "worldCat"
+	baseURL      = "https://americas.discovery.api.oclc.org/worldcat/search/v2"
+	tokenURL     = "https://oauth.oclc.org/token"
+	scope        = "wcapi"
+	providerName = "worldcat"
 )
 
+// WorldCatProvider queries the WorldCat Search API v2, authenticating with
+// OAuth2 client credentials.
 type WorldCatProvider struct {
-	apiKey     string
+	baseURL    string
+	tokens     *tokenSource
 	httpClient *http.Client
 }
 
-func (w WorldCatProvider) GetBookByISBN(ctx context.Context, isbn string) (*bookfetcher.BookInfo, error) {
-	url := fmt.Sprintf("%s/isbn/%s?wskey=%s&format=json", baseURL, isbn, w.apiKey)
+// briefRecord is a record of the brief-bibs endpoint
+type briefRecord struct {
+	OCLCNumber       string   `json:"oclcNumber"`
+	Title            string   `json:"title"`
+	Creator          string   `json:"creator"`
+	Date             string   `json:"date"`
+	MachineReadable  string   `json:"machineReadableDate"`
+	Language         string   `json:"language"`
+	GeneralFormat    string   `json:"generalFormat"`
+	Publisher        string   `json:"publisher"`
+	PublicationPlace string   `json:"publicationPlace"`
+	ISBNs            []string `json:"isbns"`
+	Edition          string   `json:"edition"`
+}
+
+type briefBibsResponse struct {
+	NumberOfRecords int           `json:"numberOfRecords"`
+	BriefRecords    []briefRecord `json:"briefRecords"`
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+func (w WorldCatProvider) GetBookByISBN(ctx context.Context, code string) (*bookfetcher.BookInfo, error) {
+	code, err := isbn.Normalize(code)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("%w: %v", bookfetcher.ErrInvalidISBN, err)
 	}
 
-	resp, err := w.httpClient.Do(req)
+	q := url.Values{}
+	q.Set("q", "bn:"+code)
+	q.Set("limit", "1")
+
+	result, err := w.briefBibs(ctx, q)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get book: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
-
-	switch resp.StatusCode {
-	case http.StatusOK:
-		// convert response to BookInfo
-	case http.StatusNotFound:
+	if len(result.BriefRecords) == 0 {
 		return nil, bookfetcher.ErrBookNotFound
-	case http.StatusTooManyRequests:
-		return nil, bookfetcher.ErrRateLimitExceeded
-	default:
-		return nil, fmt.Errorf("%w: status %d", bookfetcher.ErrProviderError, resp.StatusCode)
-	}
-
-	var wcBook struct {
-		Title       string   `json:"title"`
-		ISBN        []string `json:"isbn"`
-		Author      []string `json:"author"`
-		Publisher   string   `json:"publisher"`
-		PublishDate string   `json:"publishDate"`
-		Description string   `json:"summary"`
-		PageCount   int      `json:"pageCount"`
-		ID          string   `json:"id"`
-		Language    string   `json:"language"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&wcBook); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	return &bookfetcher.BookInfo{
-		Title:           wcBook.Title,
-		ISBN:            isbn,
-		Authors:         wcBook.Author,
-		Publisher:       wcBook.Publisher,
-		PublicationDate: wcBook.PublishDate,
-		Description:     wcBook.Description,
-		PageCount:       wcBook.PageCount,
-		Language:        wcBook.Language,
-		Provider:        providerName,
-		ProviderID:      wcBook.ID,
-		RawData:         wcBook,
-	}, nil
+	}
+
+	info := toBookInfo(result.BriefRecords[0])
+	info.ISBN = code
+	return &info, nil
 }
 
 func (w WorldCatProvider) SearchBooks(ctx context.Context, opts bookfetcher.SearchOptions) (*bookfetcher.SearchResult, error) {
-	url := fmt.Sprintf(
-		"%s/search?wskey=%s&format=json&limit=%d&start=%d",
-		baseURL,
-		w.apiKey,
-		opts.MaxResults,
-		opts.StartIndex,
-	)
-
-	if opts.Language != "" {
-		url += fmt.Sprintf("&language=%s", opts.Language)
-	}
-	if opts.PrintType != "" {
-		url += fmt.Sprintf("&printType=%s", opts.PrintType)
+	q := url.Values{}
+	q.Set("q", opts.Query)
+	if opts.MaxResults > 0 {
+		q.Set("limit", strconv.Itoa(opts.MaxResults))
 	}
-	if opts.OrderBy != "" {
-		url += fmt.Sprintf("&orderBy=%s", opts.OrderBy)
+	// offsets are 1 based
+	q.Set("offset", strconv.Itoa(opts.StartIndex+1))
+	if opts.Language != "" {
+		q.Set("inLanguage", opts.Language)
 	}
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+	if opts.OrderBy == "newest" {
+		q.Set("orderBy", "publicationDateDesc")
 	}
 
-	resp, err := w.httpClient.Do(req)
+	result, err := w.briefBibs(ctx, q)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get book: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("%w: status %d", bookfetcher.ErrProviderError, resp.StatusCode)
-	}
-
-	var result struct {
-		Books []struct {
-			Title       string   `json:"title"`
-			ISBN        []string `json:"isbn"`
-			Author      []string `json:"author"`
-			Publisher   string   `json:"publisher"`
-			PublishDate string   `json:"publishDate"`
-			ID          string   `json:"id"`
-		} `json:"books"`
-		TotalResults int `json:"total_results"`
+		return nil, err
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	books := make([]bookfetcher.BookInfo, len(result.Books))
-	for i, book := range result.Books {
-		isbn := ""
-		if len(book.ISBN) > 0 {
-			isbn = book.ISBN[0]
-		}
-		books[i] = bookfetcher.BookInfo{
-			Title:           book.Title,
-			ISBN:            isbn,
-			Authors:         book.Author,
-			Publisher:       book.Publisher,
-			PublicationDate: book.PublishDate,
-			Provider:        providerName,
-			ProviderID:      book.ID,
-			RawData:         book,
-		}
+	books := make([]bookfetcher.BookInfo, len(result.BriefRecords))
+	for i, record := range result.BriefRecords {
+		books[i] = toBookInfo(record)
 	}
 
 	return &bookfetcher.SearchResult{
 		Books:        books,
-		TotalResults: result.TotalResults,
-		ItemsPerPage: opts.MaxResults,
+		TotalResults: result.NumberOfRecords,
+		ItemsPerPage: len(books),
 		StartIndex:   opts.StartIndex,
-		HasMore:      result.TotalResults > opts.StartIndex+opts.MaxResults,
+		HasMore:      result.NumberOfRecords > opts.StartIndex+len(books),
 	}, nil
 }
 
@@ -156,42 +109,145 @@ func (w WorldCatProvider) Name() string {
 	return providerName
 }
 
+// IsHealthy checks that a token can be obtained and the search API answers
 func (w WorldCatProvider) IsHealthy(ctx context.Context) bool {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/health?wskey=%s", baseURL, w.apiKey), nil)
-	if err != nil {
-		return false
+	q := url.Values{}
+	q.Set("q", "ti:test")
+	q.Set("limit", "1")
+
+	_, err := w.briefBibs(ctx, q)
+	return err == nil
+}
+
+// briefBibs calls the brief-bibs endpoint, renewing the token once if the
+// API rejects it
+func (w WorldCatProvider) briefBibs(ctx context.Context, q url.Values) (*briefBibsResponse, error) {
+	u := fmt.Sprintf("%s/brief-bibs?%s", w.baseURL, q.Encode())
+
+	for attempt := 0; ; attempt++ {
+		token, err := w.tokens.Token(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Accept", "application/json")
+
+		resp, err := w.httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to get book: %w", bookfetcher.ErrProviderError, err)
+		}
+
+		if resp.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			resp.Body.Close()
+			w.tokens.Invalidate()
+			continue
+		}
+
+		result, err := decodeBriefBibs(resp)
+		resp.Body.Close()
+		return result, err
 	}
+}
 
-	resp, err := w.httpClient.Do(req)
-	if err != nil {
-		return false
+func decodeBriefBibs(resp *http.Response) (*briefBibsResponse, error) {
+	if resp.StatusCode != http.StatusOK {
+		return nil, bookfetcher.NewStatusError(resp)
+	}
+
+	var result briefBibsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("%w: failed to decode response: %w", bookfetcher.ErrProviderError, err)
+	}
+	return &result, nil
+}
+
+func toBookInfo(record briefRecord) bookfetcher.BookInfo {
+	info := bookfetcher.BookInfo{
+		Title:           record.Title,
+		Publisher:       record.Publisher,
+		PublicationDate: record.Date,
+		Language:        record.Language,
+		Provider:        providerName,
+		ProviderID:      record.OCLCNumber,
+		RawData:         record,
+	}
+	if record.MachineReadable != "" {
+		info.PublicationDate = record.MachineReadable
+	}
+	if record.Creator != "" {
+		info.Authors = []string{record.Creator}
+	}
+
+	for _, code := range record.ISBNs {
+		parsed, err := isbn.Parse(code)
+		if err != nil {
+			continue
+		}
+		if info.ISBN == "" {
+			info.ISBN = parsed.ISBN13()
+			info.ISBN13 = parsed.ISBN13()
+			info.ISBN10, _ = parsed.ISBN10()
+		}
 	}
-	defer resp.Body.Close()
 
-	return resp.StatusCode == http.StatusOK
+	return info
 }
 
 type WorldCatFactory struct{}
 
+// CreateFetcher builds a rate limited provider. clientId and clientSecret
+// (a WSKey and its secret) are required; baseURL, tokenURL, scope and timeout
+// are optional, plus the rate limit keys of bookfetcher.WithRateLimit.
 func (f WorldCatFactory) CreateFetcher(config map[string]string) (bookfetcher.BookFetcher, error) {
-	apiKey, ok := config["apiKey"]
+	clientID, ok := bookfetcher.ConfigValue(config, "clientId")
 	if !ok {
-		return nil, fmt.Errorf("apiKey not found in config")
+		clientID, ok = bookfetcher.ConfigValue(config, "apiKey")
+	}
+	if !ok || clientID == "" {
+		return nil, fmt.Errorf("clientId not found in config")
 	}
 
-	timeout := 10 * time.Second
-	if timeoutStr, ok := config["timeout"]; ok {
-		if t, err := time.ParseDuration(timeoutStr); err == nil {
-			timeout = t
-		}
+	clientSecret, ok := bookfetcher.ConfigValue(config, "clientSecret")
+	if !ok || clientSecret == "" {
+		return nil, fmt.Errorf("clientSecret not found in config")
 	}
 
-	return NewWorldCatProvider(apiKey, timeout), nil
+	timeout, err := bookfetcher.ConfigDuration(config, "timeout", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := NewWorldCatProvider(clientID, clientSecret, timeout)
+	if u, ok := bookfetcher.ConfigValue(config, "baseURL"); ok && u != "" {
+		provider.baseURL = strings.TrimRight(u, "/")
+	}
+	if u, ok := bookfetcher.ConfigValue(config, "tokenURL"); ok && u != "" {
+		provider.tokens.tokenURL = u
+	}
+	if s, ok := bookfetcher.ConfigValue(config, "scope"); ok {
+		provider.tokens.scope = s
+	}
+
+	return bookfetcher.WithRateLimit(provider, config)
 }
 
-func NewWorldCatProvider(apiKey string, timeout time.Duration) *WorldCatProvider {
+func NewWorldCatProvider(clientID, clientSecret string, timeout time.Duration) *WorldCatProvider {
+	httpClient := &http.Client{Timeout: timeout}
+
 	return &WorldCatProvider{
-		apiKey:     apiKey,
-		httpClient: &http.Client{Timeout: timeout},
+		baseURL: baseURL,
+		tokens: &tokenSource{
+			tokenURL:     tokenURL,
+			clientID:     clientID,
+			clientSecret: clientSecret,
+			scope:        scope,
+			httpClient:   httpClient,
+		},
+		httpClient: httpClient,
 	}
 }
@@ -0,0 +1,91 @@
+package worldcat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gracchi-stdio/barf/pkg/bookfetcher"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// expiryMargin renews tokens shortly before they expire
+const expiryMargin = 60 * time.Second
+
+// tokenSource obtains and caches OAuth2 client credentials access tokens
+type tokenSource struct {
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	scope        string
+	httpClient   *http.Client
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// Token returns a cached access token, requesting a new one when it is
+// missing or about to expire
+func (t *tokenSource) Token(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.token != "" && time.Now().Before(t.expiresAt) {
+		return t.token, nil
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	if t.scope != "" {
+		form.Set("scope", t.scope)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.SetBasicAuth(url.QueryEscape(t.clientID), url.QueryEscape(t.clientSecret))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to get token: %w", bookfetcher.ErrProviderError, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request failed: %w", bookfetcher.NewStatusError(resp))
+	}
+
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("%w: failed to decode token response: %w", bookfetcher.ErrProviderError, err)
+	}
+	if result.AccessToken == "" {
+		return "", fmt.Errorf("%w: empty access token", bookfetcher.ErrProviderError)
+	}
+
+	lifetime := time.Duration(result.ExpiresIn) * time.Second
+	if lifetime > 2*expiryMargin {
+		lifetime -= expiryMargin
+	}
+
+	t.token = result.AccessToken
+	t.expiresAt = time.Now().Add(lifetime)
+	return t.token, nil
+}
+
+// Invalidate drops the cached token so the next call requests a new one
+func (t *tokenSource) Invalidate() {
+	t.mu.Lock()
+	t.token = ""
+	t.mu.Unlock()
+}
@@ -0,0 +1,207 @@
+package bookfetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/time/rate"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how transient provider errors are retried
+type RetryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 3,
+	BaseDelay:  200 * time.Millisecond,
+	MaxDelay:   10 * time.Second,
+}
+
+// RateLimitedFetcher decorates a BookFetcher with a token bucket rate limit
+// and retries transient errors (5xx, 429 and timeouts) with jittered
+// exponential backoff. Callers over the limit wait for a token instead of
+// failing.
+type RateLimitedFetcher struct {
+	fetcher BookFetcher
+	limiter *rate.Limiter
+	policy  RetryPolicy
+	now     func() time.Time
+	sleep   func(ctx context.Context, d time.Duration) error
+}
+
+func NewRateLimitedFetcher(fetcher BookFetcher, limit rate.Limit, burst int, policy RetryPolicy) *RateLimitedFetcher {
+	return &RateLimitedFetcher{
+		fetcher: fetcher,
+		limiter: rate.NewLimiter(limit, burst),
+		policy:  policy,
+		now:     time.Now,
+		sleep:   sleep,
+	}
+}
+
+// WithRateLimit wraps fetcher using the rate limit keys of a factory config:
+// rateLimit (requests per second, unlimited when unset), burst, maxRetries,
+// retryBaseDelay and retryMaxDelay.
+func WithRateLimit(fetcher BookFetcher, config map[string]string) (*RateLimitedFetcher, error) {
+	limit := rate.Inf
+	burst := 1
+	policy := DefaultRetryPolicy
+
+	if v, ok := ConfigValue(config, "rateLimit"); ok {
+		rps, err := strconv.ParseFloat(v, 64)
+		if err != nil || rps <= 0 {
+			return nil, fmt.Errorf("invalid rateLimit %q", v)
+		}
+		limit = rate.Limit(rps)
+	}
+	if v, ok := ConfigValue(config, "burst"); ok {
+		b, err := strconv.Atoi(v)
+		if err != nil || b < 1 {
+			return nil, fmt.Errorf("invalid burst %q", v)
+		}
+		burst = b
+	}
+	if v, ok := ConfigValue(config, "maxRetries"); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid maxRetries %q", v)
+		}
+		policy.MaxRetries = n
+	}
+	var err error
+	if policy.BaseDelay, err = ConfigDuration(config, "retryBaseDelay", policy.BaseDelay); err != nil {
+		return nil, err
+	}
+	if policy.MaxDelay, err = ConfigDuration(config, "retryMaxDelay", policy.MaxDelay); err != nil {
+		return nil, err
+	}
+
+	return NewRateLimitedFetcher(fetcher, limit, burst, policy), nil
+}
+
+func (r *RateLimitedFetcher) GetBookByISBN(ctx context.Context, isbn string) (*BookInfo, error) {
+	var info *BookInfo
+	err := r.do(ctx, func(ctx context.Context) error {
+		var err error
+		info, err = r.fetcher.GetBookByISBN(ctx, isbn)
+		return err
+	})
+	return info, err
+}
+
+func (r *RateLimitedFetcher) SearchBooks(ctx context.Context, opts SearchOptions) (*SearchResult, error) {
+	var result *SearchResult
+	err := r.do(ctx, func(ctx context.Context) error {
+		var err error
+		result, err = r.fetcher.SearchBooks(ctx, opts)
+		return err
+	})
+	return result, err
+}
+
+func (r *RateLimitedFetcher) Name() string {
+	return r.fetcher.Name()
+}
+
+// IsHealthy is neither rate limited nor retried
+func (r *RateLimitedFetcher) IsHealthy(ctx context.Context) bool {
+	return r.fetcher.IsHealthy(ctx)
+}
+
+func (r *RateLimitedFetcher) do(ctx context.Context, call func(ctx context.Context) error) error {
+	for attempt := 0; ; attempt++ {
+		if err := r.wait(ctx); err != nil {
+			return err
+		}
+
+		err := call(ctx)
+		if err == nil || !IsTransientError(err) || attempt >= r.policy.MaxRetries || ctx.Err() != nil {
+			return err
+		}
+
+		delay := r.backoff(attempt)
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+			if statusErr.RetryAfter > r.policy.MaxDelay {
+				return err
+			}
+			delay = statusErr.RetryAfter
+		}
+
+		if r.sleep(ctx, delay) != nil {
+			return err
+		}
+	}
+}
+
+// wait takes a token from the bucket, waiting until one is available
+func (r *RateLimitedFetcher) wait(ctx context.Context) error {
+	now := r.now()
+	reservation := r.limiter.ReserveN(now, 1)
+	if !reservation.OK() {
+		return fmt.Errorf("%w: burst is zero", ErrRateLimitExceeded)
+	}
+
+	delay := reservation.DelayFrom(now)
+	if delay <= 0 {
+		return nil
+	}
+	if err := r.sleep(ctx, delay); err != nil {
+		// give the token back to callers that are still waiting
+		reservation.CancelAt(r.now())
+		return err
+	}
+	return nil
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// backoff returns an exponential delay for attempt with jitter, between half
+// and all of the exponential value
+func (r *RateLimitedFetcher) backoff(attempt int) time.Duration {
+	delay := r.policy.BaseDelay << attempt
+	if delay <= 0 || delay > r.policy.MaxDelay {
+		delay = r.policy.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+// IsTransientError reports whether err is worth retrying: a 5xx or 429
+// provider status, or a network timeout.
+func IsTransientError(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests ||
+			statusErr.StatusCode >= http.StatusInternalServerError
+	}
+	if errors.Is(err, ErrRateLimitExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
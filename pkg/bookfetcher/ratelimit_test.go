@@ -0,0 +1,308 @@
+package bookfetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock stands in for the time of a RateLimitedFetcher. Sleeping
+// advances it at once and records the delay.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.sleeps = append(c.sleeps, d)
+	return nil
+}
+
+// withClock makes fetcher run on clock
+func withClock(fetcher *RateLimitedFetcher, clock *fakeClock) *RateLimitedFetcher {
+	fetcher.now = clock.Now
+	fetcher.sleep = clock.Sleep
+	return fetcher
+}
+
+// statusServer answers requests with the statuses in turn, repeating the
+// last one, and a Retry-After header when retryAfter is set
+func statusServer(t *testing.T, retryAfter string, statuses ...int) (*httptest.Server, *int) {
+	t.Helper()
+
+	var mu sync.Mutex
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		status := statuses[min(requests, len(statuses)-1)]
+		requests++
+		mu.Unlock()
+
+		if retryAfter != "" && status != http.StatusOK {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// httpFetcher looks books up on a test server the way providers do,
+// turning statuses other than 200 into a StatusError
+type httpFetcher struct {
+	url string
+}
+
+func (f *httpFetcher) GetBookByISBN(ctx context.Context, isbn string) (*BookInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.url+"/isbn/"+isbn, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to get book: %w", ErrProviderError, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, NewStatusError(resp)
+	}
+	return &BookInfo{ISBN: isbn, Title: "Test book"}, nil
+}
+
+func (f *httpFetcher) SearchBooks(ctx context.Context, opts SearchOptions) (*SearchResult, error) {
+	return nil, ErrProviderError
+}
+
+func (f *httpFetcher) Name() string                       { return "http" }
+func (f *httpFetcher) IsHealthy(ctx context.Context) bool { return true }
+
+var testRetryPolicy = RetryPolicy{
+	MaxRetries: 3,
+	BaseDelay:  100 * time.Millisecond,
+	MaxDelay:   time.Second,
+}
+
+func TestRateLimitTokenBucket(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	stub := &stubFetcher{name: "stub", info: &BookInfo{Title: "Test book"}}
+	fetcher := withClock(NewRateLimitedFetcher(stub, 2, 2, testRetryPolicy), clock)
+
+	for range 4 {
+		if _, err := fetcher.GetBookByISBN(context.Background(), "9780306406157"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the burst goes through at once, then a token every half second
+	want := []time.Duration{500 * time.Millisecond, 500 * time.Millisecond}
+	if !slices.Equal(clock.sleeps, want) {
+		t.Errorf("waits = %v, want %v", clock.sleeps, want)
+	}
+	if stub.calls != 4 {
+		t.Errorf("calls = %d, want 4", stub.calls)
+	}
+
+	// a bucket left alone refills up to its burst
+	clock.now = clock.now.Add(time.Minute)
+	clock.sleeps = nil
+	for range 2 {
+		if _, err := fetcher.GetBookByISBN(context.Background(), "9780306406157"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(clock.sleeps) != 0 {
+		t.Errorf("waits after refill = %v, want none", clock.sleeps)
+	}
+}
+
+func TestRateLimitCanceledWait(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	stub := &stubFetcher{name: "stub", info: &BookInfo{Title: "Test book"}}
+	fetcher := withClock(NewRateLimitedFetcher(stub, 1, 1, testRetryPolicy), clock)
+
+	if _, err := fetcher.GetBookByISBN(context.Background(), "9780306406157"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := fetcher.GetBookByISBN(ctx, "9780306406157"); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	if stub.calls != 1 {
+		t.Errorf("calls = %d, want the canceled lookup not to reach the provider", stub.calls)
+	}
+}
+
+func TestRateLimitRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		statuses   []int
+		maxRetries int
+		requests   int
+		wantErr    error
+	}{
+		{"recovers", []int{http.StatusServiceUnavailable, http.StatusOK}, 3, 2, nil},
+		{"gives up after max retries", []int{http.StatusBadGateway}, 3, 4, ErrProviderError},
+		{"no retries", []int{http.StatusInternalServerError}, 0, 1, ErrProviderError},
+		{"rate limited", []int{http.StatusTooManyRequests, http.StatusOK}, 3, 2, nil},
+		{"not found is not retried", []int{http.StatusNotFound}, 3, 1, ErrBookNotFound},
+		{"client error is not retried", []int{http.StatusForbidden}, 3, 1, ErrProviderError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := statusServer(t, "", tt.statuses...)
+			clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+			policy := testRetryPolicy
+			policy.MaxRetries = tt.maxRetries
+			fetcher := withClock(NewRateLimitedFetcher(&httpFetcher{url: server.URL}, 100, 1, policy), clock)
+
+			_, err := fetcher.GetBookByISBN(context.Background(), "9780306406157")
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if *requests != tt.requests {
+				t.Errorf("requests = %d, want %d", *requests, tt.requests)
+			}
+		})
+	}
+}
+
+func TestRateLimitBackoff(t *testing.T) {
+	server, _ := statusServer(t, "", http.StatusServiceUnavailable)
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	policy := RetryPolicy{MaxRetries: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	// the burst covers every attempt, so each sleep is a backoff
+	fetcher := withClock(NewRateLimitedFetcher(&httpFetcher{url: server.URL}, 1000, 10, policy), clock)
+
+	if _, err := fetcher.GetBookByISBN(context.Background(), "9780306406157"); err == nil {
+		t.Fatal("err = nil, want the provider error")
+	}
+
+	// each delay is between half and all of the doubled base delay, capped
+	// at the max delay
+	ceilings := []time.Duration{100, 200, 400, 800, 1000}
+	if len(clock.sleeps) != len(ceilings) {
+		t.Fatalf("backoffs = %v, want %d", clock.sleeps, len(ceilings))
+	}
+	for i, delay := range clock.sleeps {
+		ceiling := ceilings[i] * time.Millisecond
+		if delay < ceiling/2 || delay > ceiling {
+			t.Errorf("backoff %d = %v, want between %v and %v", i, delay, ceiling/2, ceiling)
+		}
+	}
+}
+
+func TestRateLimitRetryAfter(t *testing.T) {
+	t.Run("honored", func(t *testing.T) {
+		server, requests := statusServer(t, "2", http.StatusTooManyRequests, http.StatusOK)
+		clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+		policy := RetryPolicy{MaxRetries: 3, BaseDelay: 100 * time.Millisecond, MaxDelay: 5 * time.Second}
+		fetcher := withClock(NewRateLimitedFetcher(&httpFetcher{url: server.URL}, 1000, 10, policy), clock)
+
+		if _, err := fetcher.GetBookByISBN(context.Background(), "9780306406157"); err != nil {
+			t.Fatal(err)
+		}
+		if *requests != 2 {
+			t.Errorf("requests = %d, want 2", *requests)
+		}
+		if want := []time.Duration{2 * time.Second}; !slices.Equal(clock.sleeps, want) {
+			t.Errorf("waits = %v, want %v", clock.sleeps, want)
+		}
+	})
+
+	t.Run("longer than the max delay", func(t *testing.T) {
+		server, requests := statusServer(t, "60", http.StatusTooManyRequests, http.StatusOK)
+		clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+		fetcher := withClock(NewRateLimitedFetcher(&httpFetcher{url: server.URL}, 1000, 10, testRetryPolicy), clock)
+
+		_, err := fetcher.GetBookByISBN(context.Background(), "9780306406157")
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) || statusErr.RetryAfter != time.Minute {
+			t.Fatalf("err = %v, want the 429 with its Retry-After", err)
+		}
+		if !errors.Is(err, ErrRateLimitExceeded) {
+			t.Errorf("err = %v, want ErrRateLimitExceeded", err)
+		}
+		if *requests != 1 || len(clock.sleeps) != 0 {
+			t.Errorf("requests = %d, waits = %v, want no retry", *requests, clock.sleeps)
+		}
+	})
+}
+
+func TestRateLimitCanceledBackoff(t *testing.T) {
+	server, requests := statusServer(t, "", http.StatusServiceUnavailable)
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	fetcher := withClock(NewRateLimitedFetcher(&httpFetcher{url: server.URL}, 1000, 10, testRetryPolicy), clock)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	fetcher.sleep = func(context.Context, time.Duration) error {
+		cancel()
+		return context.Canceled
+	}
+
+	_, err := fetcher.GetBookByISBN(ctx, "9780306406157")
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("err = %v, want the last provider error", err)
+	}
+	if *requests != 1 {
+		t.Errorf("requests = %d, want 1", *requests)
+	}
+}
+
+func TestWithRateLimitConfig(t *testing.T) {
+	stub := &stubFetcher{name: "stub"}
+
+	fetcher, err := WithRateLimit(stub, map[string]string{
+		"ratelimit":      "5",
+		"burst":          "3",
+		"maxretries":     "1",
+		"retryBaseDelay": "50ms",
+		"retryMaxDelay":  "2s",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fetcher.limiter.Limit() != 5 || fetcher.limiter.Burst() != 3 {
+		t.Errorf("limit = %v/%d, want 5/3", fetcher.limiter.Limit(), fetcher.limiter.Burst())
+	}
+	want := RetryPolicy{MaxRetries: 1, BaseDelay: 50 * time.Millisecond, MaxDelay: 2 * time.Second}
+	if fetcher.policy != want {
+		t.Errorf("policy = %+v, want %+v", fetcher.policy, want)
+	}
+
+	for _, config := range []map[string]string{
+		{"rateLimit": "fast"},
+		{"rateLimit": "0"},
+		{"burst": "0"},
+		{"maxRetries": "-1"},
+		{"retryBaseDelay": "soon"},
+		{"retryMaxDelay": "-1s"},
+	} {
+		if _, err := WithRateLimit(stub, config); err == nil {
+			t.Errorf("WithRateLimit(%v) = nil error, want one", config)
+		}
+	}
+}
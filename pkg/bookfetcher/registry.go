@@ -0,0 +1,56 @@
+package bookfetcher
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Registry maps provider names to the factories that build them
+type Registry struct {
+	mu        sync.RWMutex
+	factories map[string]FetcherFactory
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		factories: make(map[string]FetcherFactory),
+	}
+}
+
+// Register adds factory under name. Registering a name twice is an error.
+func (r *Registry) Register(name string, factory FetcherFactory) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.factories[name]; ok {
+		return fmt.Errorf("provider %q already registered", name)
+	}
+	r.factories[name] = factory
+	return nil
+}
+
+// Create builds the provider registered under name from its config
+func (r *Registry) Create(name string, config map[string]string) (BookFetcher, error) {
+	r.mu.RLock()
+	factory, ok := r.factories[name]
+	r.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrProviderNotFound, name)
+	}
+	return factory.CreateFetcher(config)
+}
+
+// Names returns the registered provider names in alphabetical order
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.factories))
+	for name := range r.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
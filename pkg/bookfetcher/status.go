@@ -0,0 +1,63 @@
+package bookfetcher
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StatusError is returned when a provider answers with a non-200 status. It
+// wraps ErrBookNotFound, ErrRateLimitExceeded or ErrProviderError.
+type StatusError struct {
+	StatusCode int
+	// RetryAfter is the delay requested by the provider's Retry-After header
+	RetryAfter time.Duration
+	Err        error
+}
+
+// NewStatusError builds a StatusError from a provider response
+func NewStatusError(resp *http.Response) *StatusError {
+	e := &StatusError{
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		e.Err = ErrBookNotFound
+	case http.StatusTooManyRequests:
+		e.Err = ErrRateLimitExceeded
+	default:
+		e.Err = ErrProviderError
+	}
+	return e
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%v: status %d", e.Err, e.StatusCode)
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
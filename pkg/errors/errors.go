@@ -1,5 +1,178 @@
 package errors
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
 
-var ErrInsufficientStock = errors.New("insufficient stock")
+// Kind classifies an error by how a client can react to it
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindNotFound
+	KindConflict
+	KindValidation
+	KindInsufficientStock
+	KindUpstreamUnavailable
+	KindRateLimited
+	KindUnauthenticated
+	KindForbidden
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindNotFound:
+		return "not found"
+	case KindConflict:
+		return "conflict"
+	case KindValidation:
+		return "validation failed"
+	case KindInsufficientStock:
+		return "insufficient stock"
+	case KindUpstreamUnavailable:
+		return "upstream unavailable"
+	case KindRateLimited:
+		return "rate limited"
+	case KindUnauthenticated:
+		return "unauthenticated"
+	case KindForbidden:
+		return "forbidden"
+	default:
+		return "internal error"
+	}
+}
+
+// Error is a domain error. Code is stable and meant for clients to branch
+// on; Message is for people and may change. Fields lists the input at fault
+// for validation errors.
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Fields  []FieldError
+	// Err is the underlying error, if any
+	Err error
+}
+
+// FieldError is a problem with one input field. Field is the name the
+// client sent, e.g. the json key.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func New(kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+// Wrap returns an error of kind with code that wraps err and takes its
+// message
+func Wrap(kind Kind, code string, err error) *Error {
+	return &Error{Kind: kind, Code: code, Message: err.Error(), Err: err}
+}
+
+// NotFound returns a not found error for a kind of resource such as "book",
+// coded like book_not_found. err is usually the storage layer's not found
+// error, which stays matchable with errors.Is.
+func NotFound(resource string, err error) *Error {
+	return &Error{
+		Kind:    KindNotFound,
+		Code:    strings.ReplaceAll(resource, " ", "_") + "_not_found",
+		Message: resource + " not found",
+		Err:     err,
+	}
+}
+
+// Validation returns a validation error listing fields
+func Validation(message string, fields ...FieldError) *Error {
+	return &Error{Kind: KindValidation, Code: "validation_failed", Message: message, Fields: fields}
+}
+
+// Withf returns a copy of e with the formatted detail appended to its
+// message. The copy wraps e, so errors.Is still matches e. The message is
+// shown to clients, so the detail must not quote internal errors.
+func (e *Error) Withf(format string, args ...any) *Error {
+	return &Error{
+		Kind:    e.Kind,
+		Code:    e.Code,
+		Message: e.Message + ": " + fmt.Sprintf(format, args...),
+		Fields:  e.Fields,
+		Err:     e,
+	}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches the kind sentinels, so errors.Is(err, ErrNotFound) holds for
+// every not found error
+func (e *Error) Is(target error) bool {
+	for kind, sentinel := range kindSentinels {
+		if target == sentinel {
+			return e.Kind == kind
+		}
+	}
+	return false
+}
+
+// As returns the outermost domain error in err's chain
+func As(err error) (*Error, bool) {
+	var e *Error
+	ok := errors.As(err, &e)
+	return e, ok
+}
+
+// KindOf returns the kind of the outermost domain error in err's chain, or
+// KindInternal when there is none
+func KindOf(err error) Kind {
+	if e, ok := As(err); ok {
+		return e.Kind
+	}
+	return KindInternal
+}
+
+// Kind sentinels, matched by any domain error of the kind
+var (
+	ErrNotFound            = errors.New("not found")
+	ErrConflict            = errors.New("conflict")
+	ErrValidation          = errors.New("validation failed")
+	ErrUpstreamUnavailable = errors.New("upstream unavailable")
+)
+
+var kindSentinels = map[Kind]error{
+	KindNotFound:            ErrNotFound,
+	KindConflict:            ErrConflict,
+	KindValidation:          ErrValidation,
+	KindUpstreamUnavailable: ErrUpstreamUnavailable,
+}
+
+var (
+	ErrInsufficientStock = New(KindInsufficientStock, "insufficient_stock", "insufficient stock")
+	ErrSessionClosed     = New(KindConflict, "session_closed", "receiving session is closed")
+	ErrBookExists        = New(KindConflict, "book_exists", "a book with this isbn already exists")
+	ErrTitleRequired     = New(KindValidation, "title_required", "title is required")
+	ErrInvalidQuantity   = New(KindValidation, "invalid_quantity", "quantity must not be negative")
+	ErrInvalidPrice      = New(KindValidation, "invalid_price", "price must not be negative")
+	ErrMalformedInput    = New(KindValidation, "malformed_input", "malformed input")
+	ErrUnsupportedFormat = New(KindValidation, "unsupported_format", "unsupported format")
+	ErrInvalidID         = New(KindValidation, "invalid_id", "invalid id")
+
+	ErrUnauthenticated    = New(KindUnauthenticated, "unauthenticated", "authentication required")
+	ErrInvalidCredentials = New(KindUnauthenticated, "invalid_credentials", "invalid email or password")
+	ErrInvalidToken       = New(KindUnauthenticated, "invalid_token", "invalid or expired token")
+	ErrInvalidAPIKey      = New(KindUnauthenticated, "invalid_api_key", "invalid, expired or revoked api key")
+	ErrForbidden          = New(KindForbidden, "forbidden", "permission denied")
+)
+
+// InvalidID reports an id that is not a uuid
+func InvalidID(id string) error {
+	return ErrInvalidID.Withf("%q", id)
+}
@@ -0,0 +1,190 @@
+// Command rangegen writes the registration group and registrant range
+// tables of package isbn from the International ISBN Agency's
+// RangeMessage.xml. It reads the file named by its argument, or downloads
+// the current one when there is none.
+//
+//	go run ./internal/rangegen -o rangetable.go [RangeMessage.xml]
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// rangeMessageURL is where the agency publishes the current ranges
+const rangeMessageURL = "https://www.isbn-international.org/export_rangemessage.xml"
+
+type rangeMessage struct {
+	Source   string       `xml:"MessageSource"`
+	Serial   string       `xml:"MessageSerialNumber"`
+	Date     string       `xml:"MessageDate"`
+	Prefixes []rangeGroup `xml:"EAN.UCCPrefixes>EAN.UCC"`
+	Groups   []rangeGroup `xml:"RegistrationGroups>Group"`
+}
+
+type rangeGroup struct {
+	Prefix string      `xml:"Prefix"`
+	Agency string      `xml:"Agency"`
+	Rules  []rangeRule `xml:"Rules>Rule"`
+}
+
+type rangeRule struct {
+	Range  string `xml:"Range"`
+	Length int    `xml:"Length"`
+}
+
+// tableRule is a rule as it is written to the table
+type tableRule struct {
+	Start, End, Length int
+}
+
+type tableGroup struct {
+	Prefix string
+	Agency string
+	Rules  []tableRule
+}
+
+var tableTemplate = template.Must(template.New("table").Parse(`// Code generated by rangegen from RangeMessage.xml; DO NOT EDIT.
+// {{.Source}}, message {{.Serial}} of {{.Date}}
+
+package isbn
+
+// groupRanges splits the digits after the GS1 prefix into registration groups
+var groupRanges = map[string][]rangeRule{
+{{- range .Prefixes}}
+	{{printf "%q" .Prefix}}: { // {{.Agency}}
+	{{- range .Rules}}
+		{ {{- .Start}}, {{.End}}, {{.Length -}} },
+	{{- end}}
+	},
+{{- end}}
+}
+
+// registrantRanges splits the digits after the registration group into
+// registrant and publication elements
+var registrantRanges = map[string][]rangeRule{
+{{- range .Groups}}
+	{{printf "%q" .Prefix}}: { // {{.Agency}}
+	{{- range .Rules}}
+		{ {{- .Start}}, {{.End}}, {{.Length -}} },
+	{{- end}}
+	},
+{{- end}}
+}
+`))
+
+func main() {
+	out := flag.String("o", "rangetable.go", "file to write the tables to")
+	flag.Parse()
+
+	data, err := readMessage(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("read range message: %v", err)
+	}
+
+	src, err := generate(data)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// generate returns the formatted source of the tables in the range message
+func generate(data []byte) ([]byte, error) {
+	var message rangeMessage
+	if err := xml.Unmarshal(data, &message); err != nil {
+		return nil, fmt.Errorf("parse range message: %w", err)
+	}
+
+	prefixes, err := tableGroups(message.Prefixes)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := tableGroups(message.Groups)
+	if err != nil {
+		return nil, err
+	}
+	if len(prefixes) == 0 || len(groups) == 0 {
+		return nil, fmt.Errorf("range message lists no ranges")
+	}
+
+	var buf bytes.Buffer
+	err = tableTemplate.Execute(&buf, map[string]any{
+		"Source":   message.Source,
+		"Serial":   message.Serial,
+		"Date":     message.Date,
+		"Prefixes": prefixes,
+		"Groups":   groups,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("write tables: %w", err)
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format tables: %w", err)
+	}
+	return src, nil
+}
+
+// readMessage reads the range message from path, or downloads it when path
+// is empty
+func readMessage(path string) ([]byte, error) {
+	if path != "" {
+		return os.ReadFile(path)
+	}
+
+	client := &http.Client{Timeout: time.Minute}
+	resp, err := client.Get(rangeMessageURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// tableGroups parses the rules of groups. Ranges are seven digit values
+// like 0000000-5999999.
+func tableGroups(groups []rangeGroup) ([]tableGroup, error) {
+	table := make([]tableGroup, 0, len(groups))
+	for _, group := range groups {
+		entry := tableGroup{
+			Prefix: strings.TrimSpace(group.Prefix),
+			Agency: strings.Join(strings.Fields(group.Agency), " "),
+		}
+		for _, rule := range group.Rules {
+			start, end, ok := strings.Cut(strings.TrimSpace(rule.Range), "-")
+			if !ok || len(start) != 7 || len(end) != 7 {
+				return nil, fmt.Errorf("group %s: malformed range %q", entry.Prefix, rule.Range)
+			}
+			first, err := strconv.Atoi(start)
+			if err != nil {
+				return nil, fmt.Errorf("group %s: malformed range %q", entry.Prefix, rule.Range)
+			}
+			last, err := strconv.Atoi(end)
+			if err != nil || last < first {
+				return nil, fmt.Errorf("group %s: malformed range %q", entry.Prefix, rule.Range)
+			}
+			entry.Rules = append(entry.Rules, tableRule{Start: first, End: last, Length: rule.Length})
+		}
+		table = append(table, entry)
+	}
+	return table, nil
+}
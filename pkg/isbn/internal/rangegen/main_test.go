@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// sampleMessage has the layout of RangeMessage.xml, cut down to a prefix
+// and a group
+const sampleMessage = `<?xml version="1.0" encoding="UTF-8"?>
+<ISBNRangeMessage>
+  <MessageSource>International ISBN Agency</MessageSource>
+  <MessageSerialNumber>sample</MessageSerialNumber>
+  <MessageDate>Sample date</MessageDate>
+  <EAN.UCCPrefixes>
+    <EAN.UCC>
+      <Prefix>978</Prefix>
+      <Agency>International ISBN Agency</Agency>
+      <Rules>
+        <Rule><Range>0000000-5999999</Range><Length>1</Length></Rule>
+        <Rule><Range>6000000-6499999</Range><Length>3</Length></Rule>
+      </Rules>
+    </EAN.UCC>
+  </EAN.UCCPrefixes>
+  <RegistrationGroups>
+    <Group>
+      <Prefix>978-0</Prefix>
+      <Agency>English
+        language</Agency>
+      <Rules>
+        <Rule><Range>0000000-1999999</Range><Length>2</Length></Rule>
+        <Rule><Range>2000000-6999999</Range><Length>3</Length></Rule>
+      </Rules>
+    </Group>
+  </RegistrationGroups>
+</ISBNRangeMessage>`
+
+func TestGenerate(t *testing.T) {
+	src, err := generate([]byte(sampleMessage))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"// Code generated by rangegen from RangeMessage.xml; DO NOT EDIT.",
+		"// International ISBN Agency, message sample of Sample date",
+		"\"978\": { // International ISBN Agency\n\t\t{0, 5999999, 1},\n\t\t{6000000, 6499999, 3},\n\t},",
+		"\"978-0\": { // English language\n\t\t{0, 1999999, 2},\n\t\t{2000000, 6999999, 3},\n\t},",
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("generated source lacks %q:\n%s", want, src)
+		}
+	}
+}
+
+func TestGenerateMalformed(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+	}{
+		{"not xml", "{}"},
+		{"no ranges", "<ISBNRangeMessage></ISBNRangeMessage>"},
+		{"short range", strings.Replace(sampleMessage, "0000000-5999999", "000000-5999999", 1)},
+		{"reversed range", strings.Replace(sampleMessage, "2000000-6999999", "6999999-2000000", 1)},
+		{"letters in range", strings.Replace(sampleMessage, "6000000-6499999", "60000x0-6499999", 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := generate([]byte(tt.message)); err == nil {
+				t.Error("generate succeeded, want an error")
+			}
+		})
+	}
+}
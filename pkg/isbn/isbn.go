@@ -0,0 +1,184 @@
+// Package isbn parses, validates and converts International Standard Book
+// Numbers. ISBN-10, ISBN-13 and EAN-13 Bookland barcodes (978 and 979
+// prefixes, optionally followed by a 2 or 5 digit add-on) are accepted; every
+// ISBN is normalized to its 13 digit form.
+package isbn
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrInvalid          = errors.New("invalid isbn")
+	ErrInvalidLength    = fmt.Errorf("%w: must have 10 or 13 digits", ErrInvalid)
+	ErrInvalidCharacter = fmt.Errorf("%w: unexpected character", ErrInvalid)
+	ErrInvalidChecksum  = fmt.Errorf("%w: checksum mismatch", ErrInvalid)
+	ErrNotBookland      = fmt.Errorf("%w: EAN-13 prefix is not 978 or 979", ErrInvalid)
+	ErrNoISBN10         = errors.New("isbn has no ISBN-10 form")
+	ErrUnknownRange     = errors.New("isbn is outside the known registration ranges")
+)
+
+// ISBN is a validated ISBN in its 13 digit form
+type ISBN struct {
+	digits string
+}
+
+// Parse validates s and returns the ISBN it represents. Hyphens, spaces and an
+// "ISBN", "ISBN-10:" or "ISBN-13:" label are ignored.
+func Parse(s string) (ISBN, error) {
+	code := clean(s)
+
+	// EAN-13 barcodes may carry a 2 or 5 digit price add-on
+	if (len(code) == 15 || len(code) == 18) && isBookland(code) {
+		code = code[:13]
+	}
+
+	switch len(code) {
+	case 10:
+		for i := 0; i < 9; i++ {
+			if !isDigit(code[i]) {
+				return ISBN{}, ErrInvalidCharacter
+			}
+		}
+		if !isDigit(code[9]) && code[9] != 'X' {
+			return ISBN{}, ErrInvalidCharacter
+		}
+		if checkDigit10(code[:9]) != code[9] {
+			return ISBN{}, ErrInvalidChecksum
+		}
+		body := "978" + code[:9]
+		return ISBN{digits: body + string(checkDigit13(body))}, nil
+	case 13:
+		for i := 0; i < 13; i++ {
+			if !isDigit(code[i]) {
+				return ISBN{}, ErrInvalidCharacter
+			}
+		}
+		if !isBookland(code) {
+			return ISBN{}, ErrNotBookland
+		}
+		if checkDigit13(code[:12]) != code[12] {
+			return ISBN{}, ErrInvalidChecksum
+		}
+		return ISBN{digits: code}, nil
+	default:
+		return ISBN{}, ErrInvalidLength
+	}
+}
+
+// Normalize returns the unhyphenated ISBN-13 form of s
+func Normalize(s string) (string, error) {
+	i, err := Parse(s)
+	if err != nil {
+		return "", err
+	}
+	return i.ISBN13(), nil
+}
+
+// Valid reports whether s is a valid ISBN-10, ISBN-13 or Bookland EAN-13
+func Valid(s string) bool {
+	_, err := Parse(s)
+	return err == nil
+}
+
+// To13 converts an ISBN-10 (or ISBN-13) to its unhyphenated ISBN-13 form
+func To13(s string) (string, error) {
+	return Normalize(s)
+}
+
+// To10 converts an ISBN-13 (or ISBN-10) to its unhyphenated ISBN-10 form
+func To10(s string) (string, error) {
+	i, err := Parse(s)
+	if err != nil {
+		return "", err
+	}
+	return i.ISBN10()
+}
+
+// ISBN13 returns the unhyphenated 13 digit form
+func (i ISBN) ISBN13() string {
+	return i.digits
+}
+
+// ISBN10 returns the unhyphenated 10 digit form. ISBNs with the 979 prefix
+// have no ISBN-10 and return ErrNoISBN10.
+func (i ISBN) ISBN10() (string, error) {
+	if i.IsZero() {
+		return "", ErrInvalid
+	}
+	if !strings.HasPrefix(i.digits, "978") {
+		return "", ErrNoISBN10
+	}
+	body := i.digits[3:12]
+	return body + string(checkDigit10(body)), nil
+}
+
+// Prefix returns the GS1 prefix, 978 or 979
+func (i ISBN) Prefix() string {
+	if i.IsZero() {
+		return ""
+	}
+	return i.digits[:3]
+}
+
+// IsZero reports whether i is the zero value
+func (i ISBN) IsZero() bool {
+	return i.digits == ""
+}
+
+func (i ISBN) String() string {
+	return i.digits
+}
+
+func clean(s string) string {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	for _, label := range []string{"ISBN-13", "ISBN-10", "ISBN13", "ISBN10", "ISBN"} {
+		if strings.HasPrefix(s, label) {
+			s = strings.TrimPrefix(s[len(label):], ":")
+			break
+		}
+	}
+
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' || r == '‐' || r == '‑' {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+func isBookland(code string) bool {
+	return strings.HasPrefix(code, "978") || strings.HasPrefix(code, "979")
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// checkDigit10 computes the ISBN-10 check digit for 9 digits
+func checkDigit10(body string) byte {
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += int(body[i]-'0') * (10 - i)
+	}
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		return 'X'
+	}
+	return byte('0' + check)
+}
+
+// checkDigit13 computes the EAN-13 check digit for 12 digits
+func checkDigit13(body string) byte {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		d := int(body[i] - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
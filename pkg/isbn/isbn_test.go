@@ -0,0 +1,156 @@
+package isbn
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"isbn-13", "9780306406157", "9780306406157"},
+		{"hyphenated isbn-13", "978-0-306-40615-7", "9780306406157"},
+		{"isbn-10", "0306406152", "9780306406157"},
+		{"hyphenated isbn-10", "0-306-40615-2", "9780306406157"},
+		{"spaced isbn-10", "0 306 40615 2", "9780306406157"},
+		{"isbn-10 with x check digit", "316148410X", "9783161484100"},
+		{"lowercase x check digit", "3-16-148410-x", "9783161484100"},
+		{"label", "ISBN 978-0-306-40615-7", "9780306406157"},
+		{"isbn-13 label", "ISBN-13: 978-0-306-40615-7", "9780306406157"},
+		{"isbn-10 label", "ISBN-10: 0-306-40615-2", "9780306406157"},
+		{"979 prefix", "979-10-90636-07-1", "9791090636071"},
+		{"ean with 2 digit add-on", "978030640615712", "9780306406157"},
+		{"ean with 5 digit add-on", "978030640615751299", "9780306406157"},
+		{"surrounding space", "  9780306406157 ", "9780306406157"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Normalize(tt.in)
+			if err != nil {
+				t.Fatalf("Normalize(%q): %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want error
+	}{
+		{"isbn-13 checksum", "9780306406158", ErrInvalidChecksum},
+		{"isbn-10 checksum", "0306406153", ErrInvalidChecksum},
+		{"isbn-10 x where a digit belongs", "030640615X", ErrInvalidChecksum},
+		{"x inside isbn-10", "03064X6152", ErrInvalidCharacter},
+		{"letter in isbn-13", "978030640615A", ErrInvalidCharacter},
+		{"not bookland", "9770306406157", ErrNotBookland},
+		{"too short", "978030640615", ErrInvalidLength},
+		{"add-on on a non-bookland ean", "977030640615712", ErrInvalidLength},
+		{"add-on of the wrong length", "9780306406157123", ErrInvalidLength},
+		{"empty", "", ErrInvalidLength},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.in)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Parse(%q) error = %v, want %v", tt.in, err, tt.want)
+			}
+			if !errors.Is(err, ErrInvalid) {
+				t.Errorf("Parse(%q) error = %v, want it to match ErrInvalid", tt.in, err)
+			}
+			if Valid(tt.in) {
+				t.Errorf("Valid(%q) = true", tt.in)
+			}
+		})
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		isbn10, isbn13 string
+	}{
+		{"0306406152", "9780306406157"},
+		{"316148410X", "9783161484100"},
+		{"0140328726", "9780140328721"},
+		{"4101092052", "9784101092058"},
+	}
+	for _, tt := range tests {
+		got13, err := To13(tt.isbn10)
+		if err != nil || got13 != tt.isbn13 {
+			t.Errorf("To13(%q) = %q, %v, want %q", tt.isbn10, got13, err, tt.isbn13)
+		}
+		got10, err := To10(tt.isbn13)
+		if err != nil || got10 != tt.isbn10 {
+			t.Errorf("To10(%q) = %q, %v, want %q", tt.isbn13, got10, err, tt.isbn10)
+		}
+	}
+
+	if _, err := To10("9791090636071"); !errors.Is(err, ErrNoISBN10) {
+		t.Errorf("To10 of a 979 isbn: err = %v, want ErrNoISBN10", err)
+	}
+}
+
+func TestHyphenated(t *testing.T) {
+	tests := []struct {
+		isbn     string
+		want     string
+		want10   string
+		no10Form bool
+	}{
+		{isbn: "9780306406157", want: "978-0-306-40615-7", want10: "0-306-40615-2"},
+		{isbn: "9780140328721", want: "978-0-14-032872-1", want10: "0-14-032872-6"},
+		{isbn: "9781861978769", want: "978-1-86197-876-9", want10: "1-86197-876-6"},
+		{isbn: "9782070368228", want: "978-2-07-036822-8", want10: "2-07-036822-X"},
+		{isbn: "9783161484100", want: "978-3-16-148410-0", want10: "3-16-148410-X"},
+		{isbn: "9784101092058", want: "978-4-10-109205-8", want10: "4-10-109205-2"},
+		{isbn: "9787020002207", want: "978-7-02-000220-7", want10: "7-02-000220-X"},
+		{isbn: "9791090636071", want: "979-10-90636-07-1", no10Form: true},
+		{isbn: "9791158390006", want: "979-11-5839-000-6", no10Form: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.isbn, func(t *testing.T) {
+			i, err := Parse(tt.isbn)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := i.Hyphenated()
+			if err != nil || got != tt.want {
+				t.Errorf("Hyphenated() = %q, %v, want %q", got, err, tt.want)
+			}
+
+			got10, err := i.Hyphenated10()
+			if tt.no10Form {
+				if !errors.Is(err, ErrNoISBN10) {
+					t.Errorf("Hyphenated10() error = %v, want ErrNoISBN10", err)
+				}
+				return
+			}
+			if err != nil || got10 != tt.want10 {
+				t.Errorf("Hyphenated10() = %q, %v, want %q", got10, err, tt.want10)
+			}
+		})
+	}
+}
+
+func TestHyphenatedUnknownRange(t *testing.T) {
+	// 979-0 is the prefix of music numbers, which has no isbn groups
+	i, err := Parse("9790000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := i.Hyphenated(); !errors.Is(err, ErrUnknownRange) {
+		t.Errorf("Hyphenated() error = %v, want ErrUnknownRange", err)
+	}
+
+	if _, err := (ISBN{}).Hyphenated(); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Hyphenated() of the zero ISBN: error = %v, want ErrInvalid", err)
+	}
+}
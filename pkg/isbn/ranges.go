@@ -0,0 +1,86 @@
+package isbn
+
+import (
+	"strconv"
+	"strings"
+)
+
+//go:generate go run ./internal/rangegen -o rangetable.go
+
+// rangeRule assigns a segment length to a span of 7 digit values, following
+// the layout of the International ISBN Agency's RangeMessage.xml. A length of
+// zero marks a span that is not in use.
+type rangeRule struct {
+	start, end int
+	length     int
+}
+
+// Hyphenated returns the ISBN-13 split into prefix, registration group,
+// registrant, publication and check digit. ErrUnknownRange is returned when
+// the group or registrant ranges are not known.
+func (i ISBN) Hyphenated() (string, error) {
+	if i.IsZero() {
+		return "", ErrInvalid
+	}
+
+	prefix := i.Prefix()
+	rest := i.digits[3:12]
+
+	groupLen := lookupRange(groupRanges[prefix], rest)
+	if groupLen == 0 {
+		return "", ErrUnknownRange
+	}
+	group := rest[:groupLen]
+	rest = rest[groupLen:]
+
+	registrantLen := lookupRange(registrantRanges[prefix+"-"+group], rest)
+	if registrantLen == 0 || registrantLen >= len(rest) {
+		return "", ErrUnknownRange
+	}
+
+	return strings.Join([]string{
+		prefix,
+		group,
+		rest[:registrantLen],
+		rest[registrantLen:],
+		i.digits[12:],
+	}, "-"), nil
+}
+
+// Hyphenated10 returns the hyphenated ISBN-10 form
+func (i ISBN) Hyphenated10() (string, error) {
+	isbn13, err := i.Hyphenated()
+	if err != nil {
+		return "", err
+	}
+	isbn10, err := i.ISBN10()
+	if err != nil {
+		return "", err
+	}
+
+	// reuse the group and registrant split, swapping the check digit
+	parts := strings.Split(isbn13, "-")
+	parts[len(parts)-1] = isbn10[9:]
+	return strings.Join(parts[1:], "-"), nil
+}
+
+// lookupRange returns the segment length for digits, padded or truncated to
+// seven digits, or zero when no rule matches
+func lookupRange(rules []rangeRule, digits string) int {
+	if len(digits) > 7 {
+		digits = digits[:7]
+	}
+	digits += strings.Repeat("0", 7-len(digits))
+
+	value, err := strconv.Atoi(digits)
+	if err != nil {
+		return 0
+	}
+
+	for _, rule := range rules {
+		if value >= rule.start && value <= rule.end {
+			return rule.length
+		}
+	}
+	return 0
+}
@@ -0,0 +1,99 @@
+package isbn
+
+// groupRanges splits the digits after the GS1 prefix into registration groups
+var groupRanges = map[string][]rangeRule{
+	"978": {
+		{0, 5999999, 1},
+		{6000000, 6499999, 3},
+		{6500000, 6599999, 2},
+		{6600000, 6999999, 0},
+		{7000000, 7999999, 1},
+		{8000000, 9499999, 2},
+		{9500000, 9899999, 3},
+		{9900000, 9989999, 4},
+		{9990000, 9999999, 5},
+	},
+	"979": {
+		{0, 999999, 0},
+		{1000000, 1299999, 2},
+		{1300000, 7999999, 0},
+		{8000000, 8999999, 1},
+		{9000000, 9999999, 0},
+	},
+}
+
+// registrantRanges splits the digits after the registration group into
+// registrant and publication elements. Only the largest groups are listed
+// until the table is regenerated with go generate.
+var registrantRanges = map[string][]rangeRule{
+	"978-0": {
+		{0, 1999999, 2},
+		{2000000, 6999999, 3},
+		{7000000, 8499999, 4},
+		{8500000, 8999999, 5},
+		{9000000, 9499999, 6},
+		{9500000, 9999999, 7},
+	},
+	"978-1": {
+		{0, 999999, 2},
+		{1000000, 3999999, 3},
+		{4000000, 5499999, 4},
+		{5500000, 8697999, 5},
+		{8698000, 9989999, 6},
+		{9990000, 9999999, 7},
+	},
+	"978-2": {
+		{0, 1999999, 2},
+		{2000000, 3499999, 3},
+		{3500000, 3999999, 5},
+		{4000000, 6999999, 3},
+		{7000000, 8399999, 4},
+		{8400000, 8999999, 5},
+		{9000000, 9499999, 6},
+		{9500000, 9999999, 7},
+	},
+	"978-3": {
+		{0, 299999, 2},
+		{300000, 339999, 3},
+		{340000, 369999, 4},
+		{370000, 399999, 5},
+		{400000, 1999999, 2},
+		{2000000, 6999999, 3},
+		{7000000, 8499999, 4},
+		{8500000, 8999999, 5},
+		{9000000, 9499999, 6},
+		{9500000, 9539999, 7},
+		{9540000, 9699999, 5},
+		{9700000, 9849999, 7},
+		{9850000, 9999999, 5},
+	},
+	"978-4": {
+		{0, 1999999, 2},
+		{2000000, 6999999, 3},
+		{7000000, 8499999, 4},
+		{8500000, 8999999, 5},
+		{9000000, 9499999, 6},
+		{9500000, 9999999, 7},
+	},
+	"978-7": {
+		{0, 999999, 2},
+		{1000000, 4999999, 3},
+		{5000000, 7999999, 4},
+		{8000000, 8999999, 5},
+		{9000000, 9999999, 6},
+	},
+	"979-10": {
+		{0, 1999999, 2},
+		{2000000, 6999999, 3},
+		{7000000, 8999999, 4},
+		{9000000, 9759999, 5},
+		{9760000, 9999999, 6},
+	},
+	"979-11": {
+		{0, 2499999, 2},
+		{2500000, 5499999, 3},
+		{5500000, 8499999, 4},
+		{8500000, 9499999, 5},
+		{9500000, 9999999, 6},
+	},
+}
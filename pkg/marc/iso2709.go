@@ -0,0 +1,233 @@
+package marc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// ISO 2709 delimiters
+const (
+	subfieldDelimiter = 0x1f
+	fieldTerminator   = 0x1e
+	recordTerminator  = 0x1d
+)
+
+const (
+	leaderLength         = 24
+	directoryEntryLength = 12
+	maxRecordLength      = 99999
+	maxFieldLength       = 9999
+
+	// DefaultLeader is the leader of a new record: a new, unicode encoded
+	// monograph in RDA form. Lengths are filled in when it is encoded.
+	DefaultLeader = "00000nam a2200000 i 4500"
+)
+
+var (
+	ErrMalformedRecord = errors.New("malformed marc record")
+	ErrRecordTooLong   = errors.New("marc record too long")
+)
+
+// MarshalISO2709 encodes the record in the ISO 2709 exchange format. The
+// record length and base address of the leader are recomputed and the
+// character coding is set to unicode.
+func (r *Record) MarshalISO2709() ([]byte, error) {
+	var directory, data bytes.Buffer
+
+	addField := func(tag string, value []byte) error {
+		if len(tag) != 3 {
+			return fmt.Errorf("%w: invalid tag %q", ErrMalformedRecord, tag)
+		}
+		if len(value) > maxFieldLength {
+			return fmt.Errorf("%w: field %s is %d bytes", ErrRecordTooLong, tag, len(value))
+		}
+		fmt.Fprintf(&directory, "%s%04d%05d", tag, len(value), data.Len())
+		data.Write(value)
+		return nil
+	}
+
+	for _, f := range r.ControlFields {
+		if err := addField(f.Tag, append([]byte(f.Value), fieldTerminator)); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, f := range r.DataFields {
+		var field bytes.Buffer
+		field.WriteString(indicator(f.Ind1))
+		field.WriteString(indicator(f.Ind2))
+		for _, sf := range f.Subfields {
+			field.WriteByte(subfieldDelimiter)
+			field.WriteString(sf.Code)
+			field.WriteString(sf.Value)
+		}
+		field.WriteByte(fieldTerminator)
+
+		if err := addField(f.Tag, field.Bytes()); err != nil {
+			return nil, err
+		}
+	}
+	directory.WriteByte(fieldTerminator)
+
+	baseAddress := leaderLength + directory.Len()
+	length := baseAddress + data.Len() + 1
+	if length > maxRecordLength {
+		return nil, fmt.Errorf("%w: %d bytes", ErrRecordTooLong, length)
+	}
+
+	leader := []byte(DefaultLeader)
+	if len(r.Leader) == leaderLength {
+		leader = []byte(r.Leader)
+	}
+	copy(leader[0:5], fmt.Sprintf("%05d", length))
+	leader[9] = 'a'
+	copy(leader[10:12], "22")
+	copy(leader[12:17], fmt.Sprintf("%05d", baseAddress))
+	copy(leader[20:24], "4500")
+
+	out := make([]byte, 0, length)
+	out = append(out, leader...)
+	out = append(out, directory.Bytes()...)
+	out = append(out, data.Bytes()...)
+	out = append(out, recordTerminator)
+	return out, nil
+}
+
+// UnmarshalISO2709 decodes one ISO 2709 record. Records in MARC-8 are read
+// byte for byte; only their ASCII subset is decoded faithfully.
+func (r *Record) UnmarshalISO2709(data []byte) error {
+	data = bytes.TrimRight(data, "\r\n")
+	if len(data) < leaderLength+1 || data[len(data)-1] != recordTerminator {
+		return fmt.Errorf("%w: truncated record", ErrMalformedRecord)
+	}
+
+	leader := string(data[:leaderLength])
+	baseAddress, ok := parseDigits(leader[12:17])
+	if !ok || baseAddress <= leaderLength || baseAddress > len(data) {
+		return fmt.Errorf("%w: invalid base address %q", ErrMalformedRecord, leader[12:17])
+	}
+
+	directory := data[leaderLength : baseAddress-1]
+	if len(directory)%directoryEntryLength != 0 {
+		return fmt.Errorf("%w: invalid directory length %d", ErrMalformedRecord, len(directory))
+	}
+	fields := data[baseAddress:]
+
+	record := Record{Leader: leader}
+	for i := 0; i < len(directory); i += directoryEntryLength {
+		entry := string(directory[i : i+directoryEntryLength])
+		tag := entry[:3]
+		length, ok1 := parseDigits(entry[3:7])
+		start, ok2 := parseDigits(entry[7:12])
+		if !ok1 || !ok2 || length < 1 || start+length > len(fields) {
+			return fmt.Errorf("%w: invalid directory entry %q", ErrMalformedRecord, entry)
+		}
+
+		value := bytes.TrimSuffix(fields[start:start+length], []byte{fieldTerminator})
+		if IsControlTag(tag) {
+			record.ControlFields = append(record.ControlFields, ControlField{Tag: tag, Value: string(value)})
+			continue
+		}
+
+		field := DataField{Tag: tag, Ind1: " ", Ind2: " "}
+		parts := bytes.Split(value, []byte{subfieldDelimiter})
+		if indicators := parts[0]; len(indicators) >= 2 {
+			field.Ind1, field.Ind2 = string(indicators[0]), string(indicators[1])
+		}
+		for _, part := range parts[1:] {
+			if len(part) == 0 {
+				continue
+			}
+			field.Subfields = append(field.Subfields, Subfield{Code: string(part[0]), Value: string(part[1:])})
+		}
+		record.DataFields = append(record.DataFields, field)
+	}
+
+	*r = record
+	return nil
+}
+
+// parseDigits parses a fixed width number of the leader or directory, which
+// holds only ascii digits: no sign, spaces or other forms Atoi accepts
+func parseDigits(s string) (int, bool) {
+	if s == "" {
+		return 0, false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return 0, false
+		}
+	}
+	n, err := strconv.Atoi(s)
+	return n, err == nil
+}
+
+// IsControlTag reports whether tag is a control field tag, 001 to 009
+func IsControlTag(tag string) bool {
+	return len(tag) == 3 && tag[0] == '0' && tag[1] == '0'
+}
+
+func indicator(value string) string {
+	if value == "" {
+		return " "
+	}
+	return value[:1]
+}
+
+// Reader reads a stream of ISO 2709 records
+type Reader struct {
+	r *bufio.Reader
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Next returns the next record, or io.EOF after the last one. A malformed
+// record yields an error wrapping ErrMalformedRecord; reading may continue
+// with the record after it.
+func (r *Reader) Next() (*Record, error) {
+	for {
+		data, err := r.r.ReadBytes(recordTerminator)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+
+		// records may be separated by line breaks
+		data = bytes.TrimLeft(data, "\r\n\t ")
+		if len(data) == 0 {
+			if err != nil {
+				return nil, io.EOF
+			}
+			continue
+		}
+
+		var record Record
+		if err := record.UnmarshalISO2709(data); err != nil {
+			return nil, err
+		}
+		return &record, nil
+	}
+}
+
+// Writer writes records in ISO 2709
+type Writer struct {
+	w io.Writer
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+func (w *Writer) Write(record *Record) error {
+	data, err := record.MarshalISO2709()
+	if err != nil {
+		return err
+	}
+	_, err = w.w.Write(data)
+	return err
+}
@@ -0,0 +1,195 @@
+package marc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/gracchi-stdio/barf/pkg/bookfetcher"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func testRecord() *Record {
+	return &Record{
+		Leader: DefaultLeader,
+		ControlFields: []ControlField{
+			{Tag: "001", Value: "ol123"},
+			{Tag: "008", Value: "                                   eng  "},
+		},
+		DataFields: []DataField{
+			{Tag: "020", Ind1: " ", Ind2: " ", Subfields: []Subfield{{Code: "a", Value: "9780306406157"}}},
+			{Tag: "100", Ind1: "1", Ind2: " ", Subfields: []Subfield{{Code: "a", Value: "Brontë, Charlotte"}}},
+			{Tag: "245", Ind1: "1", Ind2: "0", Subfields: []Subfield{
+				{Code: "a", Value: "Jane Eyre :"},
+				{Code: "b", Value: "an autobiography"},
+			}},
+			{Tag: "264", Ind1: " ", Ind2: "1", Subfields: []Subfield{
+				{Code: "b", Value: "Smith, Elder & Co.,"},
+				{Code: "c", Value: "1847"},
+			}},
+		},
+	}
+}
+
+func TestISO2709RoundTrip(t *testing.T) {
+	want := testRecord()
+
+	data, err := want.MarshalISO2709()
+	if err != nil {
+		t.Fatalf("MarshalISO2709: %v", err)
+	}
+	if data[len(data)-1] != recordTerminator {
+		t.Fatalf("record does not end with the record terminator")
+	}
+	if got, want := string(data[:5]), fmt.Sprintf("%05d", len(data)); got != want {
+		t.Errorf("leader record length = %s, want %s", got, want)
+	}
+
+	var got Record
+	if err := got.UnmarshalISO2709(data); err != nil {
+		t.Fatalf("UnmarshalISO2709: %v", err)
+	}
+	if !reflect.DeepEqual(got.ControlFields, want.ControlFields) {
+		t.Errorf("control fields = %+v, want %+v", got.ControlFields, want.ControlFields)
+	}
+	if !reflect.DeepEqual(got.DataFields, want.DataFields) {
+		t.Errorf("data fields = %+v, want %+v", got.DataFields, want.DataFields)
+	}
+
+	// the leader was recomputed, so encoding again gives the same bytes
+	again, err := got.MarshalISO2709()
+	if err != nil {
+		t.Fatalf("MarshalISO2709 again: %v", err)
+	}
+	if !bytes.Equal(again, data) {
+		t.Errorf("re-encoded record differs:\n got %q\nwant %q", again, data)
+	}
+}
+
+func TestReaderReadsStream(t *testing.T) {
+	first := testRecord()
+	second := testRecord()
+	second.ControlFields[0].Value = "ol456"
+
+	var stream bytes.Buffer
+	for _, r := range []*Record{first, second} {
+		data, err := r.MarshalISO2709()
+		if err != nil {
+			t.Fatal(err)
+		}
+		stream.Write(data)
+		stream.WriteString("\r\n")
+	}
+
+	reader := NewReader(&stream)
+	for _, want := range []string{"ol123", "ol456"} {
+		record, err := reader.Next()
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		if got := record.ControlField("001"); got != want {
+			t.Errorf("001 = %q, want %q", got, want)
+		}
+	}
+	if _, err := reader.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("Next after the last record = %v, want io.EOF", err)
+	}
+}
+
+func TestUnmarshalISO2709Malformed(t *testing.T) {
+	valid, err := testRecord().MarshalISO2709()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// directory entries follow the 24 byte leader, 12 bytes each: tag,
+	// 4 digit length, 5 digit start
+	withEntry := func(entry string) []byte {
+		data := bytes.Clone(valid)
+		copy(data[leaderLength:leaderLength+directoryEntryLength], entry)
+		return data
+	}
+	withBaseAddress := func(address string) []byte {
+		data := bytes.Clone(valid)
+		copy(data[12:17], address)
+		return data
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"truncated", valid[:len(valid)-10]},
+		{"no record terminator", valid[:len(valid)-1]},
+		{"negative start", withEntry("0010006-0001")},
+		{"signed length", withEntry("001+00600000")},
+		{"spaces in start", withEntry("0010006 0000")},
+		{"start past the data", withEntry("001000699990")},
+		{"zero length", withEntry("001000000000")},
+		{"negative base address", withBaseAddress("-0001")},
+		{"base address past the record", withBaseAddress("99999")},
+		{"base address inside the leader", withBaseAddress("00010")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var record Record
+			err := record.UnmarshalISO2709(tt.data)
+			if !errors.Is(err, ErrMalformedRecord) {
+				t.Errorf("UnmarshalISO2709 = %v, want ErrMalformedRecord", err)
+			}
+		})
+	}
+}
+
+func TestMarshalISO2709RejectsLongRecords(t *testing.T) {
+	record := testRecord()
+	record.DataFields[0].Subfields[0].Value = string(bytes.Repeat([]byte("x"), maxFieldLength))
+
+	if _, err := record.MarshalISO2709(); !errors.Is(err, ErrRecordTooLong) {
+		t.Errorf("MarshalISO2709 = %v, want ErrRecordTooLong", err)
+	}
+}
+
+func TestMappingRoundTrip(t *testing.T) {
+	want := &bookfetcher.BookInfo{
+		ProviderID:      "ol123",
+		ISBN:            "9780306406157",
+		ISBN13:          "9780306406157",
+		ISBN10:          "0306406152",
+		Title:           "Jane Eyre: an autobiography",
+		Authors:         []string{"Charlotte Brontë", "Currer Bell"},
+		Publisher:       "Smith, Elder & Co.",
+		PublicationDate: "1847",
+		PageCount:       448,
+		Description:     "A novel.",
+		Categories:      []string{"Governesses", "Orphans"},
+		Language:        "eng",
+	}
+
+	record := DefaultMapping.Record(want)
+
+	data, err := record.MarshalISO2709()
+	if err != nil {
+		t.Fatalf("MarshalISO2709: %v", err)
+	}
+	var fromISO Record
+	if err := fromISO.UnmarshalISO2709(data); err != nil {
+		t.Fatalf("UnmarshalISO2709: %v", err)
+	}
+
+	xmlData, err := MarshalXML(record)
+	if err != nil {
+		t.Fatalf("MarshalXML: %v", err)
+	}
+	fromXML, err := NewXMLReader(bytes.NewReader(xmlData)).Next()
+	if err != nil {
+		t.Fatalf("XMLReader.Next: %v", err)
+	}
+
+	for name, r := range map[string]*Record{"iso2709": &fromISO, "marcxml": fromXML} {
+		if got := DefaultMapping.BookInfo(r); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: BookInfo = %+v, want %+v", name, got, want)
+		}
+	}
+}
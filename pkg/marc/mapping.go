@@ -0,0 +1,435 @@
+package marc
+
+import (
+	"fmt"
+	"github.com/gracchi-stdio/barf/pkg/bookfetcher"
+	"github.com/gracchi-stdio/barf/pkg/isbn"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Field is a book field that a Mapping places in a record
+type Field string
+
+const (
+	FieldControlNumber   Field = "control_number"
+	FieldISBN            Field = "isbn"
+	FieldTitle           Field = "title"
+	FieldSubtitle        Field = "subtitle"
+	FieldAuthors         Field = "authors"
+	FieldPublisher       Field = "publisher"
+	FieldPublicationDate Field = "publication_date"
+	FieldPageCount       Field = "page_count"
+	FieldDescription     Field = "description"
+	FieldCategories      Field = "categories"
+	FieldLanguage        Field = "language"
+)
+
+// Spec addresses a value in a record: a subfield of a data field, such as
+// 245$a, or a control field or a character range of one, such as 001 or
+// 008/35-37.
+type Spec struct {
+	Tag string
+	// Ind1 and Ind2 restrict the indicators of matched data fields. Empty
+	// matches any indicator and is written as a blank.
+	Ind1, Ind2 string
+	Code       string
+	// Start and End select the characters [Start, End) of a control field;
+	// both are zero for the whole field
+	Start, End int
+}
+
+// ParseSpec parses "TAG$c", "TAG12$c" with indicators ("_" is a blank, "*"
+// any indicator), "TAG" for a control field or "TAG/start-end" for a range of
+// one, positions inclusive as in the MARC documentation.
+func ParseSpec(s string) (Spec, error) {
+	if len(s) < 3 {
+		return Spec{}, fmt.Errorf("invalid marc spec %q", s)
+	}
+	spec := Spec{Tag: s[:3]}
+	rest := s[3:]
+
+	if IsControlTag(spec.Tag) {
+		if rest == "" {
+			return spec, nil
+		}
+		from, to, ok := strings.Cut(strings.TrimPrefix(rest, "/"), "-")
+		if !ok {
+			to = from
+		}
+		start, err1 := strconv.Atoi(from)
+		end, err2 := strconv.Atoi(to)
+		if !strings.HasPrefix(rest, "/") || err1 != nil || err2 != nil || start < 0 || end < start {
+			return Spec{}, fmt.Errorf("invalid marc spec %q", s)
+		}
+		spec.Start, spec.End = start, end+1
+		return spec, nil
+	}
+
+	indicators, code, ok := strings.Cut(rest, "$")
+	if !ok || len(code) != 1 || (len(indicators) != 0 && len(indicators) != 2) {
+		return Spec{}, fmt.Errorf("invalid marc spec %q", s)
+	}
+	if len(indicators) == 2 {
+		spec.Ind1, spec.Ind2 = parseIndicator(indicators[0]), parseIndicator(indicators[1])
+	}
+	spec.Code = code
+	return spec, nil
+}
+
+func parseIndicator(c byte) string {
+	switch c {
+	case '*':
+		return ""
+	case '_', '#':
+		return " "
+	}
+	return string(c)
+}
+
+func (s Spec) String() string {
+	if IsControlTag(s.Tag) {
+		if s.End == 0 {
+			return s.Tag
+		}
+		return fmt.Sprintf("%s/%02d-%02d", s.Tag, s.Start, s.End-1)
+	}
+	if s.Ind1 == "" && s.Ind2 == "" {
+		return s.Tag + "$" + s.Code
+	}
+	format := func(ind string) string {
+		switch ind {
+		case "":
+			return "*"
+		case " ":
+			return "_"
+		}
+		return ind
+	}
+	return s.Tag + format(s.Ind1) + format(s.Ind2) + "$" + s.Code
+}
+
+// Mapping lists, per field, where its values are found in a record. Reading
+// takes the first spec that has a value, or every value for the repeatable
+// fields authors, categories and isbn. Writing uses the first spec, except
+// for authors after the first, which use the last spec (the 100 main entry
+// and 700 added entries).
+type Mapping map[Field][]Spec
+
+// DefaultMapping follows the MARC 21 bibliographic format as catalogued
+// under RDA.
+var DefaultMapping = Mapping{
+	FieldControlNumber:   mustSpecs("001"),
+	FieldISBN:            mustSpecs("020$a"),
+	FieldTitle:           mustSpecs("245$a"),
+	FieldSubtitle:        mustSpecs("245$b"),
+	FieldAuthors:         mustSpecs("100$a", "700$a"),
+	FieldPublisher:       mustSpecs("264*1$b", "260$b"),
+	FieldPublicationDate: mustSpecs("264*1$c", "260$c"),
+	FieldPageCount:       mustSpecs("300$a"),
+	FieldDescription:     mustSpecs("520$a"),
+	FieldCategories:      mustSpecs("650$a"),
+	FieldLanguage:        mustSpecs("008/35-37"),
+}
+
+func mustSpecs(specs ...string) []Spec {
+	parsed, err := parseSpecs(specs)
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+func parseSpecs(specs []string) ([]Spec, error) {
+	parsed := make([]Spec, 0, len(specs))
+	for _, s := range specs {
+		spec, err := ParseSpec(strings.TrimSpace(s))
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, spec)
+	}
+	return parsed, nil
+}
+
+// ParseMapping builds a mapping from spec strings per field name, starting
+// from DefaultMapping. A field given an empty list is not mapped.
+func ParseMapping(config map[string][]string) (Mapping, error) {
+	mapping := make(Mapping, len(DefaultMapping))
+	for field, specs := range DefaultMapping {
+		mapping[field] = specs
+	}
+
+	for name, specs := range config {
+		field := Field(strings.ToLower(name))
+		if _, ok := DefaultMapping[field]; !ok {
+			return nil, fmt.Errorf("unknown marc mapping field %q", name)
+		}
+		parsed, err := parseSpecs(specs)
+		if err != nil {
+			return nil, fmt.Errorf("marc mapping %s: %w", name, err)
+		}
+		mapping[field] = parsed
+	}
+	return mapping, nil
+}
+
+var (
+	isoDatePattern = regexp.MustCompile(`^\d{4}(-\d{2}(-\d{2})?)?$`)
+	yearPattern    = regexp.MustCompile(`\d{4}`)
+	pagesPattern   = regexp.MustCompile(`(\d+)\s*(?:p\b|pages)`)
+	digitPattern   = regexp.MustCompile(`\d+`)
+)
+
+// BookInfo maps a bibliographic record to a BookInfo
+func (m Mapping) BookInfo(r *Record) *bookfetcher.BookInfo {
+	info := &bookfetcher.BookInfo{
+		ProviderID:  strings.TrimSpace(m.first(r, FieldControlNumber)),
+		Description: strings.TrimSpace(m.first(r, FieldDescription)),
+		Language:    strings.TrimSpace(m.first(r, FieldLanguage)),
+		Publisher:   Clean(m.first(r, FieldPublisher)),
+	}
+
+	// qualifiers like "(pbk.)" follow the isbn
+	for _, value := range m.all(r, FieldISBN) {
+		parsed, err := isbn.Parse(strings.Fields(value + " ")[0])
+		if err != nil {
+			continue
+		}
+		if info.ISBN13 == "" {
+			info.ISBN13 = parsed.ISBN13()
+			info.ISBN = parsed.ISBN13()
+		}
+		if info.ISBN10 == "" {
+			info.ISBN10, _ = parsed.ISBN10()
+		}
+	}
+
+	info.Title = Clean(m.first(r, FieldTitle))
+	if subtitle := Clean(m.first(r, FieldSubtitle)); subtitle != "" {
+		info.Title += ": " + subtitle
+	}
+
+	info.Authors = unique(m.all(r, FieldAuthors))
+	info.Categories = unique(m.all(r, FieldCategories))
+
+	// keep iso dates as written, otherwise take the year of statements
+	// like "[2019]" or "c2005"
+	date := Clean(m.first(r, FieldPublicationDate))
+	if year := yearPattern.FindString(date); !isoDatePattern.MatchString(date) && year != "" {
+		date = year
+	}
+	info.PublicationDate = date
+
+	// physical description, e.g. "xii, 345 p. :"
+	extent := m.first(r, FieldPageCount)
+	if match := pagesPattern.FindStringSubmatch(extent); match != nil {
+		info.PageCount, _ = strconv.Atoi(match[1])
+	} else if match := digitPattern.FindString(extent); match != "" {
+		info.PageCount, _ = strconv.Atoi(match)
+	}
+
+	return info
+}
+
+// Record maps a BookInfo to a bibliographic record. A title of the form
+// "title: subtitle" is split when the subtitle is mapped.
+func (m Mapping) Record(info *bookfetcher.BookInfo) *Record {
+	r := &Record{Leader: DefaultLeader}
+
+	m.set(r, FieldControlNumber, info.ProviderID)
+
+	code := info.ISBN13
+	if code == "" {
+		code = info.ISBN
+	}
+	if parsed, err := isbn.Parse(code); err == nil {
+		m.add(r, FieldISBN, parsed.ISBN13(), 0)
+		if isbn10, err := parsed.ISBN10(); err == nil {
+			m.add(r, FieldISBN, isbn10, 0)
+		}
+	} else {
+		m.add(r, FieldISBN, code, 0)
+	}
+
+	title, subtitle := info.Title, ""
+	if len(m[FieldSubtitle]) > 0 {
+		if before, after, ok := strings.Cut(title, ": "); ok {
+			title, subtitle = before, after
+		}
+	}
+	m.set(r, FieldTitle, title)
+	m.set(r, FieldSubtitle, subtitle)
+
+	for i, author := range info.Authors {
+		spec := 0
+		if i > 0 {
+			spec = len(m[FieldAuthors]) - 1
+		}
+		m.add(r, FieldAuthors, author, spec)
+	}
+
+	m.set(r, FieldPublisher, info.Publisher)
+	m.set(r, FieldPublicationDate, info.PublicationDate)
+	if info.PageCount > 0 {
+		m.set(r, FieldPageCount, fmt.Sprintf("%d pages", info.PageCount))
+	}
+	m.set(r, FieldDescription, info.Description)
+	for _, category := range info.Categories {
+		m.add(r, FieldCategories, category, 0)
+	}
+	m.set(r, FieldLanguage, info.Language)
+
+	sort.SliceStable(r.ControlFields, func(i, j int) bool { return r.ControlFields[i].Tag < r.ControlFields[j].Tag })
+	sort.SliceStable(r.DataFields, func(i, j int) bool { return r.DataFields[i].Tag < r.DataFields[j].Tag })
+	return r
+}
+
+// first returns the first non empty value of field
+func (m Mapping) first(r *Record, field Field) string {
+	for _, spec := range m[field] {
+		for _, value := range spec.values(r) {
+			if strings.TrimSpace(value) != "" {
+				return value
+			}
+		}
+	}
+	return ""
+}
+
+// all returns every value of field, cleaned of ISBD punctuation
+func (m Mapping) all(r *Record, field Field) []string {
+	var values []string
+	for _, spec := range m[field] {
+		for _, value := range spec.values(r) {
+			if value = Clean(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+// set writes a single valued field, adding the subfield to an existing field
+// with the same tag and indicators when it does not have it yet
+func (m Mapping) set(r *Record, field Field, value string) {
+	specs := m[field]
+	if len(specs) == 0 || value == "" {
+		return
+	}
+	spec := specs[0]
+
+	if IsControlTag(spec.Tag) {
+		spec.setControl(r, value)
+		return
+	}
+
+	for i := range r.DataFields {
+		f := &r.DataFields[i]
+		if f.Tag == spec.Tag && f.Ind1 == indicator(spec.Ind1) && f.Ind2 == indicator(spec.Ind2) && f.Subfield(spec.Code) == "" {
+			f.Subfields = append(f.Subfields, Subfield{Code: spec.Code, Value: value})
+			return
+		}
+	}
+	r.DataFields = append(r.DataFields, spec.field(value))
+}
+
+// add writes one value of a repeatable field in a field of its own, using
+// the spec at index i
+func (m Mapping) add(r *Record, field Field, value string, i int) {
+	specs := m[field]
+	if len(specs) == 0 || value == "" {
+		return
+	}
+	spec := specs[i]
+
+	if IsControlTag(spec.Tag) {
+		spec.setControl(r, value)
+		return
+	}
+	r.DataFields = append(r.DataFields, spec.field(value))
+}
+
+func (s Spec) field(value string) DataField {
+	return DataField{
+		Tag:       s.Tag,
+		Ind1:      indicator(s.Ind1),
+		Ind2:      indicator(s.Ind2),
+		Subfields: []Subfield{{Code: s.Code, Value: value}},
+	}
+}
+
+// values returns the values the spec addresses in r
+func (s Spec) values(r *Record) []string {
+	if IsControlTag(s.Tag) {
+		value := r.ControlField(s.Tag)
+		if s.End == 0 {
+			return []string{value}
+		}
+		if len(value) < s.End {
+			return nil
+		}
+		return []string{value[s.Start:s.End]}
+	}
+
+	var values []string
+	for _, f := range r.Fields(s.Tag) {
+		if (s.Ind1 != "" && f.Ind1 != s.Ind1) || (s.Ind2 != "" && f.Ind2 != s.Ind2) {
+			continue
+		}
+		values = append(values, f.SubfieldValues(s.Code)...)
+	}
+	return values
+}
+
+// setControl writes value to the control field, or to its character range
+// padded with blanks, creating the field if needed
+func (s Spec) setControl(r *Record, value string) {
+	i := -1
+	for j, f := range r.ControlFields {
+		if f.Tag == s.Tag {
+			i = j
+			break
+		}
+	}
+	if i < 0 {
+		r.ControlFields = append(r.ControlFields, ControlField{Tag: s.Tag})
+		i = len(r.ControlFields) - 1
+	}
+
+	if s.End == 0 {
+		r.ControlFields[i].Value = value
+		return
+	}
+
+	current := []byte(r.ControlFields[i].Value)
+	// 008 is 40 characters long; pad any field to the end of the range
+	length := max(s.End, len(current))
+	if s.Tag == "008" {
+		length = max(length, 40)
+	}
+	for len(current) < length {
+		current = append(current, ' ')
+	}
+
+	part := []byte(fmt.Sprintf("%-*s", s.End-s.Start, value))
+	copy(current[s.Start:s.End], part[:s.End-s.Start])
+	r.ControlFields[i].Value = string(current)
+}
+
+func unique(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	out := values[:0]
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			out = append(out, value)
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
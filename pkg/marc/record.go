@@ -0,0 +1,113 @@
+// Package marc models MARC 21 bibliographic records as exchanged in MARCXML.
+package marc
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+const Namespace = "http://www.loc.gov/MARC21/slim"
+
+// Record is a MARC 21 record in the MARCXML slim schema
+type Record struct {
+	XMLName       xml.Name       `xml:"record" json:"-"`
+	Leader        string         `xml:"leader" json:"leader"`
+	ControlFields []ControlField `xml:"controlfield" json:"control_fields"`
+	DataFields    []DataField    `xml:"datafield" json:"data_fields"`
+}
+
+type ControlField struct {
+	Tag   string `xml:"tag,attr" json:"tag"`
+	Value string `xml:",chardata" json:"value"`
+}
+
+type DataField struct {
+	Tag       string     `xml:"tag,attr" json:"tag"`
+	Ind1      string     `xml:"ind1,attr" json:"ind1"`
+	Ind2      string     `xml:"ind2,attr" json:"ind2"`
+	Subfields []Subfield `xml:"subfield" json:"subfields"`
+}
+
+type Subfield struct {
+	Code  string `xml:"code,attr" json:"code"`
+	Value string `xml:",chardata" json:"value"`
+}
+
+// ControlField returns the value of the first control field with tag
+func (r *Record) ControlField(tag string) string {
+	for _, f := range r.ControlFields {
+		if f.Tag == tag {
+			return f.Value
+		}
+	}
+	return ""
+}
+
+// Fields returns every data field with tag
+func (r *Record) Fields(tag string) []DataField {
+	var fields []DataField
+	for _, f := range r.DataFields {
+		if f.Tag == tag {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// Subfield returns the first value of subfield code in the first field with tag
+func (r *Record) Subfield(tag, code string) string {
+	for _, f := range r.Fields(tag) {
+		if v := f.Subfield(code); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// Subfield returns the first value of subfield code
+func (f DataField) Subfield(code string) string {
+	for _, sf := range f.Subfields {
+		if sf.Code == code {
+			return sf.Value
+		}
+	}
+	return ""
+}
+
+// SubfieldValues returns every value of subfield code
+func (f DataField) SubfieldValues(code string) []string {
+	var values []string
+	for _, sf := range f.Subfields {
+		if sf.Code == code {
+			values = append(values, sf.Value)
+		}
+	}
+	return values
+}
+
+// Clean strips the ISBD punctuation MARC leaves at the end of subfields,
+// e.g. "Title /" or "Publisher,"
+func Clean(value string) string {
+	value = strings.TrimSpace(value)
+	for {
+		trimmed := strings.TrimRight(value, " /:;,=")
+		// keep the period of initials and abbreviations like "Jr."
+		if strings.HasSuffix(trimmed, ".") && !endsWithAbbreviation(trimmed) {
+			trimmed = strings.TrimSuffix(trimmed, ".")
+		}
+		trimmed = strings.TrimSpace(trimmed)
+		if trimmed == value {
+			return value
+		}
+		value = trimmed
+	}
+}
+
+func endsWithAbbreviation(value string) bool {
+	words := strings.Fields(value)
+	if len(words) == 0 {
+		return false
+	}
+	last := strings.TrimSuffix(words[len(words)-1], ".")
+	return len([]rune(last)) <= 2 && len(words) > 1
+}
@@ -0,0 +1,81 @@
+package marc
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// XMLReader reads the records of a MARCXML document one at a time. The
+// document may be a collection or a single record.
+type XMLReader struct {
+	d *xml.Decoder
+}
+
+func NewXMLReader(r io.Reader) *XMLReader {
+	return &XMLReader{d: xml.NewDecoder(r)}
+}
+
+// Next returns the next record, or io.EOF after the last one
+func (r *XMLReader) Next() (*Record, error) {
+	for {
+		token, err := r.d.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "record" {
+			continue
+		}
+
+		var record Record
+		if err := r.d.DecodeElement(&record, &start); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrMalformedRecord, err)
+		}
+		return &record, nil
+	}
+}
+
+// XMLWriter writes records as a MARCXML collection. Close must be called to
+// end the collection.
+type XMLWriter struct {
+	w   io.Writer
+	enc *xml.Encoder
+}
+
+func NewXMLWriter(w io.Writer) (*XMLWriter, error) {
+	if _, err := io.WriteString(w, xml.Header+`<collection xmlns="`+Namespace+`">`+"\n"); err != nil {
+		return nil, err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("  ", "  ")
+	return &XMLWriter{w: w, enc: enc}, nil
+}
+
+func (w *XMLWriter) Write(record *Record) error {
+	if err := w.enc.Encode(record); err != nil {
+		return err
+	}
+	return w.enc.Flush()
+}
+
+// Close ends the collection. It does not close the underlying writer.
+func (w *XMLWriter) Close() error {
+	_, err := io.WriteString(w.w, "\n</collection>\n")
+	return err
+}
+
+// MarshalXML encodes a single record as a standalone MARCXML document
+func MarshalXML(record *Record) ([]byte, error) {
+	out, err := xml.MarshalIndent(struct {
+		XMLName xml.Name `xml:"record"`
+		Xmlns   string   `xml:"xmlns,attr"`
+		*Record
+	}{Xmlns: Namespace, Record: record}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
@@ -0,0 +1,86 @@
+package marc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestXMLRoundTrip(t *testing.T) {
+	first := testRecord()
+	second := testRecord()
+	second.ControlFields[0].Value = "ol456"
+
+	var buf bytes.Buffer
+	w, err := NewXMLWriter(&buf)
+	if err != nil {
+		t.Fatalf("NewXMLWriter: %v", err)
+	}
+	for _, r := range []*Record{first, second} {
+		if err := w.Write(r); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reader := NewXMLReader(&buf)
+	for _, want := range []*Record{first, second} {
+		got, err := reader.Next()
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		if got.Leader != want.Leader {
+			t.Errorf("leader = %q, want %q", got.Leader, want.Leader)
+		}
+		if !reflect.DeepEqual(got.ControlFields, want.ControlFields) {
+			t.Errorf("control fields = %+v, want %+v", got.ControlFields, want.ControlFields)
+		}
+		if !reflect.DeepEqual(got.DataFields, want.DataFields) {
+			t.Errorf("data fields = %+v, want %+v", got.DataFields, want.DataFields)
+		}
+	}
+	if _, err := reader.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("Next after the last record = %v, want io.EOF", err)
+	}
+}
+
+func TestXMLToISO2709(t *testing.T) {
+	want := testRecord()
+
+	data, err := MarshalXML(want)
+	if err != nil {
+		t.Fatalf("MarshalXML: %v", err)
+	}
+	if !strings.Contains(string(data), `xmlns="`+Namespace+`"`) {
+		t.Errorf("standalone record lacks the MARCXML namespace:\n%s", data)
+	}
+
+	fromXML, err := NewXMLReader(bytes.NewReader(data)).Next()
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	iso, err := fromXML.MarshalISO2709()
+	if err != nil {
+		t.Fatalf("MarshalISO2709: %v", err)
+	}
+	direct, err := want.MarshalISO2709()
+	if err != nil {
+		t.Fatalf("MarshalISO2709: %v", err)
+	}
+	if !bytes.Equal(iso, direct) {
+		t.Errorf("record read from MARCXML encodes differently:\n got %q\nwant %q", iso, direct)
+	}
+}
+
+func TestXMLReaderMalformed(t *testing.T) {
+	doc := `<collection xmlns="` + Namespace + `"><record><leader>x</leader><controlfield tag="001">a</record></collection>`
+
+	if _, err := NewXMLReader(strings.NewReader(doc)).Next(); !errors.Is(err, ErrMalformedRecord) {
+		t.Errorf("Next = %v, want ErrMalformedRecord", err)
+	}
+}
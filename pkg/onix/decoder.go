@@ -0,0 +1,205 @@
+package onix
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var ErrUnsupportedRelease = errors.New("unsupported onix release")
+
+// shortTags maps the short tags of the modelled composites to their reference
+// names. Short tags are lower case apart from the root, so they never clash
+// with reference names.
+var shortTags = map[string]string{
+	"ONIXmessage":       "ONIXMessage",
+	"header":            "Header",
+	"sender":            "Sender",
+	"x298":              "SenderName",
+	"x299":              "ContactName",
+	"j272":              "EmailAddress",
+	"x307":              "SentDateTime",
+	"product":           "Product",
+	"a001":              "RecordReference",
+	"a002":              "NotificationType",
+	"productidentifier": "ProductIdentifier",
+	"b221":              "ProductIDType",
+	"b244":              "IDValue",
+	"descriptivedetail": "DescriptiveDetail",
+	"x314":              "ProductComposition",
+	"b012":              "ProductForm",
+	"titledetail":       "TitleDetail",
+	"b202":              "TitleType",
+	"titleelement":      "TitleElement",
+	"x409":              "TitleElementLevel",
+	"b030":              "TitlePrefix",
+	"b031":              "TitleWithoutPrefix",
+	"b203":              "TitleText",
+	"b029":              "Subtitle",
+	"contributor":       "Contributor",
+	"b034":              "SequenceNumber",
+	"b035":              "ContributorRole",
+	"b036":              "PersonName",
+	"b037":              "PersonNameInverted",
+	"b039":              "NamesBeforeKey",
+	"b040":              "KeyNames",
+	"b047":              "CorporateName",
+	"language":          "Language",
+	"b253":              "LanguageRole",
+	"b252":              "LanguageCode",
+	"extent":            "Extent",
+	"b218":              "ExtentType",
+	"b219":              "ExtentValue",
+	"b220":              "ExtentUnit",
+	"collateraldetail":  "CollateralDetail",
+	"textcontent":       "TextContent",
+	"x426":              "TextType",
+	"x427":              "ContentAudience",
+	"d104":              "Text",
+	"publishingdetail":  "PublishingDetail",
+	"publisher":         "Publisher",
+	"b291":              "PublishingRole",
+	"b081":              "PublisherName",
+	"b394":              "PublishingStatus",
+	"publishingdate":    "PublishingDate",
+	"x448":              "PublishingDateRole",
+	"b306":              "Date",
+	"productsupply":     "ProductSupply",
+	"supplydetail":      "SupplyDetail",
+	"supplier":          "Supplier",
+	"j292":              "SupplierRole",
+	"j137":              "SupplierName",
+	"j396":              "ProductAvailability",
+	"stock":             "Stock",
+	"j350":              "OnHand",
+	"price":             "Price",
+	"x462":              "PriceType",
+	"j151":              "PriceAmount",
+	"j152":              "CurrencyCode",
+}
+
+// Decoder reads the products of an ONIX 3.0 message one at a time, so feeds
+// of any size can be processed in constant memory. Both reference and short
+// tag messages are accepted.
+type Decoder struct {
+	d       *xml.Decoder
+	header  *Header
+	release string
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	raw := xml.NewDecoder(r)
+	raw.Entity = xml.HTMLEntity
+	raw.CharsetReader = charsetReader
+	return &Decoder{d: xml.NewTokenDecoder(referenceNames{raw})}
+}
+
+// Header returns the message header once it has been read, which is before
+// the first product is returned
+func (d *Decoder) Header() *Header {
+	return d.header
+}
+
+// Next returns the next product, or io.EOF after the last one
+func (d *Decoder) Next() (*Product, error) {
+	for {
+		token, err := d.d.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "ONIXMessage":
+			for _, attr := range start.Attr {
+				if attr.Name.Local == "release" {
+					d.release = attr.Value
+				}
+			}
+			if d.release != "" && !strings.HasPrefix(d.release, "3.") {
+				return nil, fmt.Errorf("%w: %s", ErrUnsupportedRelease, d.release)
+			}
+		case "Header":
+			var header Header
+			if err := d.d.DecodeElement(&header, &start); err != nil {
+				return nil, err
+			}
+			d.header = &header
+		case "Product":
+			var product Product
+			if err := d.d.DecodeElement(&product, &start); err != nil {
+				return nil, err
+			}
+			return &product, nil
+		}
+	}
+}
+
+// referenceNames renames short tags to reference names and drops namespaces
+type referenceNames struct {
+	d *xml.Decoder
+}
+
+func (r referenceNames) Token() (xml.Token, error) {
+	token, err := r.d.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := token.(type) {
+	case xml.StartElement:
+		t.Name = reference(t.Name)
+		return t, nil
+	case xml.EndElement:
+		t.Name = reference(t.Name)
+		return t, nil
+	}
+	return token, nil
+}
+
+func reference(name xml.Name) xml.Name {
+	if full, ok := shortTags[name.Local]; ok {
+		return xml.Name{Local: full}
+	}
+	return xml.Name{Local: name.Local}
+}
+
+// charsetReader accepts the charsets ONIX feeds declare besides utf-8
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "utf-8", "utf8", "us-ascii", "ascii":
+		return input, nil
+	case "iso-8859-1", "latin1", "latin-1":
+		return latin1Reader{input}, nil
+	}
+	return nil, fmt.Errorf("unsupported charset %q", charset)
+}
+
+type latin1Reader struct {
+	r io.Reader
+}
+
+// Read decodes latin-1 into utf-8; every byte maps to the rune of the same
+// value, taking at most two utf-8 bytes
+func (l latin1Reader) Read(p []byte) (int, error) {
+	buf := make([]byte, len(p)/2)
+	if len(buf) == 0 {
+		return 0, nil
+	}
+	n, err := l.r.Read(buf)
+	out := p[:0]
+	for _, b := range buf[:n] {
+		if b < 0x80 {
+			out = append(out, b)
+		} else {
+			out = append(out, 0xc0|b>>6, 0x80|b&0x3f)
+		}
+	}
+	return len(out), err
+}
@@ -0,0 +1,50 @@
+package onix
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+// Encoder writes an ONIX 3.0 reference tag message one product at a time.
+// Close must be called to end the message.
+type Encoder struct {
+	w   io.Writer
+	enc *xml.Encoder
+}
+
+// NewEncoder writes the message start and header. An empty SentDateTime is
+// set to the current time.
+func NewEncoder(w io.Writer, header Header) (*Encoder, error) {
+	if header.SentDateTime == "" {
+		header.SentDateTime = time.Now().UTC().Format("20060102T1504Z")
+	}
+
+	if _, err := io.WriteString(w, xml.Header+`<ONIXMessage release="`+Release+`" xmlns="`+Namespace+`">`+"\n"); err != nil {
+		return nil, err
+	}
+
+	e := &Encoder{w: w, enc: xml.NewEncoder(w)}
+	e.enc.Indent("  ", "  ")
+	if err := e.encode(header); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *Encoder) Encode(product *Product) error {
+	return e.encode(product)
+}
+
+func (e *Encoder) encode(v any) error {
+	if err := e.enc.Encode(v); err != nil {
+		return err
+	}
+	return e.enc.Flush()
+}
+
+// Close ends the message. It does not close the underlying writer.
+func (e *Encoder) Close() error {
+	_, err := io.WriteString(e.w, "\n</ONIXMessage>\n")
+	return err
+}
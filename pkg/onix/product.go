@@ -0,0 +1,397 @@
+// Package onix reads and writes ONIX for Books 3.0 messages. Only the
+// composites the catalog uses are modelled; everything else in a product is
+// skipped when decoding.
+package onix
+
+import (
+	"encoding/xml"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	Namespace = "http://ns.editeur.org/onix/3.0/reference"
+	Release   = "3.0"
+)
+
+// ProductIDType codes (list 5)
+const (
+	IDTypeProprietary = "01"
+	IDTypeGTIN13      = "03"
+	IDTypeISBN13      = "15"
+)
+
+// NotificationType codes (list 1)
+const (
+	NotificationConfirmed = "03"
+	NotificationDelete    = "05"
+)
+
+// ContributorRole codes (list 17)
+const (
+	RoleAuthor = "A01"
+	RoleEditor = "B01"
+)
+
+// Other codes used when writing products
+const (
+	TitleTypeDistinctive       = "01" // list 15
+	TitleElementLevelProduct   = "01" // list 149
+	PublishingRolePublisher    = "01" // list 45
+	PublishingDatePublication  = "01" // list 163
+	LanguageRoleText           = "01" // list 22
+	ExtentTypeMainContentPages = "00" // list 23
+	ExtentUnitPages            = "03" // list 24
+	TextTypeDescription        = "03" // list 153
+	PriceTypeRRPExcludingTax   = "01" // list 58
+	PriceTypeRRPIncludingTax   = "02" // list 58
+	AvailabilityAvailable      = "20" // list 65
+	AvailabilityInStock        = "21" // list 65
+	AvailabilityOutOfStock     = "31" // list 65
+)
+
+// Date format codes (list 55)
+const (
+	DateFormatYYYYMMDD = "00"
+	DateFormatYYYYMM   = "01"
+	DateFormatYYYY     = "05"
+)
+
+type Header struct {
+	XMLName      xml.Name `xml:"Header"`
+	Sender       Sender   `xml:"Sender"`
+	SentDateTime string   `xml:"SentDateTime"`
+}
+
+type Sender struct {
+	SenderName   string `xml:"SenderName"`
+	ContactName  string `xml:"ContactName,omitempty"`
+	EmailAddress string `xml:"EmailAddress,omitempty"`
+}
+
+// Product is an ONIX 3.0 product record. Field order follows the schema so
+// the struct marshals to valid ONIX.
+type Product struct {
+	XMLName            xml.Name            `xml:"Product"`
+	RecordReference    string              `xml:"RecordReference"`
+	NotificationType   string              `xml:"NotificationType"`
+	ProductIdentifiers []ProductIdentifier `xml:"ProductIdentifier"`
+	DescriptiveDetail  DescriptiveDetail   `xml:"DescriptiveDetail"`
+	CollateralDetail   *CollateralDetail   `xml:"CollateralDetail,omitempty"`
+	PublishingDetail   PublishingDetail    `xml:"PublishingDetail"`
+	ProductSupply      []ProductSupply     `xml:"ProductSupply"`
+}
+
+type ProductIdentifier struct {
+	ProductIDType string `xml:"ProductIDType"`
+	IDValue       string `xml:"IDValue"`
+}
+
+type DescriptiveDetail struct {
+	ProductComposition string        `xml:"ProductComposition"`
+	ProductForm        string        `xml:"ProductForm"`
+	TitleDetails       []TitleDetail `xml:"TitleDetail"`
+	Contributors       []Contributor `xml:"Contributor"`
+	Languages          []Language    `xml:"Language"`
+	Extents            []Extent      `xml:"Extent"`
+}
+
+type TitleDetail struct {
+	TitleType     string         `xml:"TitleType"`
+	TitleElements []TitleElement `xml:"TitleElement"`
+}
+
+type TitleElement struct {
+	TitleElementLevel  string `xml:"TitleElementLevel"`
+	TitlePrefix        string `xml:"TitlePrefix,omitempty"`
+	TitleWithoutPrefix string `xml:"TitleWithoutPrefix,omitempty"`
+	TitleText          string `xml:"TitleText,omitempty"`
+	Subtitle           string `xml:"Subtitle,omitempty"`
+}
+
+type Contributor struct {
+	SequenceNumber     int      `xml:"SequenceNumber,omitempty"`
+	ContributorRoles   []string `xml:"ContributorRole"`
+	PersonName         string   `xml:"PersonName,omitempty"`
+	PersonNameInverted string   `xml:"PersonNameInverted,omitempty"`
+	NamesBeforeKey     string   `xml:"NamesBeforeKey,omitempty"`
+	KeyNames           string   `xml:"KeyNames,omitempty"`
+	CorporateName      string   `xml:"CorporateName,omitempty"`
+}
+
+type Language struct {
+	LanguageRole string `xml:"LanguageRole"`
+	LanguageCode string `xml:"LanguageCode"`
+}
+
+type Extent struct {
+	ExtentType  string `xml:"ExtentType"`
+	ExtentValue string `xml:"ExtentValue"`
+	ExtentUnit  string `xml:"ExtentUnit"`
+}
+
+type CollateralDetail struct {
+	TextContents []TextContent `xml:"TextContent"`
+}
+
+type TextContent struct {
+	TextType        string `xml:"TextType"`
+	ContentAudience string `xml:"ContentAudience"`
+	Text            string `xml:"Text"`
+}
+
+type PublishingDetail struct {
+	Publishers       []Publisher      `xml:"Publisher"`
+	PublishingStatus string           `xml:"PublishingStatus,omitempty"`
+	PublishingDates  []PublishingDate `xml:"PublishingDate"`
+}
+
+type Publisher struct {
+	PublishingRole string `xml:"PublishingRole"`
+	PublisherName  string `xml:"PublisherName"`
+}
+
+type PublishingDate struct {
+	PublishingDateRole string `xml:"PublishingDateRole"`
+	Date               Date   `xml:"Date"`
+}
+
+// Date is an ONIX date; Format is a list 55 code and defaults to YYYYMMDD
+type Date struct {
+	Format string `xml:"dateformat,attr,omitempty"`
+	Value  string `xml:",chardata"`
+}
+
+type ProductSupply struct {
+	SupplyDetails []SupplyDetail `xml:"SupplyDetail"`
+}
+
+type SupplyDetail struct {
+	Supplier            Supplier `xml:"Supplier"`
+	ProductAvailability string   `xml:"ProductAvailability"`
+	Stock               []Stock  `xml:"Stock"`
+	Prices              []Price  `xml:"Price"`
+}
+
+type Supplier struct {
+	SupplierRole string `xml:"SupplierRole"`
+	SupplierName string `xml:"SupplierName,omitempty"`
+}
+
+type Stock struct {
+	OnHand string `xml:"OnHand"`
+}
+
+type Price struct {
+	PriceType    string `xml:"PriceType"`
+	PriceAmount  string `xml:"PriceAmount"`
+	CurrencyCode string `xml:"CurrencyCode,omitempty"`
+}
+
+// ISBN13 returns the ISBN-13 identifier, falling back to a Bookland GTIN-13
+func (p *Product) ISBN13() string {
+	gtin := ""
+	for _, id := range p.ProductIdentifiers {
+		value := strings.TrimSpace(id.IDValue)
+		switch id.ProductIDType {
+		case IDTypeISBN13:
+			return value
+		case IDTypeGTIN13:
+			if strings.HasPrefix(value, "978") || strings.HasPrefix(value, "979") {
+				gtin = value
+			}
+		}
+	}
+	return gtin
+}
+
+// Deleted reports whether the record is a delete notification
+func (p *Product) Deleted() bool {
+	return p.NotificationType == NotificationDelete
+}
+
+// Title returns the product level distinctive title and subtitle
+func (p *Product) Title() (string, string) {
+	for _, detail := range p.DescriptiveDetail.TitleDetails {
+		if detail.TitleType != TitleTypeDistinctive {
+			continue
+		}
+		for _, element := range detail.TitleElements {
+			if element.TitleElementLevel != TitleElementLevelProduct {
+				continue
+			}
+
+			title := strings.TrimSpace(element.TitleText)
+			if title == "" {
+				title = strings.TrimSpace(element.TitlePrefix + " " + element.TitleWithoutPrefix)
+			}
+			return title, strings.TrimSpace(element.Subtitle)
+		}
+	}
+	return "", ""
+}
+
+// Contributors returns the names of the contributors with any of roles, in
+// sequence order
+func (p *Product) Contributors(roles ...string) []string {
+	contributors := make([]Contributor, 0, len(p.DescriptiveDetail.Contributors))
+	for _, c := range p.DescriptiveDetail.Contributors {
+		for _, role := range c.ContributorRoles {
+			if contains(roles, role) {
+				contributors = append(contributors, c)
+				break
+			}
+		}
+	}
+	sort.SliceStable(contributors, func(i, j int) bool {
+		return contributors[i].SequenceNumber < contributors[j].SequenceNumber
+	})
+
+	names := make([]string, 0, len(contributors))
+	for _, c := range contributors {
+		if name := c.Name(); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Name returns the name of the contributor in natural order
+func (c Contributor) Name() string {
+	switch {
+	case c.PersonName != "":
+		return strings.TrimSpace(c.PersonName)
+	case c.KeyNames != "":
+		return strings.TrimSpace(c.NamesBeforeKey + " " + c.KeyNames)
+	case c.PersonNameInverted != "":
+		if last, first, ok := strings.Cut(c.PersonNameInverted, ","); ok {
+			return strings.TrimSpace(first) + " " + strings.TrimSpace(last)
+		}
+		return strings.TrimSpace(c.PersonNameInverted)
+	}
+	return strings.TrimSpace(c.CorporateName)
+}
+
+// PublisherName returns the name of the publisher, or of the first publishing
+// entity when none has the publisher role
+func (p *Product) PublisherName() string {
+	for _, publisher := range p.PublishingDetail.Publishers {
+		if publisher.PublishingRole == PublishingRolePublisher {
+			return strings.TrimSpace(publisher.PublisherName)
+		}
+	}
+	if len(p.PublishingDetail.Publishers) > 0 {
+		return strings.TrimSpace(p.PublishingDetail.Publishers[0].PublisherName)
+	}
+	return ""
+}
+
+// PublicationDate returns the publication date as YYYY-MM-DD, YYYY-MM or
+// YYYY
+func (p *Product) PublicationDate() string {
+	for _, date := range p.PublishingDetail.PublishingDates {
+		if date.PublishingDateRole == PublishingDatePublication {
+			return date.Date.ISO()
+		}
+	}
+	return ""
+}
+
+// Language returns the language of the text as an ISO 639-2/B code
+func (p *Product) Language() string {
+	for _, language := range p.DescriptiveDetail.Languages {
+		if language.LanguageRole == LanguageRoleText {
+			return language.LanguageCode
+		}
+	}
+	return ""
+}
+
+// PageCount returns the main content page count, or 0
+func (p *Product) PageCount() int {
+	for _, extent := range p.DescriptiveDetail.Extents {
+		if extent.ExtentType == ExtentTypeMainContentPages && extent.ExtentUnit == ExtentUnitPages {
+			n, _ := strconv.Atoi(strings.TrimSpace(extent.ExtentValue))
+			return n
+		}
+	}
+	return 0
+}
+
+// Description returns the main description text
+func (p *Product) Description() string {
+	if p.CollateralDetail == nil {
+		return ""
+	}
+	for _, text := range p.CollateralDetail.TextContents {
+		if text.TextType == TextTypeDescription {
+			return strings.TrimSpace(text.Text)
+		}
+	}
+	return ""
+}
+
+// RetailPrice returns the first recommended retail price in currency, or in
+// any currency when currency is empty. Prices including tax are preferred.
+func (p *Product) RetailPrice(currency string) (float64, bool) {
+	for _, priceType := range []string{PriceTypeRRPIncludingTax, PriceTypeRRPExcludingTax} {
+		for _, supply := range p.ProductSupply {
+			for _, detail := range supply.SupplyDetails {
+				for _, price := range detail.Prices {
+					if price.PriceType != priceType {
+						continue
+					}
+					if currency != "" && !strings.EqualFold(price.CurrencyCode, currency) {
+						continue
+					}
+					amount, err := strconv.ParseFloat(strings.TrimSpace(price.PriceAmount), 64)
+					if err != nil {
+						continue
+					}
+					return amount, true
+				}
+			}
+		}
+	}
+	return 0, false
+}
+
+// ISO returns the date as YYYY-MM-DD, YYYY-MM or YYYY
+func (d Date) ISO() string {
+	value := strings.TrimSpace(d.Value)
+	switch {
+	case (d.Format == "" || d.Format == DateFormatYYYYMMDD) && len(value) == 8:
+		return value[:4] + "-" + value[4:6] + "-" + value[6:]
+	case (d.Format == "" || d.Format == DateFormatYYYYMM) && len(value) == 6:
+		return value[:4] + "-" + value[4:]
+	}
+	return value
+}
+
+// DateFromISO parses a YYYY-MM-DD, YYYY-MM or YYYY date. ok is false for
+// anything else.
+func DateFromISO(value string) (Date, bool) {
+	digits := strings.ReplaceAll(value, "-", "")
+	if _, err := strconv.Atoi(digits); err != nil {
+		return Date{}, false
+	}
+	switch {
+	case len(value) == 10 && len(digits) == 8:
+		return Date{Value: digits}, true
+	case len(value) == 7 && len(digits) == 6:
+		return Date{Format: DateFormatYYYYMM, Value: digits}, true
+	case len(value) == 4:
+		return Date{Format: DateFormatYYYY, Value: digits}, true
+	}
+	return Date{}, false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
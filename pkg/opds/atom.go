@@ -0,0 +1,173 @@
+package opds
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Namespaces of OPDS 1.2 feeds
+const (
+	AtomNamespace       = "http://www.w3.org/2005/Atom"
+	OPDSNamespace       = "http://opds-spec.org/2010/catalog"
+	DCNamespace         = "http://purl.org/dc/terms/"
+	OpenSearchNamespace = "http://a9.com/-/spec/opensearch/1.1/"
+	ThreadingNamespace  = "http://purl.org/syndication/thread/1.0"
+)
+
+type atomFeed struct {
+	XMLName      xml.Name    `xml:"feed"`
+	Xmlns        string      `xml:"xmlns,attr"`
+	XmlnsOPDS    string      `xml:"xmlns:opds,attr"`
+	XmlnsDC      string      `xml:"xmlns:dc,attr"`
+	XmlnsOS      string      `xml:"xmlns:opensearch,attr"`
+	XmlnsThr     string      `xml:"xmlns:thr,attr"`
+	ID           string      `xml:"id"`
+	Title        string      `xml:"title"`
+	Updated      string      `xml:"updated"`
+	TotalResults *int64      `xml:"opensearch:totalResults"`
+	ItemsPerPage *int        `xml:"opensearch:itemsPerPage"`
+	StartIndex   *int        `xml:"opensearch:startIndex"`
+	Links        []atomLink  `xml:"link"`
+	Entries      []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel         string `xml:"rel,attr,omitempty"`
+	Href        string `xml:"href,attr"`
+	Type        string `xml:"type,attr,omitempty"`
+	Title       string `xml:"title,attr,omitempty"`
+	FacetGroup  string `xml:"opds:facetGroup,attr,omitempty"`
+	ActiveFacet string `xml:"opds:activeFacet,attr,omitempty"`
+	Count       string `xml:"thr:count,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr,omitempty"`
+	Text string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	ID         string       `xml:"id"`
+	Title      string       `xml:"title"`
+	Updated    string       `xml:"updated"`
+	Authors    []atomPerson `xml:"author"`
+	Identifier string       `xml:"dc:identifier,omitempty"`
+	Publisher  string       `xml:"dc:publisher,omitempty"`
+	Issued     string       `xml:"dc:issued,omitempty"`
+	Content    *atomText    `xml:"content"`
+	Links      []atomLink   `xml:"link"`
+}
+
+// WriteAtom writes the feed as an OPDS 1.2 Atom document
+func (f *Feed) WriteAtom(w io.Writer) error {
+	linkType := NavigationType
+	if f.Kind == Acquisition {
+		linkType = AcquisitionType
+	}
+
+	feed := atomFeed{
+		Xmlns:     AtomNamespace,
+		XmlnsOPDS: OPDSNamespace,
+		XmlnsDC:   DCNamespace,
+		XmlnsOS:   OpenSearchNamespace,
+		XmlnsThr:  ThreadingNamespace,
+		ID:        f.ID,
+		Title:     f.Title,
+		Updated:   atomTime(f.Updated),
+	}
+
+	for _, link := range f.Links {
+		feed.Links = append(feed.Links, newAtomLink(link))
+	}
+	if f.Paging != nil {
+		for _, link := range f.Paging.links(linkType) {
+			feed.Links = append(feed.Links, newAtomLink(link))
+		}
+		startIndex := (f.Paging.Page-1)*f.Paging.ItemsPerPage + 1
+		feed.TotalResults = &f.Paging.Total
+		feed.ItemsPerPage = &f.Paging.ItemsPerPage
+		feed.StartIndex = &startIndex
+	}
+	for _, group := range f.Facets {
+		for _, facet := range group.Facets {
+			link := atomLink{
+				Rel:        RelFacet,
+				Href:       facet.Href,
+				Type:       AcquisitionType,
+				Title:      facet.Title,
+				FacetGroup: group.Title,
+			}
+			if facet.Active {
+				link.ActiveFacet = "true"
+			}
+			if facet.Count > 0 {
+				link.Count = strconv.FormatInt(facet.Count, 10)
+			}
+			feed.Links = append(feed.Links, link)
+		}
+	}
+
+	for _, nav := range f.Navigation {
+		entry := atomEntry{
+			ID:      nav.ID,
+			Title:   nav.Title,
+			Updated: atomTime(nav.Updated),
+			Links:   []atomLink{{Rel: RelSubsection, Href: nav.Href, Type: nav.Type, Title: nav.Title}},
+		}
+		if nav.Count > 0 {
+			entry.Links[0].Count = strconv.FormatInt(nav.Count, 10)
+		}
+		if nav.Summary != "" {
+			entry.Content = &atomText{Type: "text", Text: nav.Summary}
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	for _, pub := range f.Publications {
+		entry := atomEntry{
+			ID:         pub.ID,
+			Title:      pub.Title,
+			Updated:    atomTime(pub.Updated),
+			Identifier: pub.Identifier,
+			Publisher:  pub.Publisher,
+			Issued:     pub.Issued,
+		}
+		for _, author := range pub.Authors {
+			entry.Authors = append(entry.Authors, atomPerson{Name: author})
+		}
+		for _, link := range pub.Links {
+			entry.Links = append(entry.Links, newAtomLink(link))
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(feed); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func newAtomLink(link Link) atomLink {
+	return atomLink{Rel: link.Rel, Href: link.Href, Type: link.Type, Title: link.Title}
+}
+
+// atomTime formats t as an RFC 3339 date, using the current time for the
+// zero time since Atom requires one
+func atomTime(t time.Time) string {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,133 @@
+// Package opds writes OPDS catalog feeds, as OPDS 1.2 Atom documents or
+// OPDS 2.0 json, from one description of the feed.
+package opds
+
+import (
+	"time"
+)
+
+// Media types of OPDS documents
+const (
+	NavigationType      = "application/atom+xml;profile=opds-catalog;kind=navigation"
+	AcquisitionType     = "application/atom+xml;profile=opds-catalog;kind=acquisition"
+	JSONType            = "application/opds+json"
+	PublicationJSONType = "application/opds-publication+json"
+	OpenSearchType      = "application/opensearchdescription+xml"
+)
+
+// Link relations used by the feeds
+const (
+	RelSelf       = "self"
+	RelStart      = "start"
+	RelUp         = "up"
+	RelSearch     = "search"
+	RelFirst      = "first"
+	RelPrevious   = "previous"
+	RelNext       = "next"
+	RelLast       = "last"
+	RelAlternate  = "alternate"
+	RelSubsection = "subsection"
+	RelFacet      = "http://opds-spec.org/facet"
+)
+
+// Kind tells navigation feeds, which link to other feeds, from acquisition
+// feeds, which list publications
+type Kind int
+
+const (
+	Navigation Kind = iota
+	Acquisition
+)
+
+type Link struct {
+	Rel   string
+	Href  string
+	Type  string
+	Title string
+	// Templated marks an OPDS 2.0 href that is a URI template
+	Templated bool
+}
+
+// Feed is a catalog page. Links hold the self, start, up and search links;
+// paging links are derived from Paging.
+type Feed struct {
+	ID      string
+	Title   string
+	Updated time.Time
+	Kind    Kind
+	Links   []Link
+	Paging  *Paging
+	// Navigation entries are listed by navigation feeds
+	Navigation []NavigationEntry
+	// Publications are listed by acquisition feeds
+	Publications []Publication
+	Facets       []FacetGroup
+}
+
+// Paging places a feed among the pages of a listing. Href returns the url of
+// a page, counted from 1.
+type Paging struct {
+	Page         int
+	ItemsPerPage int
+	Total        int64
+	Href         func(page int) string
+}
+
+// LastPage returns the number of the last page, which is 1 for an empty
+// listing
+func (p *Paging) LastPage() int {
+	if p.ItemsPerPage < 1 || p.Total < 1 {
+		return 1
+	}
+	return int((p.Total + int64(p.ItemsPerPage) - 1) / int64(p.ItemsPerPage))
+}
+
+// links returns the first, previous, next and last links of the page
+func (p *Paging) links(linkType string) []Link {
+	last := p.LastPage()
+	links := []Link{{Rel: RelFirst, Href: p.Href(1), Type: linkType}}
+	if p.Page > 1 {
+		links = append(links, Link{Rel: RelPrevious, Href: p.Href(min(p.Page-1, last)), Type: linkType})
+	}
+	if p.Page < last {
+		links = append(links, Link{Rel: RelNext, Href: p.Href(p.Page + 1), Type: linkType})
+	}
+	return append(links, Link{Rel: RelLast, Href: p.Href(last), Type: linkType})
+}
+
+// NavigationEntry links a navigation feed to another feed. Count, when set,
+// is the number of publications behind the link.
+type NavigationEntry struct {
+	ID      string
+	Title   string
+	Summary string
+	Href    string
+	Type    string
+	Count   int64
+	Updated time.Time
+}
+
+type Publication struct {
+	ID         string
+	Title      string
+	Authors    []string
+	Publisher  string
+	Issued     string
+	Identifier string
+	Updated    time.Time
+	Links      []Link
+}
+
+// FacetGroup is a set of alternative views of an acquisition feed, such as
+// the books of one author
+type FacetGroup struct {
+	Title  string
+	Facets []Facet
+}
+
+type Facet struct {
+	Title  string
+	Href   string
+	Count  int64
+	Active bool
+}
@@ -0,0 +1,149 @@
+package opds
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+type jsonLink struct {
+	Rel        string          `json:"rel,omitempty"`
+	Href       string          `json:"href"`
+	Type       string          `json:"type,omitempty"`
+	Title      string          `json:"title,omitempty"`
+	Templated  bool            `json:"templated,omitempty"`
+	Properties *jsonProperties `json:"properties,omitempty"`
+}
+
+type jsonProperties struct {
+	NumberOfItems int64 `json:"numberOfItems,omitempty"`
+}
+
+type jsonFeedMetadata struct {
+	Title         string `json:"title"`
+	Modified      string `json:"modified,omitempty"`
+	NumberOfItems *int64 `json:"numberOfItems,omitempty"`
+	ItemsPerPage  *int   `json:"itemsPerPage,omitempty"`
+	CurrentPage   *int   `json:"currentPage,omitempty"`
+}
+
+type jsonContributor struct {
+	Name string `json:"name"`
+}
+
+type jsonPublicationMetadata struct {
+	Type       string            `json:"@type"`
+	Identifier string            `json:"identifier,omitempty"`
+	Title      string            `json:"title"`
+	Author     []jsonContributor `json:"author,omitempty"`
+	Publisher  []jsonContributor `json:"publisher,omitempty"`
+	Published  string            `json:"published,omitempty"`
+	Modified   string            `json:"modified,omitempty"`
+}
+
+type jsonPublication struct {
+	Metadata jsonPublicationMetadata `json:"metadata"`
+	Links    []jsonLink              `json:"links"`
+}
+
+type jsonFacet struct {
+	Metadata jsonFeedMetadata `json:"metadata"`
+	Links    []jsonLink       `json:"links"`
+}
+
+type jsonFeed struct {
+	Metadata     jsonFeedMetadata  `json:"metadata"`
+	Links        []jsonLink        `json:"links"`
+	Navigation   []jsonLink        `json:"navigation,omitempty"`
+	Publications []jsonPublication `json:"publications,omitempty"`
+	Facets       []jsonFacet       `json:"facets,omitempty"`
+}
+
+// WriteJSON writes the feed as an OPDS 2.0 json document
+func (f *Feed) WriteJSON(w io.Writer) error {
+	feed := jsonFeed{
+		Metadata: jsonFeedMetadata{
+			Title:    f.Title,
+			Modified: jsonTime(f.Updated),
+		},
+		Links: []jsonLink{},
+	}
+
+	for _, link := range f.Links {
+		feed.Links = append(feed.Links, newJSONLink(link))
+	}
+	if f.Paging != nil {
+		for _, link := range f.Paging.links(JSONType) {
+			feed.Links = append(feed.Links, newJSONLink(link))
+		}
+		feed.Metadata.NumberOfItems = &f.Paging.Total
+		feed.Metadata.ItemsPerPage = &f.Paging.ItemsPerPage
+		feed.Metadata.CurrentPage = &f.Paging.Page
+	}
+
+	for _, nav := range f.Navigation {
+		link := jsonLink{Rel: RelSubsection, Href: nav.Href, Type: nav.Type, Title: nav.Title}
+		if nav.Count > 0 {
+			link.Properties = &jsonProperties{NumberOfItems: nav.Count}
+		}
+		feed.Navigation = append(feed.Navigation, link)
+	}
+
+	for _, pub := range f.Publications {
+		publication := jsonPublication{
+			Metadata: jsonPublicationMetadata{
+				Type:       "http://schema.org/Book",
+				Identifier: pub.Identifier,
+				Title:      pub.Title,
+				Published:  pub.Issued,
+				Modified:   jsonTime(pub.Updated),
+			},
+			Links: []jsonLink{},
+		}
+		for _, author := range pub.Authors {
+			publication.Metadata.Author = append(publication.Metadata.Author, jsonContributor{Name: author})
+		}
+		if pub.Publisher != "" {
+			publication.Metadata.Publisher = []jsonContributor{{Name: pub.Publisher}}
+		}
+		for _, link := range pub.Links {
+			publication.Links = append(publication.Links, newJSONLink(link))
+		}
+		feed.Publications = append(feed.Publications, publication)
+	}
+
+	for _, group := range f.Facets {
+		facet := jsonFacet{Metadata: jsonFeedMetadata{Title: group.Title}}
+		for _, option := range group.Facets {
+			link := jsonLink{Href: option.Href, Type: JSONType, Title: option.Title}
+			if option.Active {
+				link.Rel = RelSelf
+			}
+			if option.Count > 0 {
+				link.Properties = &jsonProperties{NumberOfItems: option.Count}
+			}
+			facet.Links = append(facet.Links, link)
+		}
+		feed.Facets = append(feed.Facets, facet)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(feed)
+}
+
+func newJSONLink(link Link) jsonLink {
+	linkType := link.Type
+	switch linkType {
+	case NavigationType, AcquisitionType:
+		linkType = JSONType
+	}
+	return jsonLink{Rel: link.Rel, Href: link.Href, Type: linkType, Title: link.Title, Templated: link.Templated}
+}
+
+func jsonTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
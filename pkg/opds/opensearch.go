@@ -0,0 +1,49 @@
+package opds
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+// OpenSearchDescription describes how to search a catalog. Template is the
+// url of the results with {searchTerms} in place of the query.
+type OpenSearchDescription struct {
+	ShortName   string
+	Description string
+	Template    string
+	Type        string
+}
+
+type openSearchURL struct {
+	Type     string `xml:"type,attr"`
+	Template string `xml:"template,attr"`
+}
+
+type openSearchDocument struct {
+	XMLName       xml.Name      `xml:"OpenSearchDescription"`
+	Xmlns         string        `xml:"xmlns,attr"`
+	ShortName     string        `xml:"ShortName"`
+	Description   string        `xml:"Description"`
+	InputEncoding string        `xml:"InputEncoding"`
+	URL           openSearchURL `xml:"Url"`
+}
+
+// Write writes the OpenSearch 1.1 description document
+func (d *OpenSearchDescription) Write(w io.Writer) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(openSearchDocument{
+		Xmlns:         OpenSearchNamespace,
+		ShortName:     d.ShortName,
+		Description:   d.Description,
+		InputEncoding: "UTF-8",
+		URL:           openSearchURL{Type: d.Type, Template: d.Template},
+	}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
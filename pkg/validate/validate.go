@@ -0,0 +1,322 @@
+// Package validate checks structs against declarative rules in their
+// validate tags, e.g.
+//
+//	Title string  `json:"title" validate:"required,max=500"`
+//	Price float64 `json:"price" validate:"money"`
+//
+// Rules are separated by commas and apply to the field, or to what it points
+// to; a nil pointer or empty value passes every rule but required. Fields are
+// reported by their json, query or param name. An unknown rule or malformed
+// argument makes Struct fail with a plain error for every value of the type.
+package validate
+
+import (
+	"errors"
+	"fmt"
+	domainErr "github.com/gracchi-stdio/barf/pkg/errors"
+	"github.com/gracchi-stdio/barf/pkg/isbn"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// check checks a non-empty value and returns the error code and message of
+// a failure
+type check func(v reflect.Value) (code, message string, ok bool)
+
+// rule returns the check for a rule argument, or an error when the argument
+// doesn't suit the rule
+type rule func(arg string) (check, error)
+
+var rules = map[string]rule{
+	"isbn":  plain(checkISBN),
+	"date":  plain(checkDate),
+	"money": plain(checkMoney),
+	"min":   minRule,
+	"max":   maxRule,
+	"oneof": oneOfRule,
+}
+
+// fieldRules are the parsed validate tag and naming of a struct field
+type fieldRules struct {
+	index    int
+	name     string
+	embedded bool
+	required bool
+	checks   []check
+}
+
+// typeRules caches the field rules of each struct type, or the error in
+// its tags
+var typeRules sync.Map
+
+type parsedType struct {
+	fields []fieldRules
+	err    error
+}
+
+// Struct validates the fields of the struct s points to. It returns a
+// validation error listing every failing field, or nil. Tags are parsed
+// when a type is first validated; an unknown rule or a malformed argument
+// is returned as a plain error.
+func Struct(s any) error {
+	v := reflect.ValueOf(s)
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("validate: %T is not a struct", s)
+	}
+
+	fields, err := checkStruct(v, "")
+	if err != nil {
+		return err
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return domainErr.Validation("request is invalid", fields...)
+}
+
+func checkStruct(v reflect.Value, prefix string) ([]domainErr.FieldError, error) {
+	parsed, err := rulesFor(v.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []domainErr.FieldError
+	for _, field := range parsed {
+		name := prefix + field.name
+		value := indirect(v.Field(field.index))
+
+		if fieldErr, ok := field.check(value, name); !ok {
+			fields = append(fields, fieldErr)
+			continue
+		}
+
+		// nested structs are checked with their fields named after the
+		// parent, embedded ones as if their fields were the parent's
+		if value.IsValid() && value.Kind() == reflect.Struct && value.Type() != reflect.TypeOf(time.Time{}) {
+			nested := name + "."
+			if field.embedded {
+				nested = prefix
+			}
+			inner, err := checkStruct(value, nested)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, inner...)
+		}
+	}
+	return fields, nil
+}
+
+func (f fieldRules) check(value reflect.Value, name string) (domainErr.FieldError, bool) {
+	empty := !value.IsValid() || value.IsZero() || (value.Kind() == reflect.String && strings.TrimSpace(value.String()) == "")
+	if empty {
+		if f.required {
+			return domainErr.FieldError{Field: name, Code: "required", Message: "is required"}, false
+		}
+		return domainErr.FieldError{}, true
+	}
+
+	for _, c := range f.checks {
+		if code, message, ok := c(value); !ok {
+			return domainErr.FieldError{Field: name, Code: code, Message: message}, false
+		}
+	}
+	return domainErr.FieldError{}, true
+}
+
+// rulesFor returns the field rules of struct type t, parsing its tags the
+// first time it is seen
+func rulesFor(t reflect.Type) ([]fieldRules, error) {
+	if cached, ok := typeRules.Load(t); ok {
+		parsed := cached.(parsedType)
+		return parsed.fields, parsed.err
+	}
+
+	fields, err := parseType(t)
+	typeRules.Store(t, parsedType{fields: fields, err: err})
+	return fields, err
+}
+
+func parseType(t reflect.Type) ([]fieldRules, error) {
+	var fields []fieldRules
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get("validate")
+		if tag == "-" {
+			continue
+		}
+
+		parsed := fieldRules{index: i, name: fieldName(field), embedded: field.Anonymous}
+		if tag != "" {
+			for _, spec := range strings.Split(tag, ",") {
+				ruleName, arg, _ := strings.Cut(strings.TrimSpace(spec), "=")
+				if ruleName == "required" {
+					parsed.required = true
+					continue
+				}
+
+				newCheck, known := rules[ruleName]
+				if !known {
+					return nil, fmt.Errorf("validate: unknown rule %q on %s.%s", ruleName, t, field.Name)
+				}
+				c, err := newCheck(arg)
+				if err != nil {
+					return nil, fmt.Errorf("validate: rule %q on %s.%s: %w", spec, t, field.Name, err)
+				}
+				parsed.checks = append(parsed.checks, c)
+			}
+		}
+		fields = append(fields, parsed)
+	}
+	return fields, nil
+}
+
+// fieldName returns the name a client knows the field by
+func fieldName(field reflect.StructField) string {
+	for _, key := range []string{"json", "query", "param", "form"} {
+		if name, _, _ := strings.Cut(field.Tag.Get(key), ","); name != "" && name != "-" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// plain turns a check into a rule that takes no argument
+func plain(c check) rule {
+	return func(string) (check, error) { return c, nil }
+}
+
+func checkISBN(v reflect.Value) (string, string, bool) {
+	if v.Kind() != reflect.String || !isbn.Valid(v.String()) {
+		return "invalid_isbn", "is not a valid ISBN-10 or ISBN-13", false
+	}
+	return "", "", true
+}
+
+// dateLayouts are the ISO 8601 forms accepted as dates, complete or partial
+var dateLayouts = []string{"2006", "2006-01", "2006-01-02", time.RFC3339}
+
+func checkDate(v reflect.Value) (string, string, bool) {
+	if v.Kind() == reflect.String {
+		for _, layout := range dateLayouts {
+			if _, err := time.Parse(layout, strings.TrimSpace(v.String())); err == nil {
+				return "", "", true
+			}
+		}
+	}
+	return "invalid_date", "must be an ISO 8601 date: YYYY, YYYY-MM or YYYY-MM-DD", false
+}
+
+func checkMoney(v reflect.Value) (string, string, bool) {
+	amount, ok := number(v)
+	if !ok || math.IsNaN(amount) || math.IsInf(amount, 0) || amount < 0 {
+		return "invalid_amount", "must be a non-negative amount", false
+	}
+	return "", "", true
+}
+
+func minRule(arg string) (check, error) {
+	limit, err := parseLimit(arg)
+	if err != nil {
+		return nil, err
+	}
+	return func(v reflect.Value) (string, string, bool) {
+		if size, ok := length(v); ok {
+			if float64(size) < limit {
+				return "too_short", "must be at least " + arg + " characters", false
+			}
+			return "", "", true
+		}
+		if n, ok := number(v); !ok || n < limit {
+			return "too_small", "must be at least " + arg, false
+		}
+		return "", "", true
+	}, nil
+}
+
+func maxRule(arg string) (check, error) {
+	limit, err := parseLimit(arg)
+	if err != nil {
+		return nil, err
+	}
+	return func(v reflect.Value) (string, string, bool) {
+		if size, ok := length(v); ok {
+			if float64(size) > limit {
+				return "too_long", "must be at most " + arg + " characters", false
+			}
+			return "", "", true
+		}
+		if n, ok := number(v); !ok || n > limit {
+			return "too_large", "must be at most " + arg, false
+		}
+		return "", "", true
+	}, nil
+}
+
+func oneOfRule(arg string) (check, error) {
+	options := strings.Fields(arg)
+	if len(options) == 0 {
+		return nil, errors.New("no options given")
+	}
+	return func(v reflect.Value) (string, string, bool) {
+		value := fmt.Sprint(v.Interface())
+		for _, option := range options {
+			if value == option {
+				return "", "", true
+			}
+		}
+		return "not_allowed", "must be one of " + strings.Join(options, ", "), false
+	}, nil
+}
+
+func number(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+// length returns the length in characters of a string
+func length(v reflect.Value) (int, bool) {
+	if v.Kind() != reflect.String {
+		return 0, false
+	}
+	return len([]rune(v.String())), true
+}
+
+func parseLimit(arg string) (float64, error) {
+	limit, err := strconv.ParseFloat(arg, 64)
+	if err != nil || math.IsNaN(limit) {
+		return 0, fmt.Errorf("argument %q is not a number", arg)
+	}
+	return limit, nil
+}
@@ -0,0 +1,182 @@
+package validate
+
+import (
+	"errors"
+	domainErr "github.com/gracchi-stdio/barf/pkg/errors"
+	"slices"
+	"testing"
+)
+
+type ruleRequest struct {
+	Title    string   `json:"title" validate:"required,min=2,max=5"`
+	Quantity int      `json:"quantity" validate:"min=1,max=10"`
+	Price    *float64 `json:"price" validate:"money"`
+	Ratio    float64  `json:"ratio" validate:"max=0.5"`
+	Format   string   `json:"format" validate:"oneof=csv json"`
+	Limit    int      `query:"limit" validate:"oneof=10 50"`
+	ISBN     string   `json:"isbn" validate:"isbn"`
+	Date     string   `json:"date" validate:"date"`
+}
+
+// validRuleRequest returns a request passing every rule
+func validRuleRequest() ruleRequest {
+	return ruleRequest{Title: "Dune", Quantity: 3}
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func TestStructRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(r *ruleRequest)
+		field  string
+		code   string
+	}{
+		{"valid", func(r *ruleRequest) {}, "", ""},
+		{"required missing", func(r *ruleRequest) { r.Title = "" }, "title", "required"},
+		{"required blank", func(r *ruleRequest) { r.Title = "   " }, "title", "required"},
+		{"string too short", func(r *ruleRequest) { r.Title = "D" }, "title", "too_short"},
+		{"string too long", func(r *ruleRequest) { r.Title = "Dune Messiah" }, "title", "too_long"},
+		{"string length in characters", func(r *ruleRequest) { r.Title = "Übër" }, "", ""},
+		{"number too small", func(r *ruleRequest) { r.Quantity = -1 }, "quantity", "too_small"},
+		{"number too large", func(r *ruleRequest) { r.Quantity = 11 }, "quantity", "too_large"},
+		{"zero number is empty", func(r *ruleRequest) { r.Quantity = 0 }, "", ""},
+		{"fractional limit", func(r *ruleRequest) { r.Ratio = 0.75 }, "ratio", "too_large"},
+		{"money", func(r *ruleRequest) { r.Price = ptr(12.5) }, "", ""},
+		{"negative money", func(r *ruleRequest) { r.Price = ptr(-1.0) }, "price", "invalid_amount"},
+		{"nil pointer passes", func(r *ruleRequest) { r.Price = nil }, "", ""},
+		{"oneof string", func(r *ruleRequest) { r.Format = "json" }, "", ""},
+		{"oneof string not allowed", func(r *ruleRequest) { r.Format = "xml" }, "format", "not_allowed"},
+		{"oneof number", func(r *ruleRequest) { r.Limit = 50 }, "", ""},
+		{"oneof number not allowed", func(r *ruleRequest) { r.Limit = 20 }, "limit", "not_allowed"},
+		{"isbn", func(r *ruleRequest) { r.ISBN = "978-0-306-40615-7" }, "", ""},
+		{"invalid isbn", func(r *ruleRequest) { r.ISBN = "9780306406158" }, "isbn", "invalid_isbn"},
+		{"partial date", func(r *ruleRequest) { r.Date = "1965-08" }, "", ""},
+		{"invalid date", func(r *ruleRequest) { r.Date = "August 1965" }, "date", "invalid_date"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := validRuleRequest()
+			tt.modify(&req)
+
+			err := Struct(&req)
+			if tt.field == "" {
+				if err != nil {
+					t.Fatalf("Struct() = %v, want nil", err)
+				}
+				return
+			}
+
+			fields := fieldErrors(t, err)
+			if len(fields) != 1 || fields[0].Field != tt.field || fields[0].Code != tt.code {
+				t.Errorf("fields = %+v, want %s %s", fields, tt.field, tt.code)
+			}
+		})
+	}
+}
+
+type author struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type Paging struct {
+	Page int `query:"page" validate:"min=1"`
+}
+
+type nestedRequest struct {
+	Paging
+	Author   author  `json:"author"`
+	Editor   *author `json:"editor"`
+	Internal string  `json:"-" validate:"required"`
+	Skipped  string  `json:"skipped" validate:"-"`
+	Plain    string  `validate:"required"`
+}
+
+func TestStructFieldNames(t *testing.T) {
+	req := nestedRequest{
+		Paging: Paging{Page: -1},
+		Editor: &author{},
+	}
+
+	fields := fieldErrors(t, Struct(req))
+	got := make([]string, 0, len(fields))
+	for _, field := range fields {
+		got = append(got, field.Field+" "+field.Code)
+	}
+
+	want := []string{
+		"page too_small",
+		"author.name required",
+		"editor.name required",
+		"Internal required",
+		"Plain required",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("fields = %v, want %v", got, want)
+	}
+
+	// a nil nested struct is not checked
+	req = nestedRequest{Author: author{Name: "Frank Herbert"}, Internal: "x", Plain: "x"}
+	if err := Struct(&req); err != nil {
+		t.Errorf("Struct() = %v, want nil", err)
+	}
+}
+
+func TestStructMalformedTags(t *testing.T) {
+	tests := []struct {
+		name string
+		req  any
+	}{
+		{"unknown rule", &struct {
+			Name string `json:"name" validate:"required,email"`
+		}{}},
+		{"non-numeric min", &struct {
+			Name string `json:"name" validate:"min=a"`
+		}{}},
+		{"missing max argument", &struct {
+			Count int `json:"count" validate:"max"`
+		}{}},
+		{"oneof without options", &struct {
+			Format string `json:"format" validate:"oneof="`
+		}{}},
+		{"nested", &struct {
+			Inner struct {
+				Name string `json:"name" validate:"min=x"`
+			} `json:"inner"`
+		}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// a bad tag fails on empty values too, and again once cached
+			for range 2 {
+				err := Struct(tt.req)
+				if err == nil {
+					t.Fatal("Struct() = nil, want an error")
+				}
+				if errors.Is(err, domainErr.ErrValidation) {
+					t.Errorf("Struct() = %v, want a plain error rather than a validation error", err)
+				}
+			}
+		})
+	}
+}
+
+func TestStructNotStruct(t *testing.T) {
+	if err := Struct(42); err == nil {
+		t.Error("Struct(42) = nil, want an error")
+	}
+	if err := Struct((*ruleRequest)(nil)); err != nil {
+		t.Errorf("Struct(nil) = %v, want nil", err)
+	}
+}
+
+// fieldErrors returns the fields of the validation error err
+func fieldErrors(t *testing.T, err error) []domainErr.FieldError {
+	t.Helper()
+
+	var domainError *domainErr.Error
+	if !errors.As(err, &domainError) || !errors.Is(err, domainErr.ErrValidation) {
+		t.Fatalf("err = %v, want a validation error", err)
+	}
+	return domainError.Fields
+}
@@ -0,0 +1,162 @@
+// Package xlsx writes single sheet Office Open XML workbooks row by row, so a
+// sheet of any size can be streamed without holding it in memory. Strings are
+// written inline; there is no shared string table and no styling.
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const ContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+var staticParts = []struct {
+	name    string
+	content string
+}{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`},
+}
+
+const workbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets></workbook>`
+
+// Writer writes the rows of one sheet. Close must be called to finish the
+// workbook.
+type Writer struct {
+	zw    *zip.Writer
+	sheet io.Writer
+	row   int
+	err   error
+}
+
+// NewWriter starts a workbook with a single sheet called name.
+func NewWriter(w io.Writer, name string) (*Writer, error) {
+	zw := zip.NewWriter(w)
+
+	for _, part := range staticParts {
+		if err := writePart(zw, part.name, part.content); err != nil {
+			return nil, err
+		}
+	}
+	if err := writePart(zw, "xl/workbook.xml", fmt.Sprintf(workbook, escape(sheetName(name)))); err != nil {
+		return nil, err
+	}
+
+	sheet, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(sheet, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`+"\n"+
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`); err != nil {
+		return nil, err
+	}
+
+	return &Writer{zw: zw, sheet: sheet}, nil
+}
+
+// WriteRow appends a row. Integers and floats become numeric cells, times are
+// written as RFC 3339 text and everything else as text.
+func (w *Writer) WriteRow(values ...any) error {
+	if w.err != nil {
+		return w.err
+	}
+
+	w.row++
+	var b strings.Builder
+	fmt.Fprintf(&b, `<row r="%d">`, w.row)
+	for i, value := range values {
+		ref := columnName(i) + strconv.Itoa(w.row)
+		switch v := value.(type) {
+		case nil:
+			continue
+		case int:
+			fmt.Fprintf(&b, `<c r="%s"><v>%d</v></c>`, ref, v)
+		case int64:
+			fmt.Fprintf(&b, `<c r="%s"><v>%d</v></c>`, ref, v)
+		case float64:
+			fmt.Fprintf(&b, `<c r="%s"><v>%s</v></c>`, ref, strconv.FormatFloat(v, 'f', -1, 64))
+		case time.Time:
+			fmt.Fprintf(&b, `<c r="%s" t="inlineStr"><is><t>%s</t></is></c>`, ref, v.Format(time.RFC3339))
+		default:
+			text := fmt.Sprint(v)
+			if text == "" {
+				continue
+			}
+			fmt.Fprintf(&b, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, escape(text))
+		}
+	}
+	b.WriteString(`</row>`)
+
+	_, w.err = io.WriteString(w.sheet, b.String())
+	return w.err
+}
+
+// Close finishes the sheet and the zip archive. It does not close the
+// underlying writer.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if _, err := io.WriteString(w.sheet, `</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	return w.zw.Close()
+}
+
+func writePart(zw *zip.Writer, name, content string) error {
+	part, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(part, content)
+	return err
+}
+
+// columnName turns a zero based column index into its letters: A, B, ... AA
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// sheetName drops the characters excel does not allow in sheet names and
+// truncates to 31 characters
+func sheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return -1
+		}
+		return r
+	}, name)
+	if name == "" {
+		name = "Sheet1"
+	}
+	if runes := []rune(name); len(runes) > 31 {
+		name = string(runes[:31])
+	}
+	return name
+}
+
+// escape escapes xml text and drops characters xml 1.0 cannot carry
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' {
+			return -1
+		}
+		return r
+	}, s)))
+	return b.String()
+}